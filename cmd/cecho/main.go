@@ -1,21 +1,17 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
 	"flag"
 	"fmt"
-	"io"
-	"net"
 	"os"
+	"time"
 
 	"github.com/jeremyhuiskamp/dcm/dcm"
 	"github.com/jeremyhuiskamp/dcm/dcmnet"
 )
 
-const (
-	logDebug = true
-)
+const verification = "1.2.840.10008.1.1"
 
 // Fprintf to stderr, putting our program name on the front.
 func warnf(format string, elems ...interface{}) {
@@ -28,33 +24,17 @@ func warnln(elems ...interface{}) {
 	fmt.Fprintln(os.Stderr, e...)
 }
 
-func debug(format string, elems ...interface{}) {
-	if logDebug {
-		fmt.Fprintf(os.Stderr, format+"\n", elems...)
-	}
-}
-
-func writePDU(dst io.Writer, pduType uint16, src bytes.Buffer) {
-	binary.Write(dst, binary.LittleEndian, pduType)
-	binary.Write(dst, binary.BigEndian, uint32(src.Len()))
-	dst.Write(src.Bytes())
-}
-
-func readPDU(src io.Reader) (pduType uint16, pdu io.Reader) {
-	binary.Read(src, binary.LittleEndian, &pduType)
-	debug("Read pdu type %X", pduType)
-	var pduLength uint32
-	binary.Read(src, binary.BigEndian, &pduLength)
-	debug("Read pdu length %X", pduLength)
-	return pduType, io.LimitReader(src, int64(pduLength))
-}
-
 func main() {
-	var callingAE, calledAE, addr string
+	var callingAE, calledAE, addr, username, passcode string
+	var timeout time.Duration
 
 	flag.StringVar(&callingAE, "calling", "CECHO", "Calling AE Title")
 	flag.StringVar(&calledAE, "called", "CECHO", "Called AE Title")
 	flag.StringVar(&addr, "d", "", "host:port of SCP")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second,
+		"Timeout for establishing the association")
+	flag.StringVar(&username, "username", "", "Username to identify as")
+	flag.StringVar(&passcode, "passcode", "", "Passcode for the username")
 
 	flag.Parse()
 
@@ -62,40 +42,99 @@ func main() {
 	fmt.Printf("Called AE: %s\n", calledAE)
 	fmt.Printf("Address: %s\n", addr)
 
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		warnf("%s\n", err)
-		return
+	rq := dcmnet.AssociateRQ{
+		AssociateRQAC: dcmnet.AssociateRQAC{
+			CalledAE:  calledAE,
+			CallingAE: callingAE,
+		},
+	}
+
+	switch {
+	case username != "" && passcode != "":
+		rq.UserIdentity = dcmnet.NewUsernamePasscodeIdentity(username, passcode)
+	case username != "":
+		rq.UserIdentity = dcmnet.NewUsernameIdentity(username)
+	}
+
+	if err := echo(addr, rq, timeout); err != nil {
+		warnln(err)
+		os.Exit(1)
 	}
+}
+
+func echo(addr string, rq dcmnet.AssociateRQ, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tcap := dcmnet.NewTransferCapability(verification,
+		dcm.ImplicitVRLittleEndian)
 
-	rq := dcmnet.AssociateRQAC{
-		ProtocolVersion: 1,
-		CalledAE:        calledAE,
-		CallingAE:       callingAE,
-		PresentationContexts: []dcmnet.PresentationContext{
-			{
-				ID:               1,
-				AbstractSyntax:   "1.2.840.10008.1.1",
-				TransferSyntaxes: []dcm.TransferSyntax{dcm.ImplicitVRLittleEndian},
-			},
+	rq.PresentationContexts = []dcmnet.PresentationContext{
+		{
+			ID:               1,
+			AbstractSyntax:   tcap.AbstractSyntax,
+			TransferSyntaxes: tcap.TransferSyntaxes,
 		},
 	}
-	fmt.Printf("Sending %v\n", rq)
 
-	var buf bytes.Buffer
-	rq.Write(&buf)
-	writePDU(conn, 1, buf)
+	as, err := dcmnet.Dial(ctx, addr, rq)
+	if err != nil {
+		return err
+	}
 
-	_, pduSrc := readPDU(conn)
+	fmt.Printf("Association accepted, max pdu length: %d\n", as.MaxPDULength())
 
-	ac := dcmnet.AssociateRQAC{}
-	ac.Read(pduSrc)
-	fmt.Printf("Read %v\n", ac)
+	if len(as.AcceptedTCaps()) == 0 {
+		as.Release()
+		return fmt.Errorf("verification was not accepted")
+	}
+
+	cmd := dcm.NewObject()
+	for _, field := range []struct {
+		tag   dcm.Tag
+		value interface{}
+	}{
+		{dcm.AffectedSOPClassUID, verification},
+		{dcm.CommandField, int(dcmnet.CEchoReq)},
+		{dcm.MessageID, 1},
+		{dcm.CommandDataSetType, int(dcmnet.CommandHasNoDataSet)},
+	} {
+		if err = cmd.Set(field.tag, field.value); err != nil {
+			as.Abort()
+			return err
+		}
+	}
 
-	var release bytes.Buffer
-	binary.Write(&release, binary.LittleEndian, uint16(0))
-	binary.Write(&release, binary.LittleEndian, uint16(0))
-	writePDU(conn, uint16(dcmnet.PDUReleaseRQ), release)
+	err = as.Encoder().NextMessage(dcmnet.Message{Command: cmd, TCap: tcap})
+	if err != nil {
+		as.Abort()
+		return err
+	}
+
+	rsp, err := as.Decoder().NextMessage()
+	if err != nil {
+		as.Abort()
+		return err
+	}
+	if rsp == nil {
+		as.Abort()
+		return fmt.Errorf("no response to echo request")
+	}
+
+	var status uint16
+	if err = rsp.Command.Scan(dcm.Status, &status); err != nil {
+		as.Release()
+		return fmt.Errorf("unable to read status: %s", err)
+	}
+	fmt.Printf("Echo status: 0x%04X\n", status)
+
+	if err = as.Release(); err != nil {
+		return err
+	}
+
+	if status != 0x0000 {
+		return fmt.Errorf("echo failed with status 0x%04X", status)
+	}
 
-	conn.Close()
+	return nil
 }
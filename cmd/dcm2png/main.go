@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+	"github.com/jeremyhuiskamp/dcm/dcmio"
+)
+
+func main() {
+	frame := flag.Int("frame", 0, "the frame to convert, starting at 0")
+	out := flag.String("o", "", "the png file to write (default: input with .png)")
+	center := flag.Float64("wc", 0, "window center, overriding the file")
+	width := flag.Float64("ww", 0, "window width, overriding the file")
+	flag.Parse()
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dcm2png [flags] file.dcm")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	in := flag.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(in, ".dcm") + ".png"
+	}
+
+	file, err := os.Open(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	p, err := dcmio.NewFileParser(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	obj, err := dcmio.Build(p)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = decode(obj); err != nil {
+		log.Fatal(err)
+	}
+
+	px, err := dcm.GetPixels(obj)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := dcm.GetDisplay(obj)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if set["wc"] || set["ww"] {
+		// either may be left out, if the file has a window
+		if d.Window == nil {
+			if !set["wc"] || !set["ww"] {
+				log.Fatal("the file has no window, so both -wc and -ww are needed")
+			}
+			d.Window = &dcm.Window{}
+		}
+		if set["wc"] {
+			d.Window.Center = *center
+		}
+		if set["ww"] {
+			d.Window.Width = *width
+		}
+		if err = d.Window.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	img, err := px.Image(*frame, d)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dst, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = png.Encode(dst, img); err != nil {
+		dst.Close()
+		log.Fatal(err)
+	}
+
+	if err = dst.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// decode replaces encapsulated pixel data with native pixel data, if we
+// know how.
+func decode(obj dcm.Object) error {
+	el := obj.Get(dcm.PixelData)
+	if el == nil {
+		return nil
+	}
+
+	ee, ok := (*el).(dcm.EncapsulatedElement)
+	if !ok {
+		return nil
+	}
+
+	uid := strings.TrimRight(obj.GetString(dcm.TransferSyntaxUID), " \x00")
+	ts := dcm.GetTransferSyntax(uid)
+	if ts != dcm.RLELossless {
+		return fmt.Errorf("cannot decode pixel data in %s", ts)
+	}
+
+	ip, err := dcm.GetImagePixel(obj)
+	if err != nil {
+		return err
+	}
+
+	native, err := dcm.DecodeRLE(ee, ip)
+	if err != nil {
+		return err
+	}
+
+	obj.Put(native)
+	return nil
+}
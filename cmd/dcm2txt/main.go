@@ -10,7 +10,15 @@ import (
 )
 
 func main() {
+	dict := flag.String("dict", "", "private dictionary file (JSON) to load")
 	flag.Parse()
+
+	if *dict != "" {
+		if err := loadDictionary(*dict); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	file, err := os.Open(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
@@ -21,7 +29,8 @@ func main() {
 		log.Fatal(err)
 	}
 
-	nest := 0
+	// the end offsets of the sequences and items that we're in
+	var ends []uint64
 	for {
 		tag, err := p.NextTag()
 
@@ -32,24 +41,45 @@ func main() {
 			break
 
 		} else {
+			for len(ends) > 0 && ends[len(ends)-1] <= tag.Offset {
+				ends = ends[:len(ends)-1]
+			}
+
+			if tag.Tag == dcm.ItemDelimitationItem ||
+				tag.Tag == dcm.SequenceDelimitationItem {
+				if len(ends) > 0 {
+					ends = ends[:len(ends)-1]
+				}
+			}
+
 			fmt.Printf("%d:%s%s%s #%d %s\n",
 				tag.Offset,
-				indent(nest),
+				indent(len(ends)),
 				tag.Tag,
 				vrToString(tag.VR),
 				tag.ValueLength,
 				desc(tag))
 
 			// hmm, how can we compare identity and not values?
-			if tag.VR != nil && *tag.VR == dcm.SQ {
-				nest++
-			} else if tag.Tag == dcm.ItemDelimitationItem {
-				nest--
+			if tag.HasUndefinedLength() {
+				ends = append(ends, ^uint64(0))
+			} else if tag.Tag == dcm.Item || (tag.VR != nil && *tag.VR == dcm.SQ) {
+				ends = append(ends, tag.ValueOffset+uint64(tag.ValueLength))
 			}
 		}
 	}
 }
 
+func loadDictionary(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return dcm.LoadPrivateDictionaries(file)
+}
+
 func indent(nest int) (s string) {
 	for ; nest > 0; nest-- {
 		s += ">"
@@ -65,7 +95,7 @@ func vrToString(vr *dcm.VR) (s string) {
 }
 
 func desc(tag *dcmio.Tag) string {
-	d := dcm.SpecForTag("", tag.Tag)
+	d := dcm.SpecForTag(tag.PrivateCreator, tag.Tag)
 	if d != nil {
 		return d.GetDesc()
 	}
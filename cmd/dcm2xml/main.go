@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+	"github.com/jeremyhuiskamp/dcm/dcmio"
+	"github.com/jeremyhuiskamp/dcm/dcmxml"
+)
+
+func main() {
+	out := flag.String("o", "", "the xml file to write (default: stdout)")
+	bulkdata := flag.String("bulkdata", "", "directory to write binary "+
+		"values to, instead of including them in the xml")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dcm2xml [flags] file.dcm")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	p, err := dcmio.NewFileParser(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	obj, err := dcmio.Build(p)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var encoder dcmxml.Encoder
+	if *bulkdata != "" {
+		encoder.BulkDataURI = bulkDataWriter(*bulkdata)
+	}
+
+	data, err := encoder.Marshal(obj)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(*out, data, 0666)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// bulkDataWriter writes each binary value to a new file in the directory,
+// and gives its uri.  Encapsulated data is written as it is encoded in a
+// dicom file.
+func bulkDataWriter(dir string) func(dcm.Element) string {
+	count := 0
+	return func(el dcm.Element) string {
+		var data []byte
+		switch el := el.(type) {
+		case dcm.SimpleElement:
+			data = el.WithByteOrder(binary.LittleEndian).Data
+		case dcm.EncapsulatedElement:
+			data = el.Bytes()
+		}
+
+		count++
+		name := filepath.Join(dir, fmt.Sprintf("%08X-%d.raw",
+			uint32(el.GetTag()), count))
+		if err := ioutil.WriteFile(name, data, 0666); err != nil {
+			log.Fatal(err)
+		}
+
+		return "file:" + filepath.ToSlash(name)
+	}
+}
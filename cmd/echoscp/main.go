@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+	"github.com/jeremyhuiskamp/dcm/dcmnet"
+)
+
+const verification = "1.2.840.10008.1.1"
+
+func main() {
+	var aet, callingAETs, addr, auth string
+
+	flag.StringVar(&aet, "aet", "", "AE Title to answer to (default any)")
+	flag.StringVar(&callingAETs, "calling", "",
+		"Comma-separated AE Titles that may connect (default any)")
+	flag.StringVar(&addr, "l", ":11112", "host:port to listen on")
+	flag.StringVar(&auth, "auth", "",
+		"username:passcode that requestors must identify with (default none)")
+
+	flag.Parse()
+
+	log.SetPrefix("echoscp: ")
+
+	policy := dcmnet.Policy{
+		TransferCapabilities: []dcmnet.TransferCapability{
+			dcmnet.NewTransferCapability(verification,
+				dcm.ImplicitVRLittleEndian,
+				dcm.ExplicitVRLittleEndian),
+		},
+	}
+	if aet != "" {
+		policy.CalledAETitles = []string{aet}
+	}
+	if callingAETs != "" {
+		policy.CallingAETitles = strings.Split(callingAETs, ",")
+	}
+	if auth != "" {
+		parts := strings.SplitN(auth, ":", 2)
+		if len(parts) != 2 {
+			log.Fatalf("invalid -auth %q, expected username:passcode", auth)
+		}
+		policy.Authenticate = authenticator(parts[0], parts[1])
+	}
+
+	server := dcmnet.Server{
+		Acceptor:       policy,
+		Handler:        dcmnet.HandlerFunc(echo),
+		RequestTimeout: 30 * time.Second,
+	}
+
+	log.Printf("listening on %s", addr)
+	log.Fatal(server.ListenAndServe(addr))
+}
+
+// authenticator accepts a single username and passcode
+func authenticator(username, passcode string) dcmnet.Authenticator {
+	return func(id *dcmnet.UserIdentity) ([]byte, error) {
+		if id == nil || id.Type != dcmnet.UserIdentityUsernamePasscode ||
+			subtle.ConstantTimeCompare(id.PrimaryField, []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare(id.SecondaryField, []byte(passcode)) != 1 {
+			return nil, dcmnet.ErrAuthenticationFailed
+		}
+
+		return nil, nil
+	}
+}
+
+func echo(as *dcmnet.Association) {
+	rq := as.Request()
+	log.Printf("association from %s (%s)", rq.CallingAE, as.RemoteAddr())
+
+	for {
+		msg, err := as.Decoder().NextMessage()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		if msg == nil {
+			return
+		}
+
+		var cmdField, msgID uint16
+		msg.Command.Scan(dcm.CommandField, &cmdField)
+		msg.Command.Scan(dcm.MessageID, &msgID)
+
+		if dcmnet.CommandField(cmdField) != dcmnet.CEchoReq {
+			log.Printf("unsupported command %s",
+				dcmnet.CommandField(cmdField))
+			return
+		}
+
+		rsp := dcm.NewObject()
+		rsp.Set(dcm.AffectedSOPClassUID, verification)
+		rsp.Set(dcm.CommandField, int(dcmnet.CEchoRsp))
+		rsp.Set(dcm.MessageIDBeingRespondedTo, int(msgID))
+		rsp.Set(dcm.CommandDataSetType, int(dcmnet.CommandHasNoDataSet))
+		rsp.Set(dcm.Status, 0)
+
+		err = as.Encoder().NextMessage(dcmnet.Message{
+			Command: rsp,
+			TCap:    msg.TCap,
+		})
+		if err != nil {
+			log.Print(err)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+	"github.com/jeremyhuiskamp/dcm/dcmio"
+	"github.com/jeremyhuiskamp/dcm/dcmxml"
+)
+
+func main() {
+	out := flag.String("o", "", "the dicom file to write (default: input with .dcm)")
+	tsuid := flag.String("ts", dcm.ExplicitVRLittleEndian.UID(),
+		"transfer syntax, if the xml has no TransferSyntaxUID")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: xml2dcm [flags] file.xml")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	in := flag.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(in, ".xml") + ".dcm"
+	}
+
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	decoder := dcmxml.Decoder{BulkData: readBulkData}
+	obj, err := decoder.Unmarshal(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if obj.GetString(dcm.TransferSyntaxUID) == "" {
+		if err = obj.Set(dcm.TransferSyntaxUID, *tsuid); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	dst, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = dcmio.WriteFile(dst, obj); err != nil {
+		dst.Close()
+		log.Fatal(err)
+	}
+
+	if err = dst.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readBulkData reads bulk data from local files, as written by dcm2xml
+func readBulkData(uri string) ([]byte, error) {
+	if !strings.HasPrefix(uri, "file:") {
+		return nil, fmt.Errorf("unsupported bulk data uri %s", uri)
+	}
+
+	return ioutil.ReadFile(filepath.FromSlash(strings.TrimPrefix(uri, "file:")))
+}
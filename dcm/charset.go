@@ -0,0 +1,693 @@
+package dcm
+
+//go:generate go run gencharsets.go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Specific Character Set (0008,0005) support.
+// See PS 3.3, C.12.1.1.2 and PS 3.5, 6.1.
+//
+// Text values are held in memory as UTF-8.  dcmio decodes them from the
+// object's character set when reading, and encodes them again when writing.
+
+// graphicSet is a set of characters that can be designated to G0 or G1 with
+// ISO 2022 escape sequences.
+type graphicSet struct {
+	// escape designates the set
+	escape string
+	g1     bool
+	// double byte sets have 94x94 characters, single byte sets have 96
+	// characters at positions 0x20-0x7F
+	double bool
+
+	load  func() []rune
+	once  sync.Once
+	runes []rune
+	codes map[rune]int
+}
+
+func (gs *graphicSet) init() {
+	gs.once.Do(func() {
+		gs.runes = gs.load()
+		gs.codes = make(map[rune]int, len(gs.runes))
+		for position, r := range gs.runes {
+			if _, dup := gs.codes[r]; r != utf8.RuneError && !dup {
+				gs.codes[r] = position
+			}
+		}
+	})
+}
+
+func (gs *graphicSet) decode(position int) rune {
+	gs.init()
+	if position < 0 || position >= len(gs.runes) {
+		return utf8.RuneError
+	}
+
+	return gs.runes[position]
+}
+
+// encode appends the bytes for a character to dst, if the set has it
+func (gs *graphicSet) encode(dst []byte, r rune) ([]byte, bool) {
+	gs.init()
+	position, ok := gs.codes[r]
+	if !ok {
+		return dst, false
+	}
+
+	var high byte
+	if gs.g1 {
+		high = 0x80
+	}
+
+	if gs.double {
+		return append(dst,
+			byte(position/94+0x21)|high,
+			byte(position%94+0x21)|high), true
+	}
+
+	return append(dst, byte(position+0x20)|high), true
+}
+
+func tableRunes(table string) func() []rune {
+	return func() []rune {
+		return []rune(table)
+	}
+}
+
+var (
+	asciiSet = &graphicSet{
+		escape: "\x1b(B",
+		load: func() []rune {
+			runes := make([]rune, 96)
+			for i := range runes {
+				runes[i] = rune(i + 0x20)
+			}
+			return runes
+		},
+	}
+
+	// JIS X 0201 romaji differs from ASCII in two places
+	romajiSet = &graphicSet{
+		escape: "\x1b(J",
+		load: func() []rune {
+			runes := asciiSet.load()
+			runes['\\'-0x20] = '¥'
+			runes['~'-0x20] = '‾'
+			return runes
+		},
+	}
+
+	// JIS X 0201 katakana are the half width forms
+	katakanaSet = &graphicSet{
+		escape: "\x1b)I",
+		g1:     true,
+		load: func() []rune {
+			runes := make([]rune, 96)
+			for i := range runes {
+				runes[i] = utf8.RuneError
+				if i >= 1 && i <= 0x3F {
+					runes[i] = rune(0xFF61 + i - 1)
+				}
+			}
+			return runes
+		},
+	}
+
+	jisx0208Set = &graphicSet{
+		escape: "\x1b$B",
+		double: true,
+		load:   tableRunes(jisx0208Table),
+	}
+
+	jisx0212Set = &graphicSet{
+		escape: "\x1b$(D",
+		double: true,
+		load:   tableRunes(jisx0212Table),
+	}
+
+	ksx1001Set = &graphicSet{
+		escape: "\x1b$)C",
+		g1:     true,
+		double: true,
+		load:   tableRunes(ksx1001Table),
+	}
+
+	// GB 2312 is the part of GBK with both bytes in 0xA1-0xFE
+	gb2312Set = &graphicSet{
+		escape: "\x1b$)A",
+		g1:     true,
+		double: true,
+		load: func() []rune {
+			gbk := []rune(gbkTable)
+			runes := make([]rune, 0, 94*94)
+			for lead := 0xA1; lead <= 0xFE; lead++ {
+				for trail := 0xA1; trail <= 0xFE; trail++ {
+					runes = append(runes, gbk[gbkIndex(byte(lead), byte(trail))])
+				}
+			}
+			return runes
+		},
+	}
+
+	// upper halves of ISO 8859 and TIS 620, by ISO-IR number
+	singleByteSets = newSingleByteSets()
+)
+
+func newSingleByteSets() map[string]*graphicSet {
+	escapes := map[string]string{
+		"100": "\x1b-A", "101": "\x1b-B", "109": "\x1b-C", "110": "\x1b-D",
+		"144": "\x1b-L", "127": "\x1b-G", "126": "\x1b-F", "138": "\x1b-H",
+		"148": "\x1b-M", "203": "\x1b-b", "166": "\x1b-T",
+	}
+
+	sets := make(map[string]*graphicSet, len(singleByteTables))
+	for ir, table := range singleByteTables {
+		sets[ir] = &graphicSet{
+			escape: escapes[ir],
+			g1:     true,
+			load:   tableRunes(table),
+		}
+	}
+
+	return sets
+}
+
+// multiByteCodec is a character set that doesn't use ISO 2022.
+type multiByteCodec interface {
+	decode(data []byte) string
+	encode(str string) ([]byte, error)
+}
+
+// CharacterSet decodes and encodes text according to the values of
+// SpecificCharacterSet.
+type CharacterSet struct {
+	terms []string
+
+	// the initial designations, and the sets that may be designated
+	// with escape sequences
+	g0, g1     *graphicSet
+	extensions []*graphicSet
+
+	// if not nil, ISO 2022 is not used at all
+	codec multiByteCodec
+}
+
+// DefaultCharacterSet is used when there is no SpecificCharacterSet.  It is
+// only ASCII: other bytes decode to utf8.RuneError, and other characters
+// can't be encoded.  DecodeElement keeps such values as they are, so that
+// they can still be written again.
+var DefaultCharacterSet = &CharacterSet{g0: asciiSet}
+
+// Latin1CharacterSet is ISO 8859-1 (ISO_IR 100).  It can be given to
+// dcmio.BuildWithCharacterSet for files that use it without saying so.
+var Latin1CharacterSet = &CharacterSet{
+	terms: []string{"ISO_IR 100"},
+	g0:    asciiSet,
+	g1:    singleByteSets["100"],
+}
+
+// NewCharacterSet interprets the values of SpecificCharacterSet.
+func NewCharacterSet(terms []string) (*CharacterSet, error) {
+	if len(terms) == 0 {
+		return DefaultCharacterSet, nil
+	}
+
+	cs := &CharacterSet{terms: terms}
+
+	for i, term := range terms {
+		term = strings.TrimSpace(term)
+		iso2022 := strings.HasPrefix(term, "ISO 2022 IR ")
+
+		var g0, g1 *graphicSet
+		switch term {
+		case "", "ISO_IR 6", "ISO 2022 IR 6":
+			g0 = asciiSet
+		case "ISO_IR 13", "ISO 2022 IR 13":
+			g0, g1 = romajiSet, katakanaSet
+		case "ISO 2022 IR 87":
+			g0 = jisx0208Set
+		case "ISO 2022 IR 159":
+			g0 = jisx0212Set
+		case "ISO 2022 IR 149":
+			g1 = ksx1001Set
+		case "ISO 2022 IR 58":
+			g1 = gb2312Set
+		case "ISO_IR 192":
+			cs.codec = utf8Codec{}
+		case "GB18030":
+			cs.codec = gbCodec{fourByte: true}
+		case "GBK":
+			cs.codec = gbCodec{}
+		default:
+			ir := strings.TrimPrefix(strings.TrimPrefix(term, "ISO_IR "),
+				"ISO 2022 IR ")
+			if g1 = singleByteSets[ir]; g1 == nil {
+				return nil, fmt.Errorf("unknown character set %q", term)
+			}
+			g0 = asciiSet
+		}
+
+		if cs.codec != nil {
+			// these can't be combined with others
+			if len(terms) > 1 {
+				return nil, fmt.Errorf("%q can't use code extensions", term)
+			}
+			return cs, nil
+		}
+
+		if i == 0 {
+			cs.g0, cs.g1 = g0, g1
+			if cs.g0 == nil || cs.g0.double {
+				cs.g0 = asciiSet
+			}
+		} else if !iso2022 && term != "" {
+			return nil, fmt.Errorf("%q can't be used as a code extension", term)
+		}
+
+		if iso2022 {
+			for _, gs := range []*graphicSet{g0, g1} {
+				if gs != nil {
+					cs.extensions = append(cs.extensions, gs)
+				}
+			}
+		}
+	}
+
+	return cs, nil
+}
+
+// CharacterSet returns the character set of the object, according to its
+// SpecificCharacterSet.  Nested items without their own SpecificCharacterSet
+// use the one from the enclosing object, which this can't know about.
+func (o Object) CharacterSet() (*CharacterSet, error) {
+	// nb: empty values are kept, since they are significant
+	terms, err := o.Strings(SpecificCharacterSet)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCharacterSet(terms)
+}
+
+func (cs *CharacterSet) String() string {
+	return strings.Join(cs.terms, "\\")
+}
+
+// Affects returns whether values of the VR are subject to the character
+// set.  Other text VRs only use the default repertoire.
+func (cs *CharacterSet) Affects(vr VR) bool {
+	switch vr.Name {
+	case SH.Name, LO.Name, ST.Name, LT.Name, UT.Name, PN.Name, UC.Name:
+		return true
+	default:
+		return false
+	}
+}
+
+// delimiter returns whether a character is a delimiter, at which ISO 2022
+// designations return to their initial state
+func delimiter(vr VR, b byte) bool {
+	switch b {
+	case '\\', '\r', '\n', '\t', '\f':
+		return true
+	case '^', '=':
+		return VREq(&vr, &PN)
+	default:
+		return false
+	}
+}
+
+// Decode converts a value of the VR to UTF-8.  Undecodable bytes become
+// utf8.RuneError.
+func (cs *CharacterSet) Decode(vr VR, data []byte) string {
+	if cs.codec != nil {
+		return cs.codec.decode(data)
+	}
+
+	var buf bytes.Buffer
+	g0, g1 := cs.g0, cs.g1
+
+	for i := 0; i < len(data); {
+		b := data[i]
+
+		switch {
+		case b == 0x1b:
+			if gs := cs.designation(data[i:]); gs != nil {
+				if gs.g1 {
+					g1 = gs
+				} else {
+					g0 = gs
+				}
+				i += len(gs.escape)
+				continue
+			}
+			buf.WriteRune(utf8.RuneError)
+			i++
+
+		case b < 0x20 || b == 0x7F || (b >= 0x80 && b < 0xA0 && g1 != nil):
+			// C1 controls only exist alongside a G1 set
+			buf.WriteRune(rune(b))
+			if delimiter(vr, b) {
+				g0, g1 = cs.g0, cs.g1
+			}
+			i++
+
+		default:
+			gs := g0
+			if b >= 0x80 {
+				gs = g1
+			}
+
+			switch {
+			case gs == nil:
+				buf.WriteRune(utf8.RuneError)
+				i++
+
+			case gs.double:
+				if i+1 >= len(data) || data[i+1]&0x7F < 0x21 ||
+					data[i+1]&0x7F > 0x7E || b&0x7F < 0x21 || b&0x7F > 0x7E {
+					buf.WriteRune(utf8.RuneError)
+					i++
+					continue
+				}
+				position := int(b&0x7F-0x21)*94 + int(data[i+1]&0x7F-0x21)
+				buf.WriteRune(gs.decode(position))
+				i += 2
+
+			default:
+				buf.WriteRune(gs.decode(int(b&0x7F) - 0x20))
+				if b < 0x80 && delimiter(vr, b) {
+					// the other sets stay designated, to be lenient
+					// with files that don't repeat the escapes
+					g0 = cs.g0
+				}
+				i++
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+// designation finds the extension that an escape sequence designates
+func (cs *CharacterSet) designation(data []byte) *graphicSet {
+	for _, gs := range cs.extensions {
+		if bytes.HasPrefix(data, []byte(gs.escape)) {
+			return gs
+		}
+	}
+
+	// the initial sets can always be designated again
+	for _, gs := range []*graphicSet{cs.g0, cs.g1} {
+		if gs != nil && bytes.HasPrefix(data, []byte(gs.escape)) {
+			return gs
+		}
+	}
+
+	return nil
+}
+
+// Encode converts a UTF-8 value of the VR to the character set.  It is an
+// error if a character can't be represented.
+func (cs *CharacterSet) Encode(vr VR, str string) ([]byte, error) {
+	if cs.codec != nil {
+		return cs.codec.encode(str)
+	}
+
+	var out []byte
+	g0, g1 := cs.g0, cs.g1
+
+	for _, r := range str {
+		if r < 0x20 || r == 0x7F || (r >= 0x80 && r < 0xA0 && cs.g1 != nil) {
+			if g0 != cs.g0 {
+				out = append(out, cs.g0.escape...)
+			}
+			out = append(out, byte(r))
+			g0, g1 = cs.g0, cs.g1
+			continue
+		}
+
+		if r < 0x80 && delimiter(vr, byte(r)) {
+			if g0 != cs.g0 {
+				out = append(out, cs.g0.escape...)
+			}
+			out = append(out, byte(r))
+			g0, g1 = cs.g0, cs.g1
+			continue
+		}
+
+		var ok bool
+		for _, gs := range []*graphicSet{g0, g1} {
+			if gs != nil {
+				if out, ok = gs.encode(out, r); ok {
+					break
+				}
+			}
+		}
+		if ok {
+			continue
+		}
+
+		for _, gs := range append([]*graphicSet{cs.g0}, cs.extensions...) {
+			if gs == nil || gs.escape == "" {
+				continue
+			}
+
+			escaped := append(out, gs.escape...)
+			if escaped, ok = gs.encode(escaped, r); ok {
+				out = escaped
+				if gs.g1 {
+					g1 = gs
+				} else {
+					g0 = gs
+				}
+				break
+			}
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%q is not in character set %q", r, cs)
+		}
+	}
+
+	if g0 != cs.g0 {
+		out = append(out, cs.g0.escape...)
+	}
+
+	return out, nil
+}
+
+// DecodeElement returns the element with its value decoded to UTF-8, if the
+// character set affects its VR.  Values that don't decode exactly, such as
+// undeclared ISO 8859-1 in the default character set, are left as they are
+// rather than losing the bytes to utf8.RuneError.
+func (cs *CharacterSet) DecodeElement(se SimpleElement) SimpleElement {
+	if !cs.Affects(se.VR) {
+		return se
+	}
+
+	decoded := cs.Decode(se.VR, se.Data)
+	if strings.ContainsRune(decoded, utf8.RuneError) &&
+		!bytes.Contains(se.Data, []byte(string(utf8.RuneError))) {
+		return se
+	}
+
+	se.Data = []byte(decoded)
+	return se
+}
+
+// EncodeElement returns the element with its UTF-8 value encoded in the
+// character set, if it affects its VR.  Values that aren't UTF-8 are taken
+// to be ones that DecodeElement left as they were, and are not changed.
+func (cs *CharacterSet) EncodeElement(se SimpleElement) (SimpleElement, error) {
+	if !cs.Affects(se.VR) || !utf8.Valid(se.Data) {
+		return se, nil
+	}
+
+	data, err := cs.Encode(se.VR, string(se.Data))
+	if err != nil {
+		return se, fmt.Errorf("unable to encode %s: %s", se, err)
+	}
+
+	se.Data = data
+	return se, nil
+}
+
+type utf8Codec struct{}
+
+func (utf8Codec) decode(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+
+	var buf bytes.Buffer
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		buf.WriteRune(r)
+		data = data[size:]
+	}
+
+	return buf.String()
+}
+
+func (utf8Codec) encode(str string) ([]byte, error) {
+	if !utf8.ValidString(str) {
+		return nil, errors.New("invalid UTF-8")
+	}
+
+	return []byte(str), nil
+}
+
+// gbCodec is GB18030, or GBK, which is the one and two byte subset
+type gbCodec struct {
+	fourByte bool
+}
+
+func (gb gbCodec) String() string {
+	if gb.fourByte {
+		return "GB18030"
+	}
+	return "GBK"
+}
+
+type gb18030Range struct {
+	index       int
+	first, last rune
+}
+
+var (
+	gbOnce  sync.Once
+	gbRunes []rune
+	gbCodes map[rune]int
+)
+
+func gbInit() {
+	gbOnce.Do(func() {
+		gbRunes = []rune(gbkTable)
+		gbCodes = make(map[rune]int, len(gbRunes))
+		for i, r := range gbRunes {
+			if _, dup := gbCodes[r]; r != utf8.RuneError && !dup {
+				gbCodes[r] = i
+			}
+		}
+	})
+}
+
+// gbkIndex gives the position of a two byte sequence in gbkTable, or -1
+func gbkIndex(lead, trail byte) int {
+	if lead < 0x81 || lead > 0xFE || trail < 0x40 || trail > 0xFE ||
+		trail == 0x7F {
+		return -1
+	}
+
+	column := int(trail) - 0x40
+	if trail > 0x7F {
+		column--
+	}
+
+	return (int(lead)-0x81)*190 + column
+}
+
+// gb18030Supplementary is the linear index of the four byte sequence for
+// U+10000
+const gb18030Supplementary = 189000
+
+func (gb gbCodec) decode(data []byte) string {
+	gbInit()
+
+	var buf bytes.Buffer
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			buf.WriteByte(b)
+			i++
+
+		case gb.fourByte && i+3 < len(data) && data[i+1] >= 0x30 && data[i+1] <= 0x39:
+			index := (((int(b)-0x81)*10+int(data[i+1])-0x30)*126+
+				int(data[i+2])-0x81)*10 + int(data[i+3]) - 0x30
+			buf.WriteRune(gb18030Rune(index))
+			i += 4
+
+		case i+1 < len(data) && gbkIndex(b, data[i+1]) >= 0:
+			buf.WriteRune(gbRunes[gbkIndex(b, data[i+1])])
+			i += 2
+
+		default:
+			buf.WriteRune(utf8.RuneError)
+			i++
+		}
+	}
+
+	return buf.String()
+}
+
+func gb18030Rune(index int) rune {
+	if index >= gb18030Supplementary {
+		if r := rune(index - gb18030Supplementary + 0x10000); r <= utf8.MaxRune {
+			return r
+		}
+		return utf8.RuneError
+	}
+
+	for _, rng := range gb18030Ranges {
+		if index >= rng.index && index <= rng.index+int(rng.last-rng.first) {
+			return rng.first + rune(index-rng.index)
+		}
+	}
+
+	return utf8.RuneError
+}
+
+func (gb gbCodec) encode(str string) ([]byte, error) {
+	gbInit()
+
+	var out []byte
+	for _, r := range str {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+
+		if i, ok := gbCodes[r]; ok {
+			trail := i%190 + 0x40
+			if trail >= 0x7F {
+				trail++
+			}
+			out = append(out, byte(i/190+0x81), byte(trail))
+			continue
+		}
+
+		index := -1
+		if gb.fourByte && r >= 0x10000 {
+			index = int(r-0x10000) + gb18030Supplementary
+		} else if gb.fourByte {
+			for _, rng := range gb18030Ranges {
+				if r >= rng.first && r <= rng.last {
+					index = rng.index + int(r-rng.first)
+					break
+				}
+			}
+		}
+
+		if index < 0 || r == utf8.RuneError {
+			return nil, fmt.Errorf("%q is not in %s", r, gb)
+		}
+
+		out = append(out,
+			byte(index/12600+0x81),
+			byte(index/1260%10+0x30),
+			byte(index/10%126+0x81),
+			byte(index%10+0x30))
+	}
+
+	return out, nil
+}
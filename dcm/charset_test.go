@@ -0,0 +1,140 @@
+package dcm
+
+import (
+	"strings"
+	"testing"
+)
+
+// examples from PS 3.5, Annexes H, I, J and K
+var charsetExamples = []struct {
+	terms   string
+	encoded string
+	decoded string
+}{
+	{"ISO_IR 100", "Buc^J\xe9r\xf4me", "Buc^Jérôme"},
+	{"ISO_IR 126", "\xc4\xe9\xef\xed\xf5\xf3\xe9\xef\xf2", "Διονυσιος"},
+	{"ISO_IR 138", "\xf9\xf8\xe5\xef^\xe3\xe1\xe5\xf8\xe4", "שרון^דבורה"},
+	{"ISO_IR 144", "\xbb\xee\xdace\xdc\xd1yr\xd3", "Люкceмбyrг"},
+	{"ISO_IR 192", "Wang^XiaoDong=王^小東=", "Wang^XiaoDong=王^小東="},
+	{"GB18030", "Wang^XiaoDong=\xcd\xf5^\xd0\xa1\xb6\xab=", "Wang^XiaoDong=王^小东="},
+	{"GB18030", "\x81\x30\x81\x30\x95\x32\x82\x36", "\u0080\U00020000"},
+	{"\\ISO 2022 IR 87",
+		"Yamada^Tarou=\x1b$B;3ED\x1b(B^\x1b$BB@O:\x1b(B=" +
+			"\x1b$B$d$^$@\x1b(B^\x1b$B$?$m$&\x1b(B",
+		"Yamada^Tarou=山田^太郎=やまだ^たろう"},
+	{"ISO 2022 IR 13\\ISO 2022 IR 87",
+		"\xd4\xcf\xc0\xde^\xc0\xdb\xb3=\x1b$B;3ED\x1b(J^\x1b$BB@O:\x1b(J=" +
+			"\x1b$B$d$^$@\x1b(J^\x1b$B$?$m$&\x1b(J",
+		"ﾔﾏﾀﾞ^ﾀﾛｳ=山田^太郎=やまだ^たろう"},
+	{"\\ISO 2022 IR 149",
+		"Hong^Gildong=\x1b$)C\xfb\xf3^\x1b$)C\xd1\xce\xd4\xd7=" +
+			"\x1b$)C\xc8\xab^\x1b$)C\xb1\xe6\xb5\xbf",
+		"Hong^Gildong=洪^吉洞=홍^길동"},
+	{"\\ISO 2022 IR 58",
+		"Zhang^XiaoDong=\x1b$)A\xd5\xc5^\x1b$)A\xd0\xa1\xb6\xab=",
+		"Zhang^XiaoDong=张^小东="},
+}
+
+func TestCharacterSetExamples(t *testing.T) {
+	for _, test := range charsetExamples {
+		cs, err := NewCharacterSet(strings.Split(test.terms, "\\"))
+		if err != nil {
+			t.Errorf("%s: %s", test.terms, err)
+			continue
+		}
+
+		if got := cs.Decode(PN, []byte(test.encoded)); got != test.decoded {
+			t.Errorf("%s: expected to decode %q, got %q",
+				test.terms, test.decoded, got)
+		}
+
+		got, err := cs.Encode(PN, test.decoded)
+		if err != nil {
+			t.Errorf("%s: %s", test.terms, err)
+		} else if string(got) != test.encoded {
+			t.Errorf("%s: expected to encode %q, got %q",
+				test.terms, test.encoded, got)
+		}
+	}
+}
+
+func TestDefaultCharacterSet(t *testing.T) {
+	cs, err := NewObject().CharacterSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only ASCII, unless the file says otherwise:
+	if got := cs.Decode(LO, []byte("caf\xe9\x85")); got != "caf\ufffd\ufffd" {
+		t.Fatalf("unexpected decoding %q", got)
+	}
+
+	for _, str := range []string{"山", "café", "\u0085"} {
+		if _, err := cs.Encode(LO, str); err == nil {
+			t.Errorf("expected error encoding %q in the default character set", str)
+		}
+	}
+
+	if got := Latin1CharacterSet.Decode(LO, []byte("caf\xe9")); got != "café" {
+		t.Fatalf("unexpected Latin-1 decoding %q", got)
+	}
+}
+
+func TestCharacterSetEmptyFirstTerm(t *testing.T) {
+	cs, err := NewCharacterSet([]string{"", "ISO 2022 IR 87"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is designated to G1 initially:
+	if got := cs.Decode(PN, []byte("caf\xe9")); got != "caf\ufffd" {
+		t.Fatalf("unexpected decoding %q", got)
+	}
+
+	if _, err := cs.Encode(PN, "café"); err == nil {
+		t.Fatal("expected error encoding é without ISO 8859-1")
+	}
+}
+
+func TestCharacterSetInvalid(t *testing.T) {
+	for _, terms := range [][]string{
+		{"ISO_IR 999"},
+		{"ISO_IR 192", "ISO 2022 IR 87"},
+		{"", "ISO_IR 100"},
+	} {
+		if _, err := NewCharacterSet(terms); err == nil {
+			t.Errorf("expected error for %q", terms)
+		}
+	}
+
+	cs, err := NewCharacterSet([]string{"ISO_IR 100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// escapes aren't allowed without ISO 2022:
+	if got := cs.Decode(LO, []byte("a\x1b$Bb")); got != "a\ufffd$Bb" {
+		t.Fatalf("unexpected decoding %q", got)
+	}
+}
+
+func TestCharacterSetAffects(t *testing.T) {
+	cs, err := NewCharacterSet([]string{"ISO_IR 192"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// invalid values are kept as they are:
+	se := SimpleElement{Tag: PatientName, VR: PN, Data: []byte("\xff")}
+	if got := cs.DecodeElement(se); string(got.Data) != "\xff" {
+		t.Fatalf("unexpected decoding %q", got.Data)
+	}
+	if got, err := cs.EncodeElement(se); err != nil || string(got.Data) != "\xff" {
+		t.Fatalf("unexpected encoding %q: %v", got.Data, err)
+	}
+
+	se = SimpleElement{Tag: SOPClassUID, VR: UI, Data: []byte("\xff")}
+	if got := cs.DecodeElement(se); string(got.Data) != "\xff" {
+		t.Fatalf("UI should not be decoded, got %q", got.Data)
+	}
+}
@@ -3,7 +3,10 @@
 // license that can be found in the LICENSE file.
 package dcm
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 // Specifications for data dictionaries with support for the standard dictionary
 // (generated into stddict.go) and private creator dictionaries.
@@ -12,20 +15,66 @@ type ElementSpec struct {
 	tag      Tag
 	maxValue Tag
 	vr       VR
-	minVM    int
-	maxVM    int
-	retired  bool
-	desc     string
-	keyword  string
+	// The range of the value multiplicity, with a maximum of -1 if it is
+	// unbounded, as in 1-n, and whether the element is retired.  These
+	// aren't exported yet, since only the command elements of stddict.go
+	// have been generated with them.
+	minVM   int
+	maxVM   int
+	retired bool
+	desc    string
+	keyword string
 }
 
 func (e ElementSpec) GetDesc() string {
 	return e.desc
 }
 
+// GetTag returns the tag of the element, or the lowest tag if it is a
+// repeating group or range like (60xx,3000).
+func (e ElementSpec) GetTag() Tag {
+	return e.tag
+}
+
+func (e ElementSpec) GetVR() VR {
+	return e.vr
+}
+
+func (e ElementSpec) GetKeyword() string {
+	return e.keyword
+}
+
+// isRange returns whether the spec covers a range of tags, such as the
+// repeating group (60xx,3000)
+func (e ElementSpec) isRange() bool {
+	return e.maxValue > e.tag
+}
+
+// Matches returns whether the spec is for the given tag.  Specs for ranges
+// of tags, like (60xx,3000) or (50xx,xxxx), match any value of the x digits.
+// These are found as the lowest tag, with 0 digits, and the highest,
+// with F digits.
+func (e ElementSpec) Matches(tag Tag) bool {
+	if !e.isRange() {
+		return tag == e.tag
+	}
+
+	var wildcards Tag
+	for nibble := Tag(0xF); nibble != 0; nibble <<= 4 {
+		if e.tag&nibble == 0 && e.maxValue&nibble == nibble {
+			wildcards |= nibble
+		}
+	}
+
+	return tag&^wildcards == e.tag
+}
+
 type DataDictionary struct {
-	specsByTag        map[Tag]ElementSpec
-	specsByName       map[string]ElementSpec
+	specsByTag  map[Tag]ElementSpec
+	specsByName map[string]ElementSpec
+	// specs for ranges of tags, which are only in specsByTag under their
+	// lowest tag
+	ranges            []ElementSpec
 	privateCreatorUID string
 }
 
@@ -34,6 +83,18 @@ func (dd *DataDictionary) FindElementSpec(tag Tag) *ElementSpec {
 		return &spec
 	}
 
+	// ranges in the standard dictionary are for even groups, which
+	// mustn't be confused with private ones
+	if dd.privateCreatorUID == "" && tag.IsPrivate() {
+		return nil
+	}
+
+	for _, spec := range dd.ranges {
+		if spec.Matches(tag) {
+			return &spec
+		}
+	}
+
 	return nil
 }
 
@@ -57,13 +118,35 @@ var (
 	privateDictsMtx sync.Mutex
 )
 
+// NewDataDictionary creates a data dictionary and, if privateCreatorUID is
+// not empty, registers it as the dictionary for that private creator.
+//
+// The block of a private data element depends on where its creator was
+// reserved in a particular data set, so private dictionaries leave it out:
+// they define element (gggg,xxyy) as (gggg,00yy).
 func NewDataDictionary(privateCreatorUID string, specs map[Tag]ElementSpec) DataDictionary {
 	specsByName := make(map[string]ElementSpec, len(specs))
-	// TODO: populate specsByName??
+	var ranges []ElementSpec
+	for _, spec := range specs {
+		if spec.keyword != "" {
+			specsByName[spec.keyword] = spec
+		}
+
+		if spec.isRange() {
+			ranges = append(ranges, spec)
+		}
+	}
+
+	// map iteration is random, so make the order of ranges predictable,
+	// in case any overlap
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].tag < ranges[j].tag
+	})
 
 	dd := DataDictionary{
 		specsByTag:        specs,
 		specsByName:       specsByName,
+		ranges:            ranges,
 		privateCreatorUID: privateCreatorUID,
 	}
 
@@ -85,13 +168,35 @@ func GetPrivateDictionary(creatorUID string) *DataDictionary {
 	return nil
 }
 
+// privateCreatorSpec describes every private creator element, which isn't
+// in the standard dictionary because there are so many of them.
+var privateCreatorSpec = ElementSpec{
+	vr:      LO,
+	minVM:   1,
+	maxVM:   1,
+	desc:    "Private Creator",
+	keyword: "PrivateCreator",
+}
+
+// SpecForTag looks up a tag in the standard dictionary, or, for a private
+// data element, in the dictionary of the private creator that reserved its
+// block.
 func SpecForTag(privateCreatorUID string, tag Tag) *ElementSpec {
 	var dd *DataDictionary
 
 	if privateCreatorUID == "" {
+		if tag.IsPrivateCreator() {
+			spec := privateCreatorSpec
+			spec.tag, spec.maxValue = tag, tag
+			return &spec
+		}
+
 		dd = &stddict
 	} else {
 		dd = GetPrivateDictionary(privateCreatorUID)
+		if tag.IsPrivateData() {
+			tag &= 0xFFFF00FF
+		}
 	}
 
 	if dd == nil {
@@ -101,6 +206,12 @@ func SpecForTag(privateCreatorUID string, tag Tag) *ElementSpec {
 	return dd.FindElementSpec(tag)
 }
 
+// SpecForKeyword looks up a keyword, like "PatientName", in the standard
+// dictionary.
+func SpecForKeyword(keyword string) *ElementSpec {
+	return stddict.FindElementSpecByName(keyword)
+}
+
 func VRForTag(privateCreatorUID string, tag Tag) VR {
 	spec := SpecForTag(privateCreatorUID, tag)
 
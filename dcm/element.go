@@ -0,0 +1,337 @@
+package dcm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Construction of simple elements from Go values.
+// This is the inverse of the typed accessors in values.go.
+
+// NewElement encodes a value into a new element.  If vr is nil, it is
+// looked up in the data dictionary.
+//
+// The value may be a string, int, int64, float64, Tag, time.Time,
+// PartialTime or Name, or a slice of any of those for multiple values, or
+// []byte for the raw data.  It must suit the VR, eg, ints can be written to
+// IS, US, SS, UL, SL, DS and the float VRs, while time.Time can be written
+// to DA, TM or DT.
+//
+// The data is padded to an even length, and binary values are little endian.
+func NewElement(tag Tag, vr *VR, value interface{}) (SimpleElement, error) {
+	if vr == nil {
+		dictvr := VRForTag("", tag)
+		vr = &dictvr
+	}
+
+	se := SimpleElement{Tag: tag, VR: *vr}
+
+	var err error
+	switch value := value.(type) {
+	case []byte:
+		// copied, so that padding can't write into the caller's array
+		se.Data = append([]byte(nil), value...)
+	case string:
+		se.Data, err = encodeStrings(se.VR, []string{value})
+	case []string:
+		se.Data, err = encodeStrings(se.VR, value)
+	case int:
+		se.Data, err = encodeInts(se.VR, []int64{int64(value)})
+	case []int:
+		ints := make([]int64, len(value))
+		for i, v := range value {
+			ints[i] = int64(v)
+		}
+		se.Data, err = encodeInts(se.VR, ints)
+	case int64:
+		se.Data, err = encodeInts(se.VR, []int64{value})
+	case []int64:
+		se.Data, err = encodeInts(se.VR, value)
+	case float64:
+		se.Data, err = encodeFloats(se.VR, []float64{value})
+	case []float64:
+		se.Data, err = encodeFloats(se.VR, value)
+	case Tag:
+		se.Data, err = encodeTags(se.VR, []Tag{value})
+	case []Tag:
+		se.Data, err = encodeTags(se.VR, value)
+	case time.Time:
+		se.Data, err = encodeTimes(se.VR, []PartialTime{fullPrecision(value)})
+	case []time.Time:
+		times := make([]PartialTime, len(value))
+		for i, v := range value {
+			times[i] = fullPrecision(v)
+		}
+		se.Data, err = encodeTimes(se.VR, times)
+	case PartialTime:
+		se.Data, err = encodeTimes(se.VR, []PartialTime{value})
+	case []PartialTime:
+		se.Data, err = encodeTimes(se.VR, value)
+	case Name:
+		se.Data, err = encodeNames(se.VR, []Name{value})
+	case []Name:
+		se.Data, err = encodeNames(se.VR, value)
+	default:
+		err = fmt.Errorf("unsupported type %T", value)
+	}
+
+	if err != nil {
+		return se, fmt.Errorf("unable to encode %s: %s", se, err)
+	}
+
+	if len(se.Data)%2 != 0 {
+		se.Data = append(se.Data, se.VR.Padding)
+	}
+
+	return se, nil
+}
+
+// Set puts a new element with the given value into the object.  The VR is
+// that of the element being replaced, if any, or else comes from the data
+// dictionary.  See NewElement for the supported values.
+func (o Object) Set(tag Tag, value interface{}) error {
+	var vr *VR
+	if existing, ok := o.elements[tag].(SimpleElement); ok {
+		vr = &existing.VR
+	}
+
+	se, err := NewElement(tag, vr, value)
+	if err != nil {
+		return err
+	}
+
+	o.Put(se)
+	return nil
+}
+
+func encodeStrings(vr VR, values []string) ([]byte, error) {
+	if !isText(vr) {
+		return nil, errors.New("not a text VR")
+	}
+
+	switch vr.Name {
+	case LT.Name, ST.Name, UT.Name, UR.Name:
+		if len(values) > 1 {
+			return nil, errors.New("VR is single valued")
+		}
+
+	default:
+		for _, value := range values {
+			if strings.Contains(value, "\\") {
+				return nil, fmt.Errorf("value %q contains a backslash", value)
+			}
+		}
+	}
+
+	return []byte(strings.Join(values, "\\")), nil
+}
+
+func encodeInts(vr VR, values []int64) ([]byte, error) {
+	var min, max int64
+	switch vr.Name {
+	case IS.Name:
+		strs := make([]string, len(values))
+		for i, value := range values {
+			if value < math.MinInt32 || value > math.MaxInt32 {
+				return nil, fmt.Errorf("value %d out of range", value)
+			}
+			strs[i] = strconv.FormatInt(value, 10)
+		}
+		return encodeStrings(vr, strs)
+
+	case US.Name:
+		min, max = 0, math.MaxUint16
+	case SS.Name:
+		min, max = math.MinInt16, math.MaxInt16
+	case UL.Name:
+		min, max = 0, math.MaxUint32
+	case SL.Name:
+		min, max = math.MinInt32, math.MaxInt32
+
+	default:
+		floats := make([]float64, len(values))
+		for i, value := range values {
+			floats[i] = float64(value)
+		}
+		return encodeFloats(vr, floats)
+	}
+
+	size := vr.wordSize()
+	data := make([]byte, len(values)*size)
+	for i, value := range values {
+		if value < min || value > max {
+			return nil, fmt.Errorf("value %d out of range", value)
+		}
+
+		if size == 2 {
+			binary.LittleEndian.PutUint16(data[i*size:], uint16(value))
+		} else {
+			binary.LittleEndian.PutUint32(data[i*size:], uint32(value))
+		}
+	}
+
+	return data, nil
+}
+
+func encodeFloats(vr VR, values []float64) ([]byte, error) {
+	switch vr.Name {
+	case DS.Name:
+		strs := make([]string, len(values))
+		for i, value := range values {
+			str, err := formatDS(value)
+			if err != nil {
+				return nil, err
+			}
+			strs[i] = str
+		}
+		return encodeStrings(vr, strs)
+
+	case FL.Name, OF.Name, FD.Name, OD.Name:
+		size := vr.wordSize()
+		data := make([]byte, len(values)*size)
+		for i, value := range values {
+			if size == 4 {
+				binary.LittleEndian.PutUint32(data[i*size:],
+					math.Float32bits(float32(value)))
+			} else {
+				binary.LittleEndian.PutUint64(data[i*size:],
+					math.Float64bits(value))
+			}
+		}
+		return data, nil
+
+	default:
+		return nil, errors.New("not a numeric VR")
+	}
+}
+
+// formatDS formats a decimal string, which is limited to 16 characters
+func formatDS(value float64) (string, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", fmt.Errorf("value %f cannot be a decimal string", value)
+	}
+
+	str := strconv.FormatFloat(value, 'G', -1, 64)
+	for precision := 16; len(str) > 16; precision-- {
+		str = strconv.FormatFloat(value, 'G', precision, 64)
+	}
+
+	return str, nil
+}
+
+func encodeTags(vr VR, values []Tag) ([]byte, error) {
+	if !VREq(&vr, &AT) {
+		return nil, errors.New("tags can only be written to AT")
+	}
+
+	data := make([]byte, len(values)*4)
+	for i, value := range values {
+		binary.LittleEndian.PutUint16(data[i*4:], value.Group())
+		binary.LittleEndian.PutUint16(data[i*4+2:], value.Element())
+	}
+
+	return data, nil
+}
+
+// fullPrecision gives a time the precision needed to represent it exactly
+func fullPrecision(t time.Time) PartialTime {
+	if t.Nanosecond() != 0 {
+		return PartialTime{t, PrecisionFraction}
+	}
+
+	return PartialTime{t, PrecisionSecond}
+}
+
+// partialTimeLayouts are the time.Format layouts for each component of a DT
+var partialTimeLayouts = [...]string{
+	PrecisionYear:     "2006",
+	PrecisionMonth:    "01",
+	PrecisionDay:      "02",
+	PrecisionHour:     "15",
+	PrecisionMinute:   "04",
+	PrecisionSecond:   "05",
+	PrecisionFraction: ".000000",
+}
+
+// format formats a DT, or the date or time subset of it for DA or TM.
+// DT values include the UTC offset.
+func (pt PartialTime) format(vr VR) (string, error) {
+	first, last := PrecisionYear, pt.Precision
+	switch vr.Name {
+	case DA.Name:
+		if last > PrecisionDay {
+			last = PrecisionDay
+		}
+	case TM.Name:
+		first = PrecisionHour
+		if last < first {
+			return "", fmt.Errorf("%s has no time", pt.Time)
+		}
+	case DT.Name:
+	default:
+		return "", errors.New("times can only be written to DA, TM or DT")
+	}
+
+	if last < PrecisionYear || last > PrecisionFraction {
+		return "", fmt.Errorf("invalid precision %d", last)
+	}
+
+	if year := pt.Year(); first == PrecisionYear && (year < 0 || year > 9999) {
+		return "", fmt.Errorf("year %d out of range", year)
+	}
+
+	layout := strings.Join(partialTimeLayouts[first:last+1], "")
+	if vr.Name == DT.Name {
+		layout += "-0700"
+	}
+
+	return pt.Time.Format(layout), nil
+}
+
+func encodeTimes(vr VR, values []PartialTime) ([]byte, error) {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		str, err := value.format(vr)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = str
+	}
+
+	return encodeStrings(vr, strs)
+}
+
+// String formats the name as a PN value, leaving out empty trailing
+// components and groups.
+func (pn Name) String() string {
+	groups := []string{
+		pn.Alphabetic.String(),
+		pn.Ideographic.String(),
+		pn.Phonetic.String(),
+	}
+
+	return strings.TrimRight(strings.Join(groups, "="), "=")
+}
+
+func (ng NameGroup) String() string {
+	components := []string{ng.Family, ng.Given, ng.Middle, ng.Prefix, ng.Suffix}
+	return strings.TrimRight(strings.Join(components, "^"), "^")
+}
+
+func encodeNames(vr VR, values []Name) ([]byte, error) {
+	if !VREq(&vr, &PN) {
+		return nil, errors.New("names can only be written to PN")
+	}
+
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = value.String()
+	}
+
+	return encodeStrings(vr, strs)
+}
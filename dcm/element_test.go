@@ -0,0 +1,137 @@
+package dcm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewElement(t *testing.T) {
+	est := time.FixedZone("", -5*60*60)
+
+	for _, test := range []struct {
+		tag   Tag
+		vr    *VR
+		value interface{}
+		exp   SimpleElement
+	}{
+		{PatientID, nil, "abc", SimpleElement{PatientID, LO, []byte("abc "), nil}},
+		{SOPClassUID, nil, "1.2.3", SimpleElement{SOPClassUID, UI, []byte("1.2.3\x00"), nil}},
+		{ImageType, nil, []string{"ORIGINAL", "PRIMARY"},
+			SimpleElement{ImageType, CS, []byte("ORIGINAL\\PRIMARY"), nil}},
+		{Rows, nil, 512, SimpleElement{Rows, US, []byte{0x00, 0x02}, nil}},
+		{SmallestImagePixelValue, &SS, -2, SimpleElement{SmallestImagePixelValue, SS, []byte{0xFE, 0xFF}, nil}},
+		{SimpleFrameList, nil, []int64{1, 0xFFFFFFFF},
+			SimpleElement{SimpleFrameList, UL, []byte{1, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF}, nil}},
+		{NumberOfFrames, nil, 12, SimpleElement{NumberOfFrames, IS, []byte("12"), nil}},
+		{PixelSpacing, nil, []float64{0.5, 1.0 / 3},
+			SimpleElement{PixelSpacing, DS, []byte("0.5\\0.33333333333333"), nil}},
+		{RescaleSlope, nil, 2, SimpleElement{RescaleSlope, DS, []byte("2 "), nil}},
+		{RecommendedDisplayFrameRateInFloat, nil, 2.5,
+			SimpleElement{RecommendedDisplayFrameRateInFloat, FL, []byte{0x00, 0x00, 0x20, 0x40}, nil}},
+		{FrameIncrementPointer, nil, FrameTime,
+			SimpleElement{FrameIncrementPointer, AT, []byte{0x18, 0x00, 0x63, 0x10}, nil}},
+		{StudyDate, nil, time.Date(2024, 2, 29, 10, 30, 0, 0, est),
+			SimpleElement{StudyDate, DA, []byte("20240229"), nil}},
+		{StudyTime, nil, time.Date(2024, 2, 29, 10, 30, 0, 5000, est),
+			SimpleElement{StudyTime, TM, []byte("103000.000005 "), nil}},
+		{StudyTime, nil, PartialTime{time.Date(2024, 2, 29, 10, 30, 0, 0, est), PrecisionMinute},
+			SimpleElement{StudyTime, TM, []byte("1030"), nil}},
+		{AcquisitionDateTime, nil, time.Date(2024, 2, 29, 10, 30, 0, 0, est),
+			SimpleElement{AcquisitionDateTime, DT, []byte("20240229103000-0500 "), nil}},
+		{PatientName, nil, Name{
+			Alphabetic:  NameGroup{Family: "Yamada", Given: "Tarou"},
+			Ideographic: NameGroup{Family: "山田", Given: "太郎"},
+		}, SimpleElement{PatientName, PN, []byte("Yamada^Tarou=山田^太郎"), nil}},
+		{PatientName, nil, Name{Alphabetic: NameGroup{Family: "Doe", Prefix: "Dr"}},
+			SimpleElement{PatientName, PN, []byte("Doe^^^Dr"), nil}},
+		{PixelData, &OW, []byte{1, 2, 3}, SimpleElement{PixelData, OW, []byte{1, 2, 3, 0}, nil}},
+	} {
+		got, err := NewElement(test.tag, test.vr, test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.tag, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.exp, got) {
+			t.Errorf("%s: expected %q, got %q", test.tag, test.exp.Data, got.Data)
+		}
+	}
+}
+
+func TestNewElementCopiesBytes(t *testing.T) {
+	backing := []byte{'a', 'b', 'c', 'x'}
+	value := backing[:3]
+
+	se, err := NewElement(PatientID, &LO, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(se.Data) != "abc " {
+		t.Fatalf("unexpected data %q", se.Data)
+	}
+	if string(backing) != "abcx" {
+		t.Fatalf("caller's array was modified: %q", backing)
+	}
+
+	se.Data[0] = 'z'
+	if string(value) != "abc" {
+		t.Fatalf("element shares the caller's array: %q", value)
+	}
+}
+
+func TestNewElementErrors(t *testing.T) {
+	for _, test := range []struct {
+		tag   Tag
+		value interface{}
+	}{
+		{ImageType, "a\\b"},
+		{ImageComments, []string{"a", "b"}},
+		{Rows, "512"},
+		{Rows, 65536},
+		{Rows, -1},
+		{PatientID, 1},
+		{PixelSpacing, FrameTime},
+		{FrameIncrementPointer, 1.5},
+		{StudyTime, PartialTime{time.Now(), PrecisionDay}},
+		{PatientID, time.Now()},
+		{PatientID, Name{}},
+		{PatientID, struct{}{}},
+	} {
+		if _, err := NewElement(test.tag, nil, test.value); err == nil {
+			t.Errorf("%s: expected error for %#v", test.tag, test.value)
+		}
+	}
+}
+
+func TestSetRoundTrip(t *testing.T) {
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: SmallestImagePixelValue, VR: SS})
+
+	if err := obj.Set(SmallestImagePixelValue, -100); err != nil {
+		t.Fatal(err)
+	}
+	if values, err := obj.Ints(SmallestImagePixelValue); err != nil ||
+		!reflect.DeepEqual(values, []int64{-100}) {
+		t.Fatalf("unexpected values: %v, %v", values, err)
+	}
+
+	dt := PartialTime{time.Date(2024, 2, 29, 10, 30, 0, 0, time.UTC), PrecisionMinute}
+	if err := obj.Set(AcquisitionDateTime, dt); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := obj.DateTime(AcquisitionDateTime); err != nil ||
+		!got.Equal(dt.Time) || got.Precision != dt.Precision {
+		t.Fatalf("unexpected value: %s (%d), %v", got.Time, got.Precision, err)
+	}
+
+	name := Name{Alphabetic: NameGroup{Family: "Doe", Given: "Jane", Suffix: "III"}}
+	if err := obj.Set(PatientName, []Name{name, {}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := obj.PersonNames(PatientName); err != nil ||
+		!reflect.DeepEqual(got, []Name{name, {}}) {
+		t.Fatalf("unexpected names: %+v, %v", got, err)
+	}
+}
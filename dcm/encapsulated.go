@@ -0,0 +1,208 @@
+package dcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Access to the frames in encapsulated pixel data.
+// See PS 3.5, Annex A.4.
+
+// fragmentHeaderLen is the length of the item header in front of each
+// fragment, which is counted by the offsets in the basic offset table.
+const fragmentHeaderLen = 8
+
+// OffsetTable returns the basic offset table, which is the first fragment.
+// Each value is the offset of the first fragment of a frame, measured from
+// the first byte of the first fragment after the table.
+// The table may be empty.
+func (ee EncapsulatedElement) OffsetTable() ([]uint32, error) {
+	if len(ee.Data) == 0 {
+		return nil, errors.New("encapsulated data has no basic offset table")
+	}
+
+	table := ee.Data[0]
+	if len(table)%4 != 0 {
+		return nil, fmt.Errorf("basic offset table has invalid length %d",
+			len(table))
+	}
+
+	offsets := make([]uint32, len(table)/4)
+	for i := range offsets {
+		// encapsulated syntaxes are always little endian
+		offsets[i] = binary.LittleEndian.Uint32(table[i*4:])
+	}
+
+	return offsets, nil
+}
+
+// Fragments returns the fragments of encapsulated data, excluding the basic
+// offset table.
+func (ee EncapsulatedElement) Fragments() [][]byte {
+	if len(ee.Data) == 0 {
+		return nil
+	}
+
+	return ee.Data[1:]
+}
+
+// Frames returns the compressed data of each frame, combining fragments as
+// necessary.
+//
+// If the basic offset table is empty, the frames can only be found if there
+// is a single frame, or if each fragment is exactly one frame.
+func (ee EncapsulatedElement) Frames(numberOfFrames int) ([][]byte, error) {
+	offsets, err := ee.OffsetTable()
+	if err != nil {
+		return nil, err
+	}
+
+	fragments := ee.Fragments()
+
+	if len(offsets) == 0 {
+		switch {
+		case numberOfFrames == 1:
+			return [][]byte{concat(fragments)}, nil
+		case numberOfFrames == len(fragments):
+			return fragments, nil
+		default:
+			return nil, fmt.Errorf("cannot find %d frames in %d fragments "+
+				"without a basic offset table",
+				numberOfFrames, len(fragments))
+		}
+	}
+
+	if len(offsets) != numberOfFrames {
+		return nil, fmt.Errorf("basic offset table has %d entries, "+
+			"expected %d frames", len(offsets), numberOfFrames)
+	}
+
+	frames := make([][]byte, 0, numberOfFrames)
+	var position uint32
+	var frame [][]byte
+
+	next := 1
+	for _, fragment := range fragments {
+		if next < len(offsets) && position == offsets[next] {
+			frames = append(frames, concat(frame))
+			frame = nil
+			next++
+		} else if next < len(offsets) && position > offsets[next] {
+			return nil, fmt.Errorf("basic offset table entry %d (%d) "+
+				"does not match the start of a fragment",
+				next, offsets[next])
+		}
+
+		if len(frame) == 0 && position < offsets[next-1] {
+			return nil, fmt.Errorf("basic offset table entry %d (%d) "+
+				"does not match the start of a fragment",
+				next-1, offsets[next-1])
+		}
+
+		frame = append(frame, fragment)
+		position += fragmentHeaderLen + uint32(len(fragment))
+	}
+
+	if len(frame) == 0 || next != len(offsets) {
+		return nil, errors.New("basic offset table points past " +
+			"the last fragment")
+	}
+
+	return append(frames, concat(frame)), nil
+}
+
+func concat(fragments [][]byte) []byte {
+	if len(fragments) == 1 {
+		return fragments[0]
+	}
+
+	var length int
+	for _, fragment := range fragments {
+		length += len(fragment)
+	}
+
+	joined := make([]byte, 0, length)
+	for _, fragment := range fragments {
+		joined = append(joined, fragment...)
+	}
+
+	return joined
+}
+
+// Bytes returns the encapsulated data as it is encoded in the value of the
+// element: each fragment in an item, padded to an even length, followed
+// by a SequenceDelimitationItem.  Like the rest of the encapsulated data,
+// the item headers are little endian.
+func (ee EncapsulatedElement) Bytes() []byte {
+	// the first fragment is the basic offset table, which must be
+	// present even if empty:
+	fragments := ee.Data
+	if len(fragments) == 0 {
+		fragments = [][]byte{nil}
+	}
+
+	var data []byte
+	for _, fragment := range fragments {
+		length := len(fragment) + len(fragment)%2
+		data = appendItemHeader(data, Item, uint32(length))
+		data = append(data, fragment...)
+		if len(fragment)%2 != 0 {
+			data = append(data, 0)
+		}
+	}
+
+	return appendItemHeader(data, SequenceDelimitationItem, 0)
+}
+
+func appendItemHeader(data []byte, tag Tag, length uint32) []byte {
+	var header [fragmentHeaderLen]byte
+	binary.LittleEndian.PutUint16(header[0:], tag.Group())
+	binary.LittleEndian.PutUint16(header[2:], tag.Element())
+	binary.LittleEndian.PutUint32(header[4:], length)
+	return append(data, header[:]...)
+}
+
+// IsEncapsulated returns whether the data looks like the value of encapsulated
+// data, as produced by Bytes: it starts with an item and ends with a
+// SequenceDelimitationItem.
+func IsEncapsulated(data []byte) bool {
+	if len(data) < 2*fragmentHeaderLen {
+		return false
+	}
+
+	item := appendItemHeader(nil, Item, 0)
+	end := appendItemHeader(nil, SequenceDelimitationItem, 0)
+	return bytes.Equal(data[:4], item[:4]) &&
+		bytes.Equal(data[len(data)-fragmentHeaderLen:], end)
+}
+
+// ParseEncapsulated reads encapsulated data from the value of an element,
+// as produced by Bytes.
+func ParseEncapsulated(tag Tag, vr VR, data []byte) (EncapsulatedElement, error) {
+	ee := EncapsulatedElement{Tag: tag, VR: vr}
+
+	for len(data) >= fragmentHeaderLen {
+		itemTag := NewTag(binary.LittleEndian.Uint16(data[0:]),
+			binary.LittleEndian.Uint16(data[2:]))
+		length := binary.LittleEndian.Uint32(data[4:])
+		data = data[fragmentHeaderLen:]
+
+		switch {
+		case itemTag == SequenceDelimitationItem:
+			return ee, nil
+
+		case itemTag != Item:
+			return ee, fmt.Errorf("unexpected %s in encapsulated data", itemTag)
+
+		case uint64(length) > uint64(len(data)):
+			return ee, fmt.Errorf("fragment of length %d is truncated", length)
+		}
+
+		ee.Data = append(ee.Data, data[:length])
+		data = data[length:]
+	}
+
+	return ee, errors.New("encapsulated data has no SequenceDelimitationItem")
+}
@@ -0,0 +1,133 @@
+package dcm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFramesWithOffsetTable(t *testing.T) {
+	el := EncapsulatedElement{
+		Tag: PixelData,
+		VR:  OB,
+		Data: [][]byte{
+			{0x00, 0x00, 0x00, 0x00, 0x0C, 0x00, 0x00, 0x00},
+			{0x01, 0x02, 0x03, 0x04},
+			{0x05, 0x06},
+			{0x07, 0x08},
+		},
+	}
+
+	frames, err := el.Frames(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := [][]byte{
+		{0x01, 0x02, 0x03, 0x04},
+		{0x05, 0x06, 0x07, 0x08},
+	}
+	if !reflect.DeepEqual(exp, frames) {
+		t.Fatalf("unexpected frames: % X", frames)
+	}
+
+	if _, err := el.Frames(3); err == nil {
+		t.Fatal("expected error for wrong number of frames")
+	}
+}
+
+func TestFramesWithoutOffsetTable(t *testing.T) {
+	el := EncapsulatedElement{
+		Tag: PixelData,
+		VR:  OB,
+		Data: [][]byte{
+			nil,
+			{0x01, 0x02},
+			{0x03, 0x04},
+		},
+	}
+
+	for _, test := range []struct {
+		numberOfFrames int
+		exp            [][]byte
+	}{
+		{1, [][]byte{{0x01, 0x02, 0x03, 0x04}}},
+		{2, [][]byte{{0x01, 0x02}, {0x03, 0x04}}},
+	} {
+		frames, err := el.Frames(test.numberOfFrames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.exp, frames) {
+			t.Errorf("unexpected %d frames: % X", test.numberOfFrames, frames)
+		}
+	}
+
+	if _, err := el.Frames(3); err == nil {
+		t.Fatal("expected error when frames can't be found")
+	}
+}
+
+func TestFramesBadOffsetTable(t *testing.T) {
+	for _, table := range [][]byte{
+		// not a multiple of 4
+		{0x00, 0x00, 0x00},
+		// doesn't point at the start of a fragment
+		{0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00},
+		// points past the end
+		{0x00, 0x00, 0x00, 0x00, 0xFF, 0x00, 0x00, 0x00},
+	} {
+		el := EncapsulatedElement{
+			Tag:  PixelData,
+			VR:   OB,
+			Data: [][]byte{table, {0x01, 0x02}, {0x03, 0x04}},
+		}
+
+		if frames, err := el.Frames(2); err == nil {
+			t.Errorf("expected error for table % X, got frames % X",
+				table, frames)
+		}
+	}
+}
+
+func TestEncapsulatedBytes(t *testing.T) {
+	el := EncapsulatedElement{
+		Tag:  PixelData,
+		VR:   OB,
+		Data: [][]byte{{}, {0x01, 0x02}},
+	}
+
+	exp := []byte{
+		0xFE, 0xFF, 0x00, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0x00, 0xE0, 0x02, 0x00, 0x00, 0x00,
+		0x01, 0x02,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	data := el.Bytes()
+	if !reflect.DeepEqual(exp, data) {
+		t.Fatalf("unexpected encoding: % X", data)
+	}
+
+	if !IsEncapsulated(data) || IsEncapsulated(exp[8:18]) {
+		t.Fatal("unexpected IsEncapsulated")
+	}
+
+	got, err := ParseEncapsulated(PixelData, OB, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(el, got) {
+		t.Fatalf("expected %v, got %v", el.Data, got.Data)
+	}
+
+	for _, bad := range [][]byte{
+		exp[:18],
+		exp[:14],
+		append([]byte{0x08, 0x00, 0x00, 0xE0, 0x00, 0x00, 0x00, 0x00}, exp...),
+	} {
+		if _, err := ParseEncapsulated(PixelData, OB, bad); err == nil {
+			t.Errorf("expected error for % X", bad)
+		}
+	}
+}
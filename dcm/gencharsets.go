@@ -0,0 +1,223 @@
+//go:build ignore
+// +build ignore
+
+// Generates charset_tables.go from the encodings in golang.org/x/text.
+//
+// Run with `go generate` in this directory.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ISO 8859 and similar sets, by DICOM defined term suffix (ISO_IR xxx)
+var singleByte = []struct {
+	term string
+	enc  encoding.Encoding
+}{
+	{"100", charmap.ISO8859_1},
+	{"101", charmap.ISO8859_2},
+	{"109", charmap.ISO8859_3},
+	{"110", charmap.ISO8859_4},
+	{"144", charmap.ISO8859_5},
+	{"127", charmap.ISO8859_6},
+	{"126", charmap.ISO8859_7},
+	{"138", charmap.ISO8859_8},
+	{"148", charmap.ISO8859_9},
+	{"203", charmap.ISO8859_15},
+	// TIS 620 is Windows 874 without the additions below 0xA1:
+	{"166", charmap.Windows874},
+}
+
+// decode returns the single character that data decodes to, or the
+// replacement character
+func decode(enc encoding.Encoding, data []byte) rune {
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil || utf8.RuneCount(text) != 1 {
+		return utf8.RuneError
+	}
+
+	r, _ := utf8.DecodeRune(text)
+	return r
+}
+
+// upperHalf is the characters for bytes 0xA0-0xFF
+func upperHalf(term string, enc encoding.Encoding) []rune {
+	var runes []rune
+	for b := 0xA0; b <= 0xFF; b++ {
+		if term == "166" && b == 0xA0 {
+			runes = append(runes, utf8.RuneError)
+			continue
+		}
+		runes = append(runes, decode(enc, []byte{byte(b)}))
+	}
+	return runes
+}
+
+// doubleByte is the characters of a 94x94 set, encoded as EUC (both bytes
+// 0xA1-0xFE)
+func doubleByte(enc encoding.Encoding, prefix ...byte) []rune {
+	var runes []rune
+	for row := 1; row <= 94; row++ {
+		for col := 1; col <= 94; col++ {
+			data := append(append([]byte{}, prefix...),
+				byte(0xA0+row), byte(0xA0+col))
+			runes = append(runes, decode(enc, data))
+		}
+	}
+	return runes
+}
+
+// gbk is the two byte characters of GB18030, lead 0x81-0xFE
+func gbk() []rune {
+	var runes []rune
+	for lead := 0x81; lead <= 0xFE; lead++ {
+		for trail := 0x40; trail <= 0xFE; trail++ {
+			if trail == 0x7F {
+				continue
+			}
+			runes = append(runes, decode(simplifiedchinese.GB18030,
+				[]byte{byte(lead), byte(trail)}))
+		}
+	}
+	return runes
+}
+
+// gb18030Index is the linear index of a four byte GB18030 sequence
+func gb18030Index(data []byte) int {
+	return (((int(data[0])-0x81)*10+(int(data[1])-0x30))*126+
+		(int(data[2])-0x81))*10 + (int(data[3]) - 0x30)
+}
+
+type gb18030Range struct {
+	index       int
+	first, last rune
+}
+
+// gb18030Ranges are ranges of BMP characters with four byte encodings,
+// where the index increases along with the code point
+func gb18030Ranges() []gb18030Range {
+	var ranges []gb18030Range
+	encoder := simplifiedchinese.GB18030.NewEncoder()
+
+	for code := rune(0x80); code < 0x10000; code++ {
+		if code >= 0xD800 && code < 0xE000 {
+			continue
+		}
+
+		data, err := encoder.Bytes([]byte(string(code)))
+		if err != nil || len(data) != 4 {
+			continue
+		}
+
+		index := gb18030Index(data)
+		if n := len(ranges); n > 0 && ranges[n-1].last == code-1 &&
+			ranges[n-1].index+int(code-ranges[n-1].first) == index {
+			ranges[n-1].last = code
+		} else {
+			ranges = append(ranges, gb18030Range{index, code, code})
+		}
+	}
+
+	return ranges
+}
+
+// goString writes the characters as a Go string, split into lines
+func goString(runes []rune) string {
+	const width = 32
+
+	var out bytes.Buffer
+	for i := 0; i < len(runes); i += width {
+		if i > 0 {
+			out.WriteString(" +\n\t")
+		}
+
+		out.WriteByte('"')
+		for _, r := range runes[i:min(i+width, len(runes))] {
+			if unicode.IsPrint(r) && r != '"' && r != '\\' &&
+				r != utf8.RuneError {
+				out.WriteRune(r)
+			} else {
+				fmt.Fprintf(&out, "\\u%04x", r)
+			}
+		}
+		out.WriteByte('"')
+	}
+
+	return out.String()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func main() {
+	var out bytes.Buffer
+
+	fmt.Fprintln(&out, "// Code generated by gencharsets.go; DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "package dcm")
+	fmt.Fprintln(&out)
+
+	fmt.Fprintln(&out, "// upper halves (0xA0-0xFF) of the single byte character sets")
+	fmt.Fprintln(&out, "var singleByteTables = map[string]string{")
+	for _, set := range singleByte {
+		fmt.Fprintf(&out, "\t%s: %s,\n", strconv.Quote(set.term),
+			goString(upperHalf(set.term, set.enc)))
+	}
+	fmt.Fprintln(&out, "}")
+	fmt.Fprintln(&out)
+
+	for _, table := range []struct {
+		name, desc string
+		runes      []rune
+	}{
+		{"jisx0208Table", "JIS X 0208 (ISO-IR 87)",
+			doubleByte(japanese.EUCJP)},
+		{"jisx0212Table", "JIS X 0212 (ISO-IR 159)",
+			doubleByte(japanese.EUCJP, 0x8F)},
+		{"ksx1001Table", "KS X 1001 (ISO-IR 149)",
+			doubleByte(korean.EUCKR)},
+	} {
+		fmt.Fprintf(&out, "// %s, 94x94 characters in row order\n", table.desc)
+		fmt.Fprintf(&out, "var %s = %s\n\n", table.name, goString(table.runes))
+	}
+
+	fmt.Fprintln(&out, "// the two byte characters of GB18030 (and GBK), 126 lead bytes")
+	fmt.Fprintln(&out, "// (0x81-0xFE) by 190 trail bytes (0x40-0xFE, except 0x7F)")
+	fmt.Fprintf(&out, "var gbkTable = %s\n\n", goString(gbk()))
+
+	fmt.Fprintln(&out, "// ranges of BMP characters with four byte GB18030 encodings:")
+	fmt.Fprintln(&out, "// the linear index of the first encoding, and the first and last")
+	fmt.Fprintln(&out, "// characters")
+	fmt.Fprintln(&out, "var gb18030Ranges = []gb18030Range{")
+	for _, rng := range gb18030Ranges() {
+		fmt.Fprintf(&out, "\t{%d, 0x%04X, 0x%04X},\n", rng.index, rng.first, rng.last)
+	}
+	fmt.Fprintln(&out, "}")
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile("charset_tables.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
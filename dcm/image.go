@@ -0,0 +1,398 @@
+package dcm
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Conversion of native pixel data to images for display.
+
+// Window is a linear (or sigmoid) VOI transformation.
+// See PS 3.3, C.11.2.1.2.
+type Window struct {
+	Center float64
+	Width  float64
+
+	// Function is LINEAR (the default if empty), LINEAR_EXACT or SIGMOID
+	Function string
+}
+
+// Validate checks that the width suits the function: at least 1 for
+// LINEAR, and more than 0 for LINEAR_EXACT and SIGMOID.
+func (w Window) Validate() error {
+	switch w.Function {
+	case "LINEAR_EXACT", "SIGMOID":
+		if w.Width <= 0 {
+			return fmt.Errorf("invalid %s window width %g", w.Function, w.Width)
+		}
+	default:
+		if w.Width < 1 {
+			return fmt.Errorf("invalid window width %g", w.Width)
+		}
+	}
+	return nil
+}
+
+// Apply transforms a modality value into a fraction of the display range,
+// between 0 and 1.
+func (w Window) Apply(x float64) float64 {
+	switch w.Function {
+	case "SIGMOID":
+		return 1 / (1 + math.Exp(-4*(x-w.Center)/w.Width))
+
+	case "LINEAR_EXACT":
+		switch {
+		case x <= w.Center-w.Width/2:
+			return 0
+		case x > w.Center+w.Width/2:
+			return 1
+		default:
+			return (x-w.Center)/w.Width + 0.5
+		}
+
+	default:
+		switch {
+		case x <= w.Center-0.5-(w.Width-1)/2:
+			return 0
+		case x > w.Center-0.5+(w.Width-1)/2:
+			return 1
+		default:
+			return (x-(w.Center-0.5))/(w.Width-1) + 0.5
+		}
+	}
+}
+
+// LUT is a lookup table, as used for VOI LUTs and palettes.
+// See PS 3.3, C.11.1.1.
+type LUT struct {
+	// FirstMapped is the input value that maps to the first entry.
+	// Lower values map to the first entry and higher values past the end
+	// map to the last entry.
+	FirstMapped int
+	// Bits is the number of bits in each entry
+	Bits int
+	Data []uint16
+}
+
+func (l LUT) Lookup(value int) uint16 {
+	i := value - l.FirstMapped
+	if i < 0 {
+		i = 0
+	} else if i >= len(l.Data) {
+		i = len(l.Data) - 1
+	}
+
+	return l.Data[i]
+}
+
+// getLUT reads a lookup table from its descriptor and data elements.
+func getLUT(obj Object, descriptorTag, dataTag Tag) (*LUT, error) {
+	descriptor, err := obj.Ints(descriptorTag)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", descriptorTag, err)
+	}
+
+	if descriptor == nil {
+		return nil, nil
+	}
+
+	if len(descriptor) != 3 {
+		return nil, fmt.Errorf("%s has %d values", descriptorTag, len(descriptor))
+	}
+
+	// the number of entries is unsigned even if the descriptor is SS, but
+	// the first mapped value follows the VR
+	entries := int(uint16(descriptor[0]))
+	if entries == 0 {
+		entries = 1 << 16
+	}
+
+	lut := LUT{FirstMapped: int(descriptor[1]), Bits: int(descriptor[2])}
+	if lut.Bits < 1 || lut.Bits > 16 {
+		return nil, fmt.Errorf("%s has %d bits per entry", descriptorTag, lut.Bits)
+	}
+
+	dataEl := obj.Get(dataTag)
+	if dataEl == nil {
+		return nil, fmt.Errorf("%s has no matching %s", descriptorTag, dataTag)
+	}
+
+	data, ok := (*dataEl).(SimpleElement)
+	if !ok {
+		return nil, fmt.Errorf("unexpected lookup table data: %s", *dataEl)
+	}
+
+	lut.Data = make([]uint16, entries)
+	switch {
+	case len(data.Data) >= entries*2:
+		order := data.order()
+		for i := range lut.Data {
+			lut.Data[i] = order.Uint16(data.Data[i*2:])
+		}
+	case len(data.Data) >= entries:
+		// 8 bit entries, packed
+		for i := range lut.Data {
+			lut.Data[i] = uint16(data.Data[i])
+		}
+	default:
+		return nil, fmt.Errorf("%s has %d bytes for %d entries",
+			dataTag, len(data.Data), entries)
+	}
+
+	return &lut, nil
+}
+
+// Display holds the parameters for converting pixel data to an image.
+type Display struct {
+	// Window is used for grayscale images.
+	Window *Window
+	// VOILUT is used for grayscale images if there is no Window.
+	// If neither is present, the range of values in the frame is used.
+	VOILUT *LUT
+	// Palette holds the red, green and blue lookup tables for
+	// PALETTE COLOR images.
+	Palette [3]*LUT
+}
+
+// GetDisplay reads the display parameters from an object.  Only the first
+// window or VOI LUT is used.
+func GetDisplay(obj Object) (d Display, err error) {
+	if obj.Get(WindowCenter) != nil && obj.Get(WindowWidth) != nil {
+		d.Window = &Window{
+			Function: strings.TrimSpace(obj.GetString(VOILUTFunction)),
+		}
+
+		if d.Window.Center, err = getDecimal(obj, WindowCenter, 0); err != nil {
+			return d, err
+		}
+		if d.Window.Width, err = getDecimal(obj, WindowWidth, 0); err != nil {
+			return d, err
+		}
+		if err = d.Window.Validate(); err != nil {
+			return d, err
+		}
+	}
+
+	if el := obj.Get(VOILUTSequence); el != nil {
+		if sq, ok := (*el).(SequenceElement); ok && len(sq.Objects) > 0 {
+			d.VOILUT, err = getLUT(sq.Objects[0], LUTDescriptor, LUTData)
+			if err != nil {
+				return d, err
+			}
+		}
+	}
+
+	for i, tags := range [3][2]Tag{
+		{RedPaletteColorLookupTableDescriptor, RedPaletteColorLookupTableData},
+		{GreenPaletteColorLookupTableDescriptor, GreenPaletteColorLookupTableData},
+		{BluePaletteColorLookupTableDescriptor, BluePaletteColorLookupTableData},
+	} {
+		if d.Palette[i], err = getLUT(obj, tags[0], tags[1]); err != nil {
+			return d, err
+		}
+	}
+
+	return d, nil
+}
+
+// FrameImage converts a frame of an object's pixel data to an image, using
+// the display parameters from the object.
+func FrameImage(obj Object, frame int) (image.Image, error) {
+	px, err := GetPixels(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := GetDisplay(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return px.Image(frame, d)
+}
+
+func (px *Pixels) photometric() string {
+	if px.PhotometricInterpretation != "" {
+		return px.PhotometricInterpretation
+	}
+
+	if px.SamplesPerPixel == 1 {
+		return "MONOCHROME2"
+	}
+
+	return "RGB"
+}
+
+// sampleIndex gives the position of a sample in a frame, according to the
+// planar configuration
+func (px *Pixels) sampleIndex(pixel, sample int) int {
+	if px.PlanarConfiguration == 0 {
+		return pixel*px.SamplesPerPixel + sample
+	}
+
+	return sample*px.Rows*px.Columns + pixel
+}
+
+// Image converts a frame to an image.
+// Grayscale images are image.Gray if there are at most 8 bits stored, or
+// image.Gray16 otherwise.  Color images are image.RGBA.
+func (px *Pixels) Image(frame int, d Display) (image.Image, error) {
+	pi := px.photometric()
+	switch pi {
+	case "MONOCHROME1", "MONOCHROME2":
+		return px.grayImage(frame, d, pi == "MONOCHROME1")
+	case "RGB", "YBR_FULL":
+		return px.colorImage(frame, pi == "YBR_FULL")
+	case "PALETTE COLOR":
+		return px.paletteImage(frame, d.Palette)
+	default:
+		return nil, fmt.Errorf("unsupported photometric interpretation %q", pi)
+	}
+}
+
+func (px *Pixels) grayImage(frame int, d Display, invert bool) (image.Image, error) {
+	if px.SamplesPerPixel != 1 {
+		return nil, fmt.Errorf("grayscale image has %d samples per pixel",
+			px.SamplesPerPixel)
+	}
+
+	values, err := px.RescaledFrame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	var voi func(float64) float64
+	switch {
+	case d.Window != nil:
+		voi = d.Window.Apply
+
+	case d.VOILUT != nil:
+		max := float64(uint(1)<<uint(d.VOILUT.Bits) - 1)
+		voi = func(x float64) float64 {
+			return float64(d.VOILUT.Lookup(int(math.Floor(x)))) / max
+		}
+
+	default:
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, value := range values {
+			min = math.Min(min, value)
+			max = math.Max(max, value)
+		}
+		voi = func(x float64) float64 {
+			if max == min {
+				return 0
+			}
+			return (x - min) / (max - min)
+		}
+	}
+
+	rect := image.Rect(0, 0, px.Columns, px.Rows)
+	gray := image.NewGray(rect)
+	var gray16 *image.Gray16
+	if px.BitsStored > 8 {
+		gray16 = image.NewGray16(rect)
+	}
+
+	for i, value := range values {
+		y := math.Max(0, math.Min(1, voi(value)))
+		if invert {
+			y = 1 - y
+		}
+
+		if gray16 != nil {
+			gray16.Pix[i*2], gray16.Pix[i*2+1] =
+				split16(uint16(y*math.MaxUint16 + 0.5))
+		} else {
+			gray.Pix[i] = uint8(y*math.MaxUint8 + 0.5)
+		}
+	}
+
+	if gray16 != nil {
+		return gray16, nil
+	}
+
+	return gray, nil
+}
+
+// split16 gives the high and low bytes of a value
+func split16(value uint16) (uint8, uint8) {
+	return uint8(value >> 8), uint8(value)
+}
+
+func (px *Pixels) colorImage(frame int, ybr bool) (image.Image, error) {
+	if px.SamplesPerPixel != 3 {
+		return nil, fmt.Errorf("color image has %d samples per pixel",
+			px.SamplesPerPixel)
+	}
+
+	samples, err := px.Uint16Frame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	// reduce to 8 bits:
+	var shift uint
+	if px.BitsStored > 8 {
+		shift = uint(px.BitsStored - 8)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, px.Columns, px.Rows))
+	for p := 0; p < px.Rows*px.Columns; p++ {
+		var c [3]uint8
+		for s := range c {
+			c[s] = uint8(samples[px.sampleIndex(p, s)] >> shift)
+		}
+
+		if ybr {
+			c[0], c[1], c[2] = color.YCbCrToRGB(c[0], c[1], c[2])
+		}
+
+		img.Pix[p*4] = c[0]
+		img.Pix[p*4+1] = c[1]
+		img.Pix[p*4+2] = c[2]
+		img.Pix[p*4+3] = math.MaxUint8
+	}
+
+	return img, nil
+}
+
+func (px *Pixels) paletteImage(frame int, palette [3]*LUT) (image.Image, error) {
+	if px.SamplesPerPixel != 1 {
+		return nil, fmt.Errorf("palette image has %d samples per pixel",
+			px.SamplesPerPixel)
+	}
+
+	for _, lut := range palette {
+		if lut == nil {
+			return nil, errors.New("palette color image has no palette")
+		}
+	}
+
+	samples, err := px.Int16Frame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, px.Columns, px.Rows))
+	for p, sample := range samples {
+		index := int(sample)
+		if px.PixelRepresentation == 0 {
+			index = int(uint16(sample))
+		}
+
+		for s, lut := range palette {
+			value := lut.Lookup(index)
+			if lut.Bits > 8 {
+				value >>= uint(lut.Bits - 8)
+			}
+			img.Pix[p*4+s] = uint8(value)
+		}
+		img.Pix[p*4+3] = math.MaxUint8
+	}
+
+	return img, nil
+}
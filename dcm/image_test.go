@@ -0,0 +1,245 @@
+package dcm
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func ds(tag Tag, value string) SimpleElement {
+	return SimpleElement{Tag: tag, VR: DS, Data: []byte(value)}
+}
+
+func cs(tag Tag, value string) SimpleElement {
+	return SimpleElement{Tag: tag, VR: CS, Data: []byte(value)}
+}
+
+func TestWindowApply(t *testing.T) {
+	for _, test := range []struct {
+		window Window
+		x, exp float64
+	}{
+		{Window{Center: 50, Width: 101}, -1, 0},
+		{Window{Center: 50, Width: 101}, 49.5, 0.5},
+		{Window{Center: 50, Width: 101}, 100, 1},
+		{Window{Center: 50, Width: 100, Function: "LINEAR_EXACT"}, 25, 0.25},
+		{Window{Center: 50, Width: 100, Function: "LINEAR_EXACT"}, 0, 0},
+		{Window{Center: 50, Width: 100, Function: "SIGMOID"}, 50, 0.5},
+	} {
+		if got := test.window.Apply(test.x); got != test.exp {
+			t.Errorf("%+v.Apply(%f): expected %f, got %f",
+				test.window, test.x, test.exp, got)
+		}
+	}
+}
+
+func TestWindowValidate(t *testing.T) {
+	for _, test := range []struct {
+		window Window
+		valid  bool
+	}{
+		{Window{Width: 1}, true},
+		{Window{Width: 0.5}, false},
+		{Window{Width: 1, Function: "LINEAR"}, true},
+		{Window{Width: 0.5, Function: "LINEAR_EXACT"}, true},
+		{Window{Width: 0, Function: "LINEAR_EXACT"}, false},
+		{Window{Width: 0.5, Function: "SIGMOID"}, true},
+		{Window{Width: -1, Function: "SIGMOID"}, false},
+	} {
+		if err := test.window.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v: unexpected result %v", test.window, err)
+		}
+	}
+
+	// as read from the object:
+	obj := pixelObject(8, 8, 7, 0, []byte{0, 1, 2, 3})
+	obj.Put(ds(WindowCenter, "1"))
+	obj.Put(ds(WindowWidth, "0.5"))
+	if _, err := GetDisplay(obj); err == nil {
+		t.Error("expected error for a LINEAR width below 1")
+	}
+
+	obj.Put(cs(VOILUTFunction, "SIGMOID "))
+	if _, err := GetDisplay(obj); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGrayImageRange(t *testing.T) {
+	// without a window, the range of the frame is stretched:
+	obj := pixelObject(8, 8, 7, 0, []byte{10, 20, 30, 110})
+
+	img, err := FrameImage(obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("unexpected image type %T", img)
+	}
+
+	if exp := []uint8{0, 26, 51, 255}; !reflect.DeepEqual(exp, gray.Pix) {
+		t.Fatalf("unexpected pixels: %v", gray.Pix)
+	}
+}
+
+func TestGrayImageWindow(t *testing.T) {
+	// 12 bit, signed, with a rescale and window:
+	obj := pixelObject(16, 12, 11, 1, []byte{
+		0x00, 0x08, 0xFF, 0x07, 0x00, 0x00, 0x64, 0x00,
+	})
+	obj.Put(ds(RescaleIntercept, "-100"))
+	obj.Put(ds(WindowCenter, "0.5\\40"))
+	obj.Put(ds(WindowWidth, "2\\400"))
+	obj.Put(cs(PhotometricInterpretation, "MONOCHROME1 "))
+
+	img, err := FrameImage(obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gray, ok := img.(*image.Gray16)
+	if !ok {
+		t.Fatalf("unexpected image type %T", img)
+	}
+
+	// -2148, 1947, -100, 0, inverted:
+	exp := []color.Gray16{{0xFFFF}, {0}, {0xFFFF}, {0x8000}}
+	for i, c := range exp {
+		if got := gray.Gray16At(i%2, i/2); got != c {
+			t.Errorf("pixel %d: expected %v, got %v", i, c, got)
+		}
+	}
+}
+
+func TestGrayImageVOILUT(t *testing.T) {
+	obj := pixelObject(8, 8, 7, 0, []byte{0, 1, 2, 200})
+
+	item := NewObject()
+	item.Put(SimpleElement{Tag: LUTDescriptor, VR: US, Data: []byte{
+		3, 0, 1, 0, 8, 0,
+	}})
+	item.Put(SimpleElement{Tag: LUTData, VR: OW, Data: []byte{
+		10, 0, 20, 0, 30, 0,
+	}})
+	obj.Put(SequenceElement{Tag: VOILUTSequence, Objects: []Object{item}})
+
+	img, err := FrameImage(obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []uint8{10, 10, 20, 30}; !reflect.DeepEqual(exp, img.(*image.Gray).Pix) {
+		t.Fatalf("unexpected pixels: %v", img.(*image.Gray).Pix)
+	}
+}
+
+func TestColorImage(t *testing.T) {
+	obj := pixelObject(8, 8, 7, 0, []byte{
+		// planar: red, green, then blue
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+		9, 10, 11, 12,
+	})
+	obj.Put(us(SamplesPerPixel, 3))
+	obj.Put(us(PlanarConfiguration, 1))
+	obj.Put(cs(PhotometricInterpretation, "RGB "))
+
+	img, err := FrameImage(obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, exp := img.At(1, 1), (color.RGBA{4, 8, 12, 255}); got != exp {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	obj.Put(cs(PhotometricInterpretation, "YBR_FULL"))
+	obj.Put(us(PlanarConfiguration, 0))
+	obj.Put(SimpleElement{Tag: PixelData, VR: OB, Data: []byte{
+		128, 128, 128, 255, 128, 128, 0, 128, 128, 76, 85, 255,
+	}})
+
+	img, err = FrameImage(obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, exp := range []color.RGBA{
+		{128, 128, 128, 255},
+		{255, 255, 255, 255},
+		{0, 0, 0, 255},
+		{254, 0, 0, 255},
+	} {
+		if got := img.At(i%2, i/2); got != exp {
+			t.Errorf("pixel %d: expected %v, got %v", i, exp, got)
+		}
+	}
+}
+
+func TestInvalidVOILUT(t *testing.T) {
+	obj := pixelObject(8, 8, 7, 0, []byte{0, 1, 2, 200})
+
+	item := NewObject()
+	item.Put(SimpleElement{Tag: LUTDescriptor, VR: US, Data: []byte{
+		3, 0, 1, 0, 0, 0,
+	}})
+	item.Put(SimpleElement{Tag: LUTData, VR: OW, Data: []byte{
+		10, 0, 20, 0, 30, 0,
+	}})
+	obj.Put(SequenceElement{Tag: VOILUTSequence, Objects: []Object{item}})
+
+	if _, err := FrameImage(obj, 0); err == nil {
+		t.Fatal("expected error for a LUT with 0 bits per entry")
+	}
+}
+
+func TestPaletteImage(t *testing.T) {
+	obj := pixelObject(8, 8, 7, 0, []byte{0, 1, 2, 3})
+	obj.Put(cs(PhotometricInterpretation, "PALETTE COLOR "))
+
+	if _, err := FrameImage(obj, 0); err == nil {
+		t.Fatal("expected error for missing palette")
+	}
+
+	for i, tags := range [][2]Tag{
+		{RedPaletteColorLookupTableDescriptor, RedPaletteColorLookupTableData},
+		{GreenPaletteColorLookupTableDescriptor, GreenPaletteColorLookupTableData},
+		{BluePaletteColorLookupTableDescriptor, BluePaletteColorLookupTableData},
+	} {
+		// 16 bit entries, starting at 1:
+		obj.Put(SimpleElement{Tag: tags[0], VR: US, Data: []byte{
+			2, 0, 1, 0, 16, 0,
+		}})
+		obj.Put(SimpleElement{Tag: tags[1], VR: OW, Data: []byte{
+			0, byte(i * 10), 0, byte(100 + i),
+		}})
+	}
+
+	img, err := FrameImage(obj, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, exp := range []color.RGBA{
+		{0, 10, 20, 255},
+		{0, 10, 20, 255},
+		{100, 101, 102, 255},
+		{100, 101, 102, 255},
+	} {
+		if got := img.At(i%2, i/2); got != exp {
+			t.Errorf("pixel %d: expected %v, got %v", i, exp, got)
+		}
+	}
+}
+
+func TestUnsupportedPhotometric(t *testing.T) {
+	obj := pixelObject(8, 8, 7, 0, []byte{0, 1, 2, 3})
+	obj.Put(cs(PhotometricInterpretation, "YBR_FULL_422"))
+
+	if _, err := FrameImage(obj, 0); err == nil {
+		t.Fatal("expected error for YBR_FULL_422")
+	}
+}
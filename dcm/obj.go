@@ -78,6 +78,11 @@ func (o Object) Put(e Element) {
 	o.elements[e.GetTag()] = e
 }
 
+// Remove deletes the element with the given tag, if present.
+func (o Object) Remove(tag Tag) {
+	delete(o.elements, tag)
+}
+
 func (o Object) Get(tag Tag) *Element {
 	if e, ok := o.elements[tag]; ok {
 		return &e
@@ -110,11 +115,7 @@ func (o Object) Scan(tag Tag, dest interface{}) error {
 	// TODO: check for scannable types
 	// TODO: implement things like strings differently
 	// TODO: VR detection / conversion
-	return binary.Read(
-		bytes.NewReader(se.Data),
-		// TODO: detect endianness from transfer syntax
-		binary.LittleEndian,
-		dest)
+	return binary.Read(bytes.NewReader(se.Data), se.order(), dest)
 }
 
 func NewObject() Object {
@@ -129,6 +130,11 @@ type SimpleElement struct {
 	VR VR
 
 	Data []byte
+
+	// ByteOrder is the byte order of binary values in Data, which comes
+	// from the transfer syntax that the element was read with.
+	// nil means little endian.
+	ByteOrder binary.ByteOrder
 }
 
 func (se SimpleElement) GetTag() Tag {
@@ -158,3 +164,11 @@ type EncapsulatedElement struct {
 	VR   VR
 	Data [][]byte
 }
+
+func (ee EncapsulatedElement) GetTag() Tag {
+	return ee.Tag
+}
+
+func (ee EncapsulatedElement) String() string {
+	return fmt.Sprintf("%s %s (%d fragments)", ee.Tag, ee.VR, len(ee.Data))
+}
@@ -0,0 +1,238 @@
+package dcm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Paths address elements inside the items of sequences.
+//
+// A path is a list of tags separated by "." or "/".  Each tag is either a
+// keyword from the standard dictionary, like PatientName, or a number, like
+// (0010,0010) or 00100010.  Every tag but the last is a sequence, and can
+// be followed by the index of an item, like [0], or by [*] for all of its
+// items.  Leaving out the index also means all items.  Examples:
+//
+//	ReferencedSeriesSequence[0].SeriesInstanceUID
+//	(0008,1115)[*]/(0020,000E)
+
+// allItems is the index of a step that matches every item of the sequence
+const allItems = -1
+
+// maxCreatedIndex limits the items that Set adds to reach an index, so that
+// a path can't make us allocate arbitrarily many.
+const maxCreatedIndex = 1 << 16
+
+type pathStep struct {
+	tag   Tag
+	index int
+}
+
+// Path is a parsed path expression.
+type Path struct {
+	steps []pathStep
+}
+
+var (
+	pathSeparatorRegex = regexp.MustCompile(`[./]`)
+	pathStepRegex      = regexp.MustCompile(`^(\([[:xdigit:]]{4},[[:xdigit:]]{4}\)|` +
+		`[[:xdigit:]]{8}|[[:alpha:]][[:alnum:]]*)(\[([0-9]+|\*)\])?$`)
+)
+
+// ParsePath parses a path expression, as described above.
+func ParsePath(str string) (Path, error) {
+	var path Path
+
+	if str == "" {
+		return path, errors.New("empty path")
+	}
+
+	parts := pathSeparatorRegex.Split(str, -1)
+	for i, part := range parts {
+		matches := pathStepRegex.FindStringSubmatch(part)
+		if matches == nil {
+			return path, fmt.Errorf("invalid step %q in path %q", part, str)
+		}
+
+		tag, err := parsePathTag(matches[1])
+		if err != nil {
+			return path, fmt.Errorf("invalid path %q: %s", str, err)
+		}
+
+		if i == len(parts)-1 && matches[2] != "" {
+			return path, fmt.Errorf("path %q ends with an item index", str)
+		}
+
+		step := pathStep{tag, allItems}
+		if matches[3] != "" && matches[3] != "*" {
+			if step.index, err = strconv.Atoi(matches[3]); err != nil {
+				return path, fmt.Errorf("invalid index in path %q", str)
+			}
+		}
+
+		path.steps = append(path.steps, step)
+	}
+
+	return path, nil
+}
+
+func parsePathTag(str string) (Tag, error) {
+	if strings.HasPrefix(str, "(") {
+		str = str[1:5] + str[6:10]
+	}
+
+	if len(str) == 8 {
+		if value, err := strconv.ParseUint(str, 16, 32); err == nil {
+			return Tag(value), nil
+		}
+	}
+
+	spec := SpecForKeyword(str)
+	if spec == nil {
+		return 0, fmt.Errorf("unknown keyword %q", str)
+	}
+
+	return spec.GetTag(), nil
+}
+
+// String formats the path with numeric tags, which can be parsed again.
+func (p Path) String() string {
+	steps := make([]string, len(p.steps))
+	for i, step := range p.steps {
+		steps[i] = step.tag.String()
+		if i == len(p.steps)-1 {
+			break
+		}
+
+		if step.index == allItems {
+			steps[i] += "[*]"
+		} else {
+			steps[i] += fmt.Sprintf("[%d]", step.index)
+		}
+	}
+
+	return strings.Join(steps, "/")
+}
+
+// Tag returns the tag of the element that the path addresses, or zero for
+// an empty path.
+func (p Path) Tag() Tag {
+	if len(p.steps) == 0 {
+		return 0
+	}
+
+	return p.steps[len(p.steps)-1].tag
+}
+
+// parents finds the objects that hold the element addressed by the path.
+// If create is set, missing sequences and items with an index are added.
+// Otherwise, missing items and elements that aren't sequences are skipped.
+func (p Path) parents(obj Object, create bool) ([]Object, error) {
+	if len(p.steps) == 0 {
+		return nil, errors.New("empty path")
+	}
+
+	objs := []Object{obj}
+
+	for _, step := range p.steps[:len(p.steps)-1] {
+		var next []Object
+
+		for _, o := range objs {
+			el, ok := o.elements[step.tag]
+			if !ok {
+				if !create || step.index == allItems {
+					continue
+				}
+				el = SequenceElement{Tag: step.tag}
+			}
+
+			sq, ok := el.(SequenceElement)
+			if !ok {
+				if create {
+					return nil, fmt.Errorf("%s is not a sequence", step.tag)
+				}
+				continue
+			}
+
+			if step.index == allItems {
+				next = append(next, sq.Objects...)
+				continue
+			}
+
+			if step.index >= len(sq.Objects) {
+				if !create {
+					continue
+				}
+
+				if step.index >= maxCreatedIndex {
+					return nil, fmt.Errorf("index %d of %s is too large to add",
+						step.index, step.tag)
+				}
+
+				for len(sq.Objects) <= step.index {
+					sq.Objects = append(sq.Objects, NewObject())
+				}
+				o.Put(sq)
+			}
+
+			next = append(next, sq.Objects[step.index])
+		}
+
+		objs = next
+	}
+
+	return objs, nil
+}
+
+// Get returns the elements that the path addresses in the object, in the
+// order of the items they are in.
+func (p Path) Get(obj Object) []Element {
+	objs, _ := p.parents(obj, false)
+
+	var elements []Element
+	for _, o := range objs {
+		if el, ok := o.elements[p.Tag()]; ok {
+			elements = append(elements, el)
+		}
+	}
+
+	return elements
+}
+
+// Set sets the value of the elements that the path addresses, as with
+// Object.Set.  Sequences and items with an index are added if they are
+// missing, for indexes below 65536.  Paths with [*] only set values in the
+// items that already exist.
+func (p Path) Set(obj Object, value interface{}) error {
+	objs, err := p.parents(obj, true)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objs {
+		if err = o.Set(p.Tag(), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the elements that the path addresses, and returns how many
+// there were.
+func (p Path) Delete(obj Object) int {
+	objs, _ := p.parents(obj, false)
+
+	deleted := 0
+	for _, o := range objs {
+		if _, ok := o.elements[p.Tag()]; ok {
+			o.Remove(p.Tag())
+			deleted++
+		}
+	}
+
+	return deleted
+}
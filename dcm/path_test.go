@@ -0,0 +1,134 @@
+package dcm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	for input, exp := range map[string]string{
+		"PatientID": "(0010,0020)",
+		"ReferencedSeriesSequence[0].SeriesInstanceUID":    "(0008,1115)[0]/(0020,000E)",
+		"(0008,1115)[*]/(0020,000E)":                       "(0008,1115)[*]/(0020,000E)",
+		"00081115/0020000e":                                "(0008,1115)[*]/(0020,000E)",
+		"ContentSequence[2].ContentSequence[10].TextValue": "(0040,A730)[2]/(0040,A730)[10]/(0040,A160)",
+	} {
+		path, err := ParsePath(input)
+		if err != nil {
+			t.Errorf("%s: %s", input, err)
+		} else if got := path.String(); got != exp {
+			t.Errorf("%s: expected %s, got %s", input, exp, got)
+		}
+	}
+
+	for _, input := range []string{
+		"",
+		"NotAKeyword",
+		"PatientID[0]",
+		"ReferencedSeriesSequence..SeriesInstanceUID",
+		"ReferencedSeriesSequence[x].SeriesInstanceUID",
+		"(0008,1115",
+		"ReferencedSeriesSequence/",
+	} {
+		if _, err := ParsePath(input); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func mustParsePath(t *testing.T, str string) Path {
+	path, err := ParsePath(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPathGetSetDelete(t *testing.T) {
+	obj := NewObject()
+
+	// items are added as needed:
+	for _, str := range []string{
+		"ReferencedSeriesSequence[0].SeriesInstanceUID",
+		"ReferencedSeriesSequence[2].SeriesInstanceUID",
+	} {
+		if err := mustParsePath(t, str).Set(obj, "1.2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := mustParsePath(t, "ReferencedSeriesSequence[*].SeriesInstanceUID")
+	exp := SimpleElement{Tag: SeriesInstanceUID, VR: UI, Data: []byte("1.2\x00")}
+	if got := all.Get(obj); !reflect.DeepEqual(got, []Element{exp, exp}) {
+		t.Fatalf("unexpected elements %v", got)
+	}
+
+	sq := obj.elements[ReferencedSeriesSequence].(SequenceElement)
+	if len(sq.Objects) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(sq.Objects))
+	}
+
+	// wildcards set existing items only:
+	if err := all.Set(obj, "1.3"); err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := sq.Objects[1].Strings(SeriesInstanceUID); !reflect.DeepEqual(values, []string{"1.3"}) {
+		t.Fatalf("unexpected values %q", values)
+	}
+
+	if deleted := all.Delete(obj); deleted != 3 {
+		t.Fatalf("expected to delete 3 elements, deleted %d", deleted)
+	}
+	if got := all.Get(obj); len(got) != 0 {
+		t.Fatalf("unexpected elements %v", got)
+	}
+
+	// nothing to set through a missing sequence:
+	missing := mustParsePath(t, "ReferencedStudySequence[*].StudyInstanceUID")
+	if err := missing.Set(obj, "1.4"); err != nil {
+		t.Fatal(err)
+	}
+	if obj.Get(ReferencedStudySequence) != nil {
+		t.Fatal("unexpected sequence")
+	}
+
+	// not a sequence:
+	obj.Set(PatientID, "pid")
+	if err := mustParsePath(t, "PatientID[0].PatientName").Set(obj, "x"); err == nil {
+		t.Fatal("expected error setting inside a simple element")
+	}
+	if got := mustParsePath(t, "PatientID.PatientName").Get(obj); len(got) != 0 {
+		t.Fatalf("unexpected elements %v", got)
+	}
+
+	// too many items to add:
+	huge := mustParsePath(t, "ReferencedImageSequence[999999999].ReferencedSOPInstanceUID")
+	if err := huge.Set(obj, "1.5"); err == nil {
+		t.Fatal("expected error for a huge index")
+	}
+	if obj.Get(ReferencedImageSequence) != nil {
+		t.Fatal("unexpected sequence")
+	}
+}
+
+func TestEmptyPath(t *testing.T) {
+	obj := NewObject()
+	obj.Set(PatientID, "pid")
+
+	var path Path
+	if tag := path.Tag(); tag != 0 {
+		t.Fatalf("unexpected tag %s", tag)
+	}
+	if got := path.Get(obj); len(got) != 0 {
+		t.Fatalf("unexpected elements %v", got)
+	}
+	if err := path.Set(obj, "x"); err == nil {
+		t.Fatal("expected error setting an empty path")
+	}
+	if deleted := path.Delete(obj); deleted != 0 {
+		t.Fatalf("unexpected deletion of %d elements", deleted)
+	}
+	if obj.Get(PatientID) == nil {
+		t.Fatal("expected PatientID to remain")
+	}
+}
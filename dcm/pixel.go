@@ -0,0 +1,313 @@
+package dcm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Access to native pixel data.
+
+// ImagePixel describes the layout of native pixel data.
+// See PS 3.3, C.7.6.3.
+type ImagePixel struct {
+	Rows            int
+	Columns         int
+	SamplesPerPixel int
+	BitsAllocated   int
+	BitsStored      int
+	HighBit         int
+
+	// PixelRepresentation is 0 for unsigned samples or 1 for two's
+	// complement samples.
+	PixelRepresentation int
+
+	// PlanarConfiguration is 0 if the samples of each pixel are together
+	// (eg, RGBRGB...) or 1 if each sample is in a separate plane
+	// (eg, RRR...GGG...BBB...).
+	PlanarConfiguration int
+
+	NumberOfFrames int
+
+	// PhotometricInterpretation is the color space of the samples, eg,
+	// MONOCHROME2 or RGB.
+	PhotometricInterpretation string
+}
+
+// GetImagePixel reads the attributes of the image pixel module from an object.
+// Rows, Columns and BitsAllocated are required, the others have defaults.
+func GetImagePixel(obj Object) (ip ImagePixel, err error) {
+	for _, field := range []struct {
+		tag   Tag
+		dest  *int
+		value int
+	}{
+		{Rows, &ip.Rows, -1},
+		{Columns, &ip.Columns, -1},
+		{BitsAllocated, &ip.BitsAllocated, -1},
+		{SamplesPerPixel, &ip.SamplesPerPixel, 1},
+		{PixelRepresentation, &ip.PixelRepresentation, 0},
+		{PlanarConfiguration, &ip.PlanarConfiguration, 0},
+	} {
+		*field.dest, err = getUS(obj, field.tag, field.value)
+		if err != nil {
+			return ip, err
+		}
+	}
+
+	if ip.BitsStored, err = getUS(obj, BitsStored, ip.BitsAllocated); err != nil {
+		return ip, err
+	}
+
+	if ip.HighBit, err = getUS(obj, HighBit, ip.BitsStored-1); err != nil {
+		return ip, err
+	}
+
+	frames, err := getDecimal(obj, NumberOfFrames, 1)
+	if err != nil {
+		return ip, err
+	}
+	ip.NumberOfFrames = int(frames)
+
+	ip.PhotometricInterpretation = strings.TrimSpace(
+		obj.GetString(PhotometricInterpretation))
+
+	return ip, nil
+}
+
+// frames is NumberOfFrames, which is optional and defaults to 1
+func (ip ImagePixel) frames() int {
+	if ip.NumberOfFrames < 1 {
+		return 1
+	}
+
+	return ip.NumberOfFrames
+}
+
+func (ip ImagePixel) bytesPerSample() int {
+	return (ip.BitsAllocated + 7) / 8
+}
+
+// FrameLength is the number of bytes in each frame of native pixel data.
+func (ip ImagePixel) FrameLength() int {
+	return ip.Rows * ip.Columns * ip.SamplesPerPixel * ip.bytesPerSample()
+}
+
+// Rescale converts stored values to output units (eg, Hounsfield units).
+// See PS 3.3, C.11.1.1.2.
+type Rescale struct {
+	Slope     float64
+	Intercept float64
+}
+
+// GetRescale reads RescaleSlope and RescaleIntercept from an object.
+// If they are absent, the rescale does nothing.
+func GetRescale(obj Object) (r Rescale, err error) {
+	if r.Slope, err = getDecimal(obj, RescaleSlope, 1); err != nil {
+		return r, err
+	}
+
+	r.Intercept, err = getDecimal(obj, RescaleIntercept, 0)
+	return r, err
+}
+
+func (r Rescale) Apply(value float64) float64 {
+	return value*r.Slope + r.Intercept
+}
+
+// Pixels gives access to the frames of native pixel data.
+type Pixels struct {
+	ImagePixel
+	Rescale Rescale
+	data    []byte
+	order   binary.ByteOrder
+}
+
+// GetPixels reads native pixel data and the attributes describing it from
+// an object.  Encapsulated pixel data has to be decoded first (see DecodeRLE).
+func GetPixels(obj Object) (*Pixels, error) {
+	el := obj.Get(PixelData)
+	if el == nil {
+		return nil, errors.New("object has no pixel data")
+	}
+
+	se, ok := (*el).(SimpleElement)
+	if !ok {
+		return nil, fmt.Errorf("pixel data is not native: %s", *el)
+	}
+
+	ip, err := GetImagePixel(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip.BitsAllocated != 8 && ip.BitsAllocated != 16 {
+		return nil, fmt.Errorf("unsupported bits allocated: %d",
+			ip.BitsAllocated)
+	}
+
+	if ip.BitsStored < 1 || ip.BitsStored > ip.BitsAllocated ||
+		ip.HighBit < ip.BitsStored-1 || ip.HighBit >= ip.BitsAllocated {
+		return nil, fmt.Errorf("invalid bits stored (%d) or high bit (%d)",
+			ip.BitsStored, ip.HighBit)
+	}
+
+	if need := ip.FrameLength() * ip.frames(); len(se.Data) < need {
+		return nil, fmt.Errorf("expected %d bytes of pixel data, found %d",
+			need, len(se.Data))
+	}
+
+	rescale, err := GetRescale(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pixels{ip, rescale, se.Data, se.order()}, nil
+}
+
+// Frame returns the raw data of a frame.
+func (px *Pixels) Frame(frame int) ([]byte, error) {
+	if frame < 0 || frame >= px.frames() {
+		return nil, fmt.Errorf("frame %d out of range (%d frames)",
+			frame, px.frames())
+	}
+
+	frameLen := px.FrameLength()
+	return px.data[frame*frameLen : (frame+1)*frameLen], nil
+}
+
+// The typed frame accessors return every sample of the frame, in the order
+// given by PlanarConfiguration.  Bits outside of BitsStored are masked off
+// (eg, overlays in the high bits).
+
+// Uint8Frame returns the samples of a frame with 8 bits allocated.
+func (px *Pixels) Uint8Frame(frame int) ([]uint8, error) {
+	if px.BitsAllocated != 8 {
+		return nil, fmt.Errorf("cannot read %d bits allocated as uint8",
+			px.BitsAllocated)
+	}
+
+	raw, err := px.Frame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]uint8, len(raw))
+	for i, value := range raw {
+		samples[i] = uint8(px.unsigned(uint16(value)))
+	}
+
+	return samples, nil
+}
+
+// Uint16Frame returns the samples of a frame with 8 or 16 bits allocated.
+func (px *Pixels) Uint16Frame(frame int) ([]uint16, error) {
+	raw, err := px.Frame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]uint16, len(raw)/px.bytesPerSample())
+	for i := range samples {
+		samples[i] = px.unsigned(px.raw(raw, i))
+	}
+
+	return samples, nil
+}
+
+// Int16Frame returns the samples of a frame with 8 or 16 bits allocated,
+// sign-extended if PixelRepresentation is signed.
+func (px *Pixels) Int16Frame(frame int) ([]int16, error) {
+	raw, err := px.Frame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]int16, len(raw)/px.bytesPerSample())
+	for i := range samples {
+		samples[i] = px.signed(px.raw(raw, i))
+	}
+
+	return samples, nil
+}
+
+// RescaledFrame returns the samples of a frame after applying the rescale.
+func (px *Pixels) RescaledFrame(frame int) ([]float64, error) {
+	samples, err := px.Int16Frame(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	rescaled := make([]float64, len(samples))
+	for i, sample := range samples {
+		if px.PixelRepresentation == 0 {
+			// int16 could overflow for 16 bits stored
+			rescaled[i] = px.Rescale.Apply(float64(uint16(sample)))
+		} else {
+			rescaled[i] = px.Rescale.Apply(float64(sample))
+		}
+	}
+
+	return rescaled, nil
+}
+
+func (px *Pixels) raw(frame []byte, i int) uint16 {
+	if px.BitsAllocated == 8 {
+		return uint16(frame[i])
+	}
+
+	return px.order.Uint16(frame[i*2:])
+}
+
+// unsigned extracts the stored bits of a sample
+func (px *Pixels) unsigned(value uint16) uint16 {
+	shift := uint(px.HighBit + 1 - px.BitsStored)
+	mask := uint16(1<<uint(px.BitsStored) - 1)
+	return (value >> shift) & mask
+}
+
+// signed extracts the stored bits of a sample, sign-extending them if the
+// pixel representation calls for it
+func (px *Pixels) signed(value uint16) int16 {
+	value = px.unsigned(value)
+	if px.PixelRepresentation == 0 {
+		return int16(value)
+	}
+
+	// move the sign bit to the top, then shift back to extend it
+	unused := uint(16 - px.BitsStored)
+	return int16(value<<unused) >> unused
+}
+
+// getUS reads a single US value, or returns def if the tag is absent.
+func getUS(obj Object, tag Tag, def int) (int, error) {
+	values, err := obj.Ints(tag)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read %s: %s", tag, err)
+	}
+
+	if len(values) == 0 {
+		if def < 0 {
+			return 0, fmt.Errorf("object is missing required %s", tag)
+		}
+		return def, nil
+	}
+
+	return int(values[0]), nil
+}
+
+// getDecimal reads the first value of a DS or IS, or returns def if the tag
+// is absent or empty.
+func getDecimal(obj Object, tag Tag, def float64) (float64, error) {
+	values, err := obj.Floats(tag)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read %s: %s", tag, err)
+	}
+
+	if len(values) == 0 {
+		return def, nil
+	}
+
+	return values[0], nil
+}
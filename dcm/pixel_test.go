@@ -0,0 +1,169 @@
+package dcm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func us(tag Tag, value uint16) SimpleElement {
+	return SimpleElement{
+		Tag:  tag,
+		VR:   US,
+		Data: []byte{byte(value), byte(value >> 8)},
+	}
+}
+
+func pixelObject(bitsAllocated, bitsStored, highBit, representation uint16,
+	data []byte) Object {
+	obj := NewObject()
+	obj.Put(us(Rows, 2))
+	obj.Put(us(Columns, 2))
+	obj.Put(us(BitsAllocated, bitsAllocated))
+	obj.Put(us(BitsStored, bitsStored))
+	obj.Put(us(HighBit, highBit))
+	obj.Put(us(PixelRepresentation, representation))
+	obj.Put(SimpleElement{Tag: PixelData, VR: OW, Data: data})
+	return obj
+}
+
+func TestGetImagePixelDefaults(t *testing.T) {
+	obj := NewObject()
+	obj.Put(us(Rows, 512))
+	obj.Put(us(Columns, 256))
+	obj.Put(us(BitsAllocated, 16))
+	obj.Put(SimpleElement{Tag: NumberOfFrames, VR: IS, Data: []byte("3 ")})
+
+	ip, err := GetImagePixel(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := ImagePixel{
+		Rows:            512,
+		Columns:         256,
+		SamplesPerPixel: 1,
+		BitsAllocated:   16,
+		BitsStored:      16,
+		HighBit:         15,
+		NumberOfFrames:  3,
+	}
+	if ip != exp {
+		t.Fatalf("unexpected image pixel: %+v", ip)
+	}
+	if ip.FrameLength() != 512*256*2 {
+		t.Fatalf("unexpected frame length: %d", ip.FrameLength())
+	}
+
+	if _, err := GetImagePixel(NewObject()); err == nil {
+		t.Fatal("expected error for missing rows")
+	}
+}
+
+func TestUint16Frame(t *testing.T) {
+	// 12 bits stored, with junk in the high bits:
+	px, err := GetPixels(pixelObject(16, 12, 11, 0, []byte{
+		0x01, 0x00, 0xFF, 0x0F, 0x00, 0xF8, 0x34, 0x12,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := px.Uint16Frame(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []uint16{0x001, 0xFFF, 0x800, 0x234}; !reflect.DeepEqual(exp, samples) {
+		t.Fatalf("unexpected samples: %X", samples)
+	}
+
+	if _, err := px.Uint16Frame(1); err == nil {
+		t.Fatal("expected error for missing frame")
+	}
+
+	if _, err := px.Uint8Frame(0); err == nil {
+		t.Fatal("expected error reading 16 bits as uint8")
+	}
+}
+
+func TestInt16Frame(t *testing.T) {
+	px, err := GetPixels(pixelObject(16, 12, 11, 1, []byte{
+		0x01, 0x00, 0xFF, 0x0F, 0x00, 0xF8, 0xFF, 0x07,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := px.Int16Frame(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []int16{1, -1, -2048, 2047}; !reflect.DeepEqual(exp, samples) {
+		t.Fatalf("unexpected samples: %d", samples)
+	}
+}
+
+func TestUint8Frame(t *testing.T) {
+	obj := pixelObject(8, 8, 7, 0, []byte{
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+	})
+	obj.Put(SimpleElement{Tag: NumberOfFrames, VR: IS, Data: []byte("2 ")})
+
+	px, err := GetPixels(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := px.Uint8Frame(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []uint8{5, 6, 7, 8}; !reflect.DeepEqual(exp, samples) {
+		t.Fatalf("unexpected samples: %d", samples)
+	}
+}
+
+func TestRescaledFrame(t *testing.T) {
+	obj := pixelObject(16, 16, 15, 0, []byte{
+		0x00, 0x00, 0x00, 0x04, 0x00, 0x80, 0xFF, 0xFF,
+	})
+	obj.Put(SimpleElement{Tag: RescaleSlope, VR: DS, Data: []byte("2 ")})
+	obj.Put(SimpleElement{Tag: RescaleIntercept, VR: DS, Data: []byte("-1024")})
+
+	px, err := GetPixels(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := px.RescaledFrame(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []float64{-1024, 1024, 64512, 130046}
+	if !reflect.DeepEqual(exp, values) {
+		t.Fatalf("unexpected values: %v", values)
+	}
+}
+
+func TestGetPixelsErrors(t *testing.T) {
+	short := pixelObject(16, 16, 15, 0, []byte{0x00, 0x00})
+	if _, err := GetPixels(short); err == nil {
+		t.Error("expected error for short pixel data")
+	}
+
+	badBits := pixelObject(16, 12, 15, 0, make([]byte, 8))
+	badBits.Put(us(HighBit, 16))
+	if _, err := GetPixels(badBits); err == nil {
+		t.Error("expected error for high bit out of range")
+	}
+
+	encapsulated := pixelObject(8, 8, 7, 0, nil)
+	encapsulated.Put(EncapsulatedElement{Tag: PixelData, VR: OB})
+	if _, err := GetPixels(encapsulated); err == nil {
+		t.Error("expected error for encapsulated pixel data")
+	}
+}
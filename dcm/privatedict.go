@@ -0,0 +1,158 @@
+package dcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Loading of private dictionaries at runtime, eg, for vendor specific
+// elements.
+//
+// The format is a JSON array of elements:
+//
+//	[
+//	  {
+//	    "creator": "GEMS_ACQU_01",
+//	    "tag": "(0019,xx0C)",
+//	    "vr": "US",
+//	    "vm": "1",
+//	    "keyword": "RouteOfAdministration",
+//	    "description": "Route of Administration"
+//	  }
+//	]
+//
+// The block of the element (the xx above) is ignored, since it depends on
+// where the creator is reserved in each data set.  See NewDataDictionary.
+
+// privateElement is an element of a private dictionary file
+type privateElement struct {
+	Creator     string `json:"creator"`
+	Tag         string `json:"tag"`
+	VR          string `json:"vr"`
+	VM          string `json:"vm"`
+	Keyword     string `json:"keyword"`
+	Description string `json:"description"`
+}
+
+// LoadPrivateDictionaries reads private dictionaries in the format above and
+// registers them for their private creators.  Elements of a creator that
+// already has a dictionary are added to it.
+func LoadPrivateDictionaries(in io.Reader) error {
+	var elements []privateElement
+	if err := json.NewDecoder(in).Decode(&elements); err != nil {
+		return fmt.Errorf("unable to read private dictionary: %s", err)
+	}
+
+	specs := make(map[string]map[Tag]ElementSpec)
+	for _, element := range elements {
+		if element.Creator == "" {
+			return fmt.Errorf("no private creator for %s", element.Tag)
+		}
+
+		spec, err := element.spec()
+		if err != nil {
+			return fmt.Errorf("invalid element %s of %s: %s",
+				element.Tag, element.Creator, err)
+		}
+
+		if specs[element.Creator] == nil {
+			specs[element.Creator] = make(map[Tag]ElementSpec)
+		}
+		specs[element.Creator][spec.tag] = spec
+	}
+
+	for creator, creatorSpecs := range specs {
+		// nb: the existing dictionary is left alone, in case it is
+		// being used
+		if existing := GetPrivateDictionary(creator); existing != nil {
+			for tag, spec := range existing.specsByTag {
+				if _, ok := creatorSpecs[tag]; !ok {
+					creatorSpecs[tag] = spec
+				}
+			}
+		}
+
+		NewDataDictionary(creator, creatorSpecs)
+	}
+
+	return nil
+}
+
+func (pe privateElement) spec() (spec ElementSpec, err error) {
+	tag, err := parsePrivateTag(pe.Tag)
+	if err != nil {
+		return spec, err
+	}
+
+	vr := GetVRByName(pe.VR)
+	if vr.Name != pe.VR {
+		return spec, fmt.Errorf("unknown vr %q", pe.VR)
+	}
+
+	minVM, maxVM, err := parseVM(pe.VM)
+	if err != nil {
+		return spec, err
+	}
+
+	return ElementSpec{
+		tag:      tag,
+		maxValue: tag,
+		vr:       *vr,
+		minVM:    minVM,
+		maxVM:    maxVM,
+		desc:     pe.Description,
+		keyword:  pe.Keyword,
+	}, nil
+}
+
+// parsePrivateTag parses a tag like (gggg,xxee) into (gggg,00ee)
+func parsePrivateTag(str string) (Tag, error) {
+	hex := strings.NewReplacer("(", "", ")", "", ",", "").Replace(str)
+	if len(hex) != 8 {
+		return 0, fmt.Errorf("invalid tag %q", str)
+	}
+
+	hex = hex[:4] + "00" + hex[6:]
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tag %q", str)
+	}
+
+	tag := Tag(value)
+	if !tag.IsPrivate() {
+		return 0, fmt.Errorf("tag %s is not private", tag)
+	}
+
+	return tag, nil
+}
+
+// parseVM parses a value multiplicity like 1, 1-3, 1-n or 2-2n.
+// An unbounded maximum is -1, and an empty string leaves both unspecified.
+func parseVM(str string) (min, max int, err error) {
+	if str == "" {
+		return 0, 0, nil
+	}
+
+	parts := strings.SplitN(str, "-", 2)
+
+	if min, err = strconv.Atoi(parts[0]); err != nil || min < 0 {
+		return 0, 0, fmt.Errorf("invalid vm %q", str)
+	}
+
+	if len(parts) == 1 {
+		return min, min, nil
+	}
+
+	if strings.HasSuffix(parts[1], "n") {
+		return min, -1, nil
+	}
+
+	if max, err = strconv.Atoi(parts[1]); err != nil || max < min {
+		return 0, 0, fmt.Errorf("invalid vm %q", str)
+	}
+
+	return min, max, nil
+}
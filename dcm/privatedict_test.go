@@ -0,0 +1,71 @@
+package dcm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadPrivateDictionaries(t *testing.T) {
+	err := LoadPrivateDictionaries(strings.NewReader(`[
+		{"creator": "TEST LOAD 1", "tag": "(0019,xx0C)", "vr": "US",
+		 "vm": "1", "keyword": "RouteOfAdministration",
+		 "description": "Route of Administration"},
+		{"creator": "TEST LOAD 1", "tag": "0019xx10", "vr": "DS",
+		 "vm": "1-n", "keyword": "Doses", "description": "Doses"},
+		{"creator": "TEST LOAD 2", "tag": "(0029,xx08)", "vr": "CS",
+		 "vm": "1", "keyword": "HeaderType", "description": "Header Type"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more elements for an existing creator:
+	err = LoadPrivateDictionaries(strings.NewReader(`[
+		{"creator": "TEST LOAD 2", "tag": "(0029,xx10)", "vr": "OB",
+		 "keyword": "HeaderInfo", "description": "Header Info"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		creator string
+		tag     Tag
+		vr      VR
+		desc    string
+	}{
+		{"TEST LOAD 1", Tag(0x0019100C), US, "Route of Administration"},
+		{"TEST LOAD 1", Tag(0x00194210), DS, "Doses"},
+		{"TEST LOAD 2", Tag(0x00291008), CS, "Header Type"},
+		{"TEST LOAD 2", Tag(0x00291010), OB, "Header Info"},
+	} {
+		spec := SpecForTag(test.creator, test.tag)
+		if spec == nil {
+			t.Errorf("%s %s: not found", test.creator, test.tag)
+			continue
+		}
+
+		if spec.vr != test.vr || spec.GetDesc() != test.desc {
+			t.Errorf("%s %s: unexpected spec %+v", test.creator, test.tag, spec)
+		}
+	}
+
+	if spec := SpecForTag("TEST LOAD 1", Tag(0x00191010)); spec.minVM != 1 || spec.maxVM != -1 {
+		t.Errorf("unexpected vm %d-%d", spec.minVM, spec.maxVM)
+	}
+}
+
+func TestLoadPrivateDictionariesErrors(t *testing.T) {
+	for _, dict := range []string{
+		`{}`,
+		`[{"tag": "(0019,xx0C)", "vr": "US"}]`,
+		`[{"creator": "TEST", "tag": "(0018,xx0C)", "vr": "US"}]`,
+		`[{"creator": "TEST", "tag": "(0019,xx0)", "vr": "US"}]`,
+		`[{"creator": "TEST", "tag": "(0019,xx0C)", "vr": "XX"}]`,
+		`[{"creator": "TEST", "tag": "(0019,xx0C)", "vr": "US", "vm": "3-1"}]`,
+	} {
+		if err := LoadPrivateDictionaries(strings.NewReader(dict)); err == nil {
+			t.Errorf("expected error for %s", dict)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package dcm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// RLE Lossless compression of pixel data.  See PS 3.5, Annex G.
+
+const (
+	rleHeaderLen   = 64
+	rleMaxSegments = 15
+	rleMaxRun      = 128
+)
+
+// rleSegmentIndex gives the position in native data of the given byte of the
+// given pixel and sample, where byte 0 is the most significant (which is the
+// order of the segments).  Native data is little endian.
+func (ip ImagePixel) rleSegmentIndex(pixel, sample, b int) int {
+	bytes := ip.bytesPerSample()
+	if ip.PlanarConfiguration == 0 {
+		return (pixel*ip.SamplesPerPixel+sample)*bytes + (bytes - 1 - b)
+	}
+
+	pixels := ip.Rows * ip.Columns
+	return (sample*pixels+pixel)*bytes + (bytes - 1 - b)
+}
+
+func (ip ImagePixel) rleSegments() (int, error) {
+	if ip.BitsAllocated%8 != 0 {
+		return 0, fmt.Errorf("RLE does not support %d bits allocated",
+			ip.BitsAllocated)
+	}
+
+	segments := ip.SamplesPerPixel * ip.bytesPerSample()
+	if segments < 1 || segments > rleMaxSegments {
+		return 0, fmt.Errorf("RLE does not support %d samples of %d bits",
+			ip.SamplesPerPixel, ip.BitsAllocated)
+	}
+
+	return segments, nil
+}
+
+// DecodeRLE decompresses RLE Lossless pixel data into native, little endian
+// pixel data.
+func DecodeRLE(el EncapsulatedElement, ip ImagePixel) (SimpleElement, error) {
+	segments, err := ip.rleSegments()
+	if err != nil {
+		return SimpleElement{}, err
+	}
+
+	frames, err := el.Frames(ip.frames())
+	if err != nil {
+		return SimpleElement{}, err
+	}
+
+	frameLen := ip.FrameLength()
+	data := make([]byte, frameLen*len(frames))
+
+	for i, frame := range frames {
+		err = ip.decodeRLEFrame(frame, segments, data[i*frameLen:(i+1)*frameLen])
+		if err != nil {
+			return SimpleElement{}, fmt.Errorf("frame %d: %s", i, err)
+		}
+	}
+
+	vr := OB
+	if ip.BitsAllocated > 8 {
+		vr = OW
+	}
+
+	return SimpleElement{Tag: el.Tag, VR: vr, Data: data}, nil
+}
+
+func (ip ImagePixel) decodeRLEFrame(frame []byte, segments int, dst []byte) error {
+	if len(frame) < rleHeaderLen {
+		return errors.New("RLE header is truncated")
+	}
+
+	// RLE is always little endian
+	if got := int(binary.LittleEndian.Uint32(frame)); got != segments {
+		return fmt.Errorf("expected %d RLE segments, found %d", segments, got)
+	}
+
+	// the segments follow the header in order, within the frame
+	offsets := make([]int, segments+1)
+	for i := 0; i < segments; i++ {
+		offsets[i] = int(binary.LittleEndian.Uint32(frame[4+i*4:]))
+
+		previous := rleHeaderLen
+		if i > 0 {
+			previous = offsets[i-1]
+		}
+		if offsets[i] < previous || offsets[i] > len(frame) {
+			return fmt.Errorf("invalid offset for RLE segment %d: %d",
+				i, offsets[i])
+		}
+	}
+	offsets[segments] = len(frame)
+
+	pixels := ip.Rows * ip.Columns
+	plane := make([]byte, pixels)
+
+	for s := 0; s < segments; s++ {
+		err := decodeRLESegment(frame[offsets[s]:offsets[s+1]], plane)
+		if err != nil {
+			return fmt.Errorf("segment %d: %s", s, err)
+		}
+
+		sample, b := s/ip.bytesPerSample(), s%ip.bytesPerSample()
+		for p, value := range plane {
+			dst[ip.rleSegmentIndex(p, sample, b)] = value
+		}
+	}
+
+	return nil
+}
+
+// decodeRLESegment decodes a PackBits-style segment until dst is full.
+func decodeRLESegment(src, dst []byte) error {
+	var in, out int
+
+	for out < len(dst) {
+		if in >= len(src) {
+			return fmt.Errorf("data ran out after %d of %d bytes",
+				out, len(dst))
+		}
+
+		n := int8(src[in])
+		in++
+
+		switch {
+		case n >= 0:
+			count := int(n) + 1
+			if in+count > len(src) || out+count > len(dst) {
+				return errors.New("literal run overflows segment")
+			}
+
+			copy(dst[out:], src[in:in+count])
+			in += count
+			out += count
+
+		case n != -128:
+			count := 1 - int(n)
+			if in >= len(src) || out+count > len(dst) {
+				return errors.New("replicate run overflows segment")
+			}
+
+			for i := 0; i < count; i++ {
+				dst[out+i] = src[in]
+			}
+			in++
+			out += count
+
+			// -128 is a no-op
+		}
+	}
+
+	return nil
+}
+
+// EncodeRLE compresses native pixel data with RLE Lossless.
+// Each frame is written as a single fragment, and the basic offset table is
+// filled in.
+func EncodeRLE(el SimpleElement, ip ImagePixel) (EncapsulatedElement, error) {
+	segments, err := ip.rleSegments()
+	if err != nil {
+		return EncapsulatedElement{}, err
+	}
+
+	// the segments are defined in terms of little endian data
+	el = el.WithByteOrder(nil)
+
+	frameLen := ip.FrameLength()
+	frames := ip.frames()
+	if len(el.Data) < frameLen*frames {
+		return EncapsulatedElement{}, fmt.Errorf("expected %d bytes of "+
+			"pixel data, found %d", frameLen*frames, len(el.Data))
+	}
+
+	table := make([]byte, 4*frames)
+	fragments := [][]byte{table}
+	var offset uint32
+
+	for i := 0; i < frames; i++ {
+		frame := ip.encodeRLEFrame(el.Data[i*frameLen:(i+1)*frameLen], segments)
+		fragments = append(fragments, frame)
+
+		binary.LittleEndian.PutUint32(table[i*4:], offset)
+		offset += fragmentHeaderLen + uint32(len(frame))
+	}
+
+	return EncapsulatedElement{Tag: el.Tag, VR: OB, Data: fragments}, nil
+}
+
+func (ip ImagePixel) encodeRLEFrame(src []byte, segments int) []byte {
+	frame := make([]byte, rleHeaderLen)
+	binary.LittleEndian.PutUint32(frame, uint32(segments))
+
+	row := make([]byte, ip.Columns)
+
+	for s := 0; s < segments; s++ {
+		binary.LittleEndian.PutUint32(frame[4+s*4:], uint32(len(frame)))
+
+		sample, b := s/ip.bytesPerSample(), s%ip.bytesPerSample()
+		for r := 0; r < ip.Rows; r++ {
+			for c := range row {
+				row[c] = src[ip.rleSegmentIndex(r*ip.Columns+c, sample, b)]
+			}
+
+			// runs may not cross rows
+			frame = encodeRLERow(frame, row)
+		}
+
+		if len(frame)%2 != 0 {
+			frame = append(frame, 0)
+		}
+	}
+
+	return frame
+}
+
+// encodeRLERow appends the PackBits-style encoding of src to dst.
+func encodeRLERow(dst, src []byte) []byte {
+	for len(src) > 0 {
+		run := 1
+		for run < len(src) && run < rleMaxRun && src[run] == src[0] {
+			run++
+		}
+
+		if run > 2 {
+			dst = append(dst, byte(1-run), src[0])
+			src = src[run:]
+			continue
+		}
+
+		// a literal goes until the next run of at least 3
+		literal := 0
+		for literal < len(src) && literal < rleMaxRun {
+			if literal+2 < len(src) &&
+				src[literal] == src[literal+1] &&
+				src[literal] == src[literal+2] {
+				break
+			}
+			literal++
+		}
+
+		dst = append(dst, byte(literal-1))
+		dst = append(dst, src[:literal]...)
+		src = src[literal:]
+	}
+
+	return dst
+}
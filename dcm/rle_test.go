@@ -0,0 +1,153 @@
+package dcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeRLESegment(t *testing.T) {
+	src := []byte{
+		0x02, 'a', 'b', 'c', // literal
+		0xFE, 'd', // replicate x3
+		0x80,      // no-op
+		0x00, 'e', // literal
+	}
+
+	dst := make([]byte, 7)
+	if err := decodeRLESegment(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != "abcddde" {
+		t.Fatalf("unexpected decoding: %q", got)
+	}
+
+	if err := decodeRLESegment(src, make([]byte, 8)); err == nil {
+		t.Fatal("expected error for truncated segment")
+	}
+}
+
+func TestEncodeRLERow(t *testing.T) {
+	for _, test := range []struct {
+		row string
+		exp string
+	}{
+		{"abc", "\x02abc"},
+		{"aaaa", "\xFDa"},
+		{"abbbc", "\x00a\xFEb\x00c"},
+		{"aab", "\x02aab"},
+		{string(bytes.Repeat([]byte{'x'}, 130)), "\x81x\x01xx"},
+	} {
+		if got := string(encodeRLERow(nil, []byte(test.row))); got != test.exp {
+			t.Errorf("unexpected encoding of %q: %q (expected %q)",
+				test.row, got, test.exp)
+		}
+	}
+}
+
+func TestRLERoundTrip(t *testing.T) {
+	for _, ip := range []ImagePixel{
+		{Rows: 3, Columns: 5, SamplesPerPixel: 1, BitsAllocated: 8},
+		{Rows: 4, Columns: 3, SamplesPerPixel: 1, BitsAllocated: 16},
+		{Rows: 2, Columns: 4, SamplesPerPixel: 3, BitsAllocated: 8},
+		{Rows: 2, Columns: 4, SamplesPerPixel: 3, BitsAllocated: 8,
+			PlanarConfiguration: 1},
+		{Rows: 2, Columns: 2, SamplesPerPixel: 1, BitsAllocated: 32,
+			NumberOfFrames: 3},
+	} {
+		native := make([]byte, ip.FrameLength()*ip.frames())
+		for i := range native {
+			// some runs, some not
+			native[i] = byte(i / 3 % 7)
+		}
+
+		encoded, err := EncodeRLE(SimpleElement{
+			Tag:  PixelData,
+			VR:   OW,
+			Data: native,
+		}, ip)
+		if err != nil {
+			t.Fatalf("%+v: %s", ip, err)
+		}
+
+		if len(encoded.Data) != ip.frames()+1 {
+			t.Fatalf("%+v: unexpected number of fragments: %d",
+				ip, len(encoded.Data))
+		}
+
+		decoded, err := DecodeRLE(encoded, ip)
+		if err != nil {
+			t.Fatalf("%+v: %s", ip, err)
+		}
+
+		if !bytes.Equal(native, decoded.Data) {
+			t.Errorf("%+v: round trip failed:\n% X\n% X",
+				ip, native, decoded.Data)
+		}
+	}
+}
+
+func TestDecodeRLESegmentOrder(t *testing.T) {
+	// 16 bit, 2 pixels: 0x0102, 0x0304
+	frame := make([]byte, rleHeaderLen)
+	frame[0] = 2
+	frame[4] = rleHeaderLen
+	frame[8] = rleHeaderLen + 4
+	frame = append(frame,
+		0x01, 0x01, 0x03, 0x00, // high bytes
+		0x01, 0x02, 0x04, 0x00) // low bytes
+
+	el := EncapsulatedElement{
+		Tag:  PixelData,
+		VR:   OB,
+		Data: [][]byte{nil, frame},
+	}
+
+	decoded, err := DecodeRLE(el, ImagePixel{
+		Rows: 1, Columns: 2, SamplesPerPixel: 1, BitsAllocated: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []byte{0x02, 0x01, 0x04, 0x03}
+	if !bytes.Equal(exp, decoded.Data) {
+		t.Fatalf("unexpected decoding: % X", decoded.Data)
+	}
+	if decoded.VR != OW {
+		t.Fatalf("unexpected vr: %s", decoded.VR)
+	}
+}
+
+func TestDecodeRLEInvalidOffsets(t *testing.T) {
+	ip := ImagePixel{Rows: 1, Columns: 2, SamplesPerPixel: 1, BitsAllocated: 16}
+
+	for _, offsets := range [][2]uint32{
+		{0, rleHeaderLen},                // inside the header
+		{rleHeaderLen + 8, rleHeaderLen}, // decreasing
+		{rleHeaderLen, 10000},            // past the end of the frame
+	} {
+		frame := make([]byte, rleHeaderLen+16)
+		frame[0] = 2
+		binary.LittleEndian.PutUint32(frame[4:], offsets[0])
+		binary.LittleEndian.PutUint32(frame[8:], offsets[1])
+
+		el := EncapsulatedElement{Tag: PixelData, VR: OB, Data: [][]byte{nil, frame}}
+		if _, err := DecodeRLE(el, ip); err == nil {
+			t.Errorf("expected error for offsets %v", offsets)
+		}
+	}
+}
+
+func TestRLEUnsupported(t *testing.T) {
+	for _, ip := range []ImagePixel{
+		{Rows: 1, Columns: 1, SamplesPerPixel: 1, BitsAllocated: 1},
+		{Rows: 1, Columns: 1, SamplesPerPixel: 4, BitsAllocated: 32},
+	} {
+		_, err := EncodeRLE(SimpleElement{Tag: PixelData, VR: OB,
+			Data: make([]byte, 16)}, ip)
+		if err == nil {
+			t.Errorf("%+v: expected error", ip)
+		}
+	}
+}
@@ -4,52 +4,52 @@ package dcm
 
 var stddict = NewDataDictionary("",
 	map[Tag]ElementSpec{
-		Tag(0x00000000): {tag: Tag(0x00000000), maxValue: Tag(0x00000000), vr: UL, retired: false, desc: "Command Group Length", keyword: "CommandGroupLength"},
-		Tag(0x00000001): {tag: Tag(0x00000001), maxValue: Tag(0x00000001), vr: UL, retired: false, desc: "Command Length to End", keyword: "CommandLengthToEnd"},
-		Tag(0x00000002): {tag: Tag(0x00000002), maxValue: Tag(0x00000002), vr: UI, retired: false, desc: "Affected SOP Class UID", keyword: "AffectedSOPClassUID"},
-		Tag(0x00000003): {tag: Tag(0x00000003), maxValue: Tag(0x00000003), vr: UI, retired: false, desc: "Requested SOP Class UID", keyword: "RequestedSOPClassUID"},
-		Tag(0x00000010): {tag: Tag(0x00000010), maxValue: Tag(0x00000010), vr: SH, retired: false, desc: "Command Recognition Code", keyword: "CommandRecognitionCode"},
-		Tag(0x00000100): {tag: Tag(0x00000100), maxValue: Tag(0x00000100), vr: US, retired: false, desc: "Command Field", keyword: "CommandField"},
-		Tag(0x00000110): {tag: Tag(0x00000110), maxValue: Tag(0x00000110), vr: US, retired: false, desc: "Message ID", keyword: "MessageID"},
-		Tag(0x00000120): {tag: Tag(0x00000120), maxValue: Tag(0x00000120), vr: US, retired: false, desc: "Message ID Being Responded To", keyword: "MessageIDBeingRespondedTo"},
-		Tag(0x00000200): {tag: Tag(0x00000200), maxValue: Tag(0x00000200), vr: AE, retired: false, desc: "Initiator", keyword: "Initiator"},
-		Tag(0x00000300): {tag: Tag(0x00000300), maxValue: Tag(0x00000300), vr: AE, retired: false, desc: "Receiver", keyword: "Receiver"},
-		Tag(0x00000400): {tag: Tag(0x00000400), maxValue: Tag(0x00000400), vr: AE, retired: false, desc: "Find Location", keyword: "FindLocation"},
-		Tag(0x00000600): {tag: Tag(0x00000600), maxValue: Tag(0x00000600), vr: AE, retired: false, desc: "Move Destination", keyword: "MoveDestination"},
-		Tag(0x00000700): {tag: Tag(0x00000700), maxValue: Tag(0x00000700), vr: US, retired: false, desc: "Priority", keyword: "Priority"},
-		Tag(0x00000800): {tag: Tag(0x00000800), maxValue: Tag(0x00000800), vr: US, retired: false, desc: "Command Data Set Type", keyword: "CommandDataSetType"},
-		Tag(0x00000850): {tag: Tag(0x00000850), maxValue: Tag(0x00000850), vr: US, retired: false, desc: "Number of Matches", keyword: "NumberOfMatches"},
-		Tag(0x00000860): {tag: Tag(0x00000860), maxValue: Tag(0x00000860), vr: US, retired: false, desc: "Response Sequence Number", keyword: "ResponseSequenceNumber"},
-		Tag(0x00000900): {tag: Tag(0x00000900), maxValue: Tag(0x00000900), vr: US, retired: false, desc: "Status", keyword: "Status"},
-		Tag(0x00000901): {tag: Tag(0x00000901), maxValue: Tag(0x00000901), vr: AT, retired: false, desc: "Offending Element", keyword: "OffendingElement"},
-		Tag(0x00000902): {tag: Tag(0x00000902), maxValue: Tag(0x00000902), vr: LO, retired: false, desc: "Error Comment", keyword: "ErrorComment"},
-		Tag(0x00000903): {tag: Tag(0x00000903), maxValue: Tag(0x00000903), vr: US, retired: false, desc: "Error ID", keyword: "ErrorID"},
-		Tag(0x00001000): {tag: Tag(0x00001000), maxValue: Tag(0x00001000), vr: UI, retired: false, desc: "Affected SOP Instance UID", keyword: "AffectedSOPInstanceUID"},
-		Tag(0x00001001): {tag: Tag(0x00001001), maxValue: Tag(0x00001001), vr: UI, retired: false, desc: "Requested SOP Instance UID", keyword: "RequestedSOPInstanceUID"},
-		Tag(0x00001002): {tag: Tag(0x00001002), maxValue: Tag(0x00001002), vr: US, retired: false, desc: "Event Type ID", keyword: "EventTypeID"},
-		Tag(0x00001005): {tag: Tag(0x00001005), maxValue: Tag(0x00001005), vr: AT, retired: false, desc: "Attribute Identifier List", keyword: "AttributeIdentifierList"},
-		Tag(0x00001008): {tag: Tag(0x00001008), maxValue: Tag(0x00001008), vr: US, retired: false, desc: "Action Type ID", keyword: "ActionTypeID"},
-		Tag(0x00001020): {tag: Tag(0x00001020), maxValue: Tag(0x00001020), vr: US, retired: false, desc: "Number of Remaining Sub-operations", keyword: "NumberOfRemainingSuboperations"},
-		Tag(0x00001021): {tag: Tag(0x00001021), maxValue: Tag(0x00001021), vr: US, retired: false, desc: "Number of Completed Sub-operations", keyword: "NumberOfCompletedSuboperations"},
-		Tag(0x00001022): {tag: Tag(0x00001022), maxValue: Tag(0x00001022), vr: US, retired: false, desc: "Number of Failed Sub-operations", keyword: "NumberOfFailedSuboperations"},
-		Tag(0x00001023): {tag: Tag(0x00001023), maxValue: Tag(0x00001023), vr: US, retired: false, desc: "Number of Warning Sub-operations", keyword: "NumberOfWarningSuboperations"},
-		Tag(0x00001030): {tag: Tag(0x00001030), maxValue: Tag(0x00001030), vr: AE, retired: false, desc: "Move Originator Application Entity Title", keyword: "MoveOriginatorApplicationEntityTitle"},
-		Tag(0x00001031): {tag: Tag(0x00001031), maxValue: Tag(0x00001031), vr: US, retired: false, desc: "Move Originator Message ID", keyword: "MoveOriginatorMessageID"},
-		Tag(0x00004000): {tag: Tag(0x00004000), maxValue: Tag(0x00004000), vr: LT, retired: false, desc: "Dialog Receiver", keyword: "DialogReceiver"},
-		Tag(0x00004010): {tag: Tag(0x00004010), maxValue: Tag(0x00004010), vr: LT, retired: false, desc: "Terminal Type", keyword: "TerminalType"},
-		Tag(0x00005010): {tag: Tag(0x00005010), maxValue: Tag(0x00005010), vr: SH, retired: false, desc: "Message Set ID", keyword: "MessageSetID"},
-		Tag(0x00005020): {tag: Tag(0x00005020), maxValue: Tag(0x00005020), vr: SH, retired: false, desc: "End Message ID", keyword: "EndMessageID"},
-		Tag(0x00005110): {tag: Tag(0x00005110), maxValue: Tag(0x00005110), vr: LT, retired: false, desc: "Display Format", keyword: "DisplayFormat"},
-		Tag(0x00005120): {tag: Tag(0x00005120), maxValue: Tag(0x00005120), vr: LT, retired: false, desc: "Page Position ID", keyword: "PagePositionID"},
-		Tag(0x00005130): {tag: Tag(0x00005130), maxValue: Tag(0x00005130), vr: CS, retired: false, desc: "Text Format ID", keyword: "TextFormatID"},
-		Tag(0x00005140): {tag: Tag(0x00005140), maxValue: Tag(0x00005140), vr: CS, retired: false, desc: "Normal/Reverse", keyword: "NormalReverse"},
-		Tag(0x00005150): {tag: Tag(0x00005150), maxValue: Tag(0x00005150), vr: CS, retired: false, desc: "Add Gray Scale", keyword: "AddGrayScale"},
-		Tag(0x00005160): {tag: Tag(0x00005160), maxValue: Tag(0x00005160), vr: CS, retired: false, desc: "Borders", keyword: "Borders"},
-		Tag(0x00005170): {tag: Tag(0x00005170), maxValue: Tag(0x00005170), vr: IS, retired: false, desc: "Copies", keyword: "Copies"},
-		Tag(0x00005180): {tag: Tag(0x00005180), maxValue: Tag(0x00005180), vr: CS, retired: false, desc: "Command Magnification Type", keyword: "CommandMagnificationType"},
-		Tag(0x00005190): {tag: Tag(0x00005190), maxValue: Tag(0x00005190), vr: CS, retired: false, desc: "Erase", keyword: "Erase"},
-		Tag(0x000051A0): {tag: Tag(0x000051A0), maxValue: Tag(0x000051A0), vr: CS, retired: false, desc: "Print", keyword: "Print"},
-		Tag(0x000051B0): {tag: Tag(0x000051B0), maxValue: Tag(0x000051B0), vr: US, retired: false, desc: "Overlays", keyword: "Overlays"},
+		Tag(0x00000000): {tag: Tag(0x00000000), maxValue: Tag(0x00000000), vr: UL, minVM: 1, maxVM: 1, retired: false, desc: "Command Group Length", keyword: "CommandGroupLength"},
+		Tag(0x00000001): {tag: Tag(0x00000001), maxValue: Tag(0x00000001), vr: UL, minVM: 1, maxVM: 1, retired: true, desc: "Command Length to End", keyword: "CommandLengthToEnd"},
+		Tag(0x00000002): {tag: Tag(0x00000002), maxValue: Tag(0x00000002), vr: UI, minVM: 1, maxVM: 1, retired: false, desc: "Affected SOP Class UID", keyword: "AffectedSOPClassUID"},
+		Tag(0x00000003): {tag: Tag(0x00000003), maxValue: Tag(0x00000003), vr: UI, minVM: 1, maxVM: 1, retired: false, desc: "Requested SOP Class UID", keyword: "RequestedSOPClassUID"},
+		Tag(0x00000010): {tag: Tag(0x00000010), maxValue: Tag(0x00000010), vr: SH, minVM: 1, maxVM: 1, retired: true, desc: "Command Recognition Code", keyword: "CommandRecognitionCode"},
+		Tag(0x00000100): {tag: Tag(0x00000100), maxValue: Tag(0x00000100), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Command Field", keyword: "CommandField"},
+		Tag(0x00000110): {tag: Tag(0x00000110), maxValue: Tag(0x00000110), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Message ID", keyword: "MessageID"},
+		Tag(0x00000120): {tag: Tag(0x00000120), maxValue: Tag(0x00000120), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Message ID Being Responded To", keyword: "MessageIDBeingRespondedTo"},
+		Tag(0x00000200): {tag: Tag(0x00000200), maxValue: Tag(0x00000200), vr: AE, minVM: 1, maxVM: 1, retired: true, desc: "Initiator", keyword: "Initiator"},
+		Tag(0x00000300): {tag: Tag(0x00000300), maxValue: Tag(0x00000300), vr: AE, minVM: 1, maxVM: 1, retired: true, desc: "Receiver", keyword: "Receiver"},
+		Tag(0x00000400): {tag: Tag(0x00000400), maxValue: Tag(0x00000400), vr: AE, minVM: 1, maxVM: 1, retired: true, desc: "Find Location", keyword: "FindLocation"},
+		Tag(0x00000600): {tag: Tag(0x00000600), maxValue: Tag(0x00000600), vr: AE, minVM: 1, maxVM: 1, retired: false, desc: "Move Destination", keyword: "MoveDestination"},
+		Tag(0x00000700): {tag: Tag(0x00000700), maxValue: Tag(0x00000700), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Priority", keyword: "Priority"},
+		Tag(0x00000800): {tag: Tag(0x00000800), maxValue: Tag(0x00000800), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Command Data Set Type", keyword: "CommandDataSetType"},
+		Tag(0x00000850): {tag: Tag(0x00000850), maxValue: Tag(0x00000850), vr: US, minVM: 1, maxVM: 1, retired: true, desc: "Number of Matches", keyword: "NumberOfMatches"},
+		Tag(0x00000860): {tag: Tag(0x00000860), maxValue: Tag(0x00000860), vr: US, minVM: 1, maxVM: 1, retired: true, desc: "Response Sequence Number", keyword: "ResponseSequenceNumber"},
+		Tag(0x00000900): {tag: Tag(0x00000900), maxValue: Tag(0x00000900), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Status", keyword: "Status"},
+		Tag(0x00000901): {tag: Tag(0x00000901), maxValue: Tag(0x00000901), vr: AT, minVM: 1, maxVM: -1, retired: false, desc: "Offending Element", keyword: "OffendingElement"},
+		Tag(0x00000902): {tag: Tag(0x00000902), maxValue: Tag(0x00000902), vr: LO, minVM: 1, maxVM: 1, retired: false, desc: "Error Comment", keyword: "ErrorComment"},
+		Tag(0x00000903): {tag: Tag(0x00000903), maxValue: Tag(0x00000903), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Error ID", keyword: "ErrorID"},
+		Tag(0x00001000): {tag: Tag(0x00001000), maxValue: Tag(0x00001000), vr: UI, minVM: 1, maxVM: 1, retired: false, desc: "Affected SOP Instance UID", keyword: "AffectedSOPInstanceUID"},
+		Tag(0x00001001): {tag: Tag(0x00001001), maxValue: Tag(0x00001001), vr: UI, minVM: 1, maxVM: 1, retired: false, desc: "Requested SOP Instance UID", keyword: "RequestedSOPInstanceUID"},
+		Tag(0x00001002): {tag: Tag(0x00001002), maxValue: Tag(0x00001002), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Event Type ID", keyword: "EventTypeID"},
+		Tag(0x00001005): {tag: Tag(0x00001005), maxValue: Tag(0x00001005), vr: AT, minVM: 1, maxVM: -1, retired: false, desc: "Attribute Identifier List", keyword: "AttributeIdentifierList"},
+		Tag(0x00001008): {tag: Tag(0x00001008), maxValue: Tag(0x00001008), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Action Type ID", keyword: "ActionTypeID"},
+		Tag(0x00001020): {tag: Tag(0x00001020), maxValue: Tag(0x00001020), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Number of Remaining Sub-operations", keyword: "NumberOfRemainingSuboperations"},
+		Tag(0x00001021): {tag: Tag(0x00001021), maxValue: Tag(0x00001021), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Number of Completed Sub-operations", keyword: "NumberOfCompletedSuboperations"},
+		Tag(0x00001022): {tag: Tag(0x00001022), maxValue: Tag(0x00001022), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Number of Failed Sub-operations", keyword: "NumberOfFailedSuboperations"},
+		Tag(0x00001023): {tag: Tag(0x00001023), maxValue: Tag(0x00001023), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Number of Warning Sub-operations", keyword: "NumberOfWarningSuboperations"},
+		Tag(0x00001030): {tag: Tag(0x00001030), maxValue: Tag(0x00001030), vr: AE, minVM: 1, maxVM: 1, retired: false, desc: "Move Originator Application Entity Title", keyword: "MoveOriginatorApplicationEntityTitle"},
+		Tag(0x00001031): {tag: Tag(0x00001031), maxValue: Tag(0x00001031), vr: US, minVM: 1, maxVM: 1, retired: false, desc: "Move Originator Message ID", keyword: "MoveOriginatorMessageID"},
+		Tag(0x00004000): {tag: Tag(0x00004000), maxValue: Tag(0x00004000), vr: LT, minVM: 1, maxVM: 1, retired: true, desc: "Dialog Receiver", keyword: "DialogReceiver"},
+		Tag(0x00004010): {tag: Tag(0x00004010), maxValue: Tag(0x00004010), vr: LT, minVM: 1, maxVM: 1, retired: true, desc: "Terminal Type", keyword: "TerminalType"},
+		Tag(0x00005010): {tag: Tag(0x00005010), maxValue: Tag(0x00005010), vr: SH, minVM: 1, maxVM: 1, retired: true, desc: "Message Set ID", keyword: "MessageSetID"},
+		Tag(0x00005020): {tag: Tag(0x00005020), maxValue: Tag(0x00005020), vr: SH, minVM: 1, maxVM: 1, retired: true, desc: "End Message ID", keyword: "EndMessageID"},
+		Tag(0x00005110): {tag: Tag(0x00005110), maxValue: Tag(0x00005110), vr: LT, minVM: 1, maxVM: 1, retired: true, desc: "Display Format", keyword: "DisplayFormat"},
+		Tag(0x00005120): {tag: Tag(0x00005120), maxValue: Tag(0x00005120), vr: LT, minVM: 1, maxVM: 1, retired: true, desc: "Page Position ID", keyword: "PagePositionID"},
+		Tag(0x00005130): {tag: Tag(0x00005130), maxValue: Tag(0x00005130), vr: CS, minVM: 1, maxVM: 1, retired: true, desc: "Text Format ID", keyword: "TextFormatID"},
+		Tag(0x00005140): {tag: Tag(0x00005140), maxValue: Tag(0x00005140), vr: CS, minVM: 1, maxVM: 1, retired: true, desc: "Normal/Reverse", keyword: "NormalReverse"},
+		Tag(0x00005150): {tag: Tag(0x00005150), maxValue: Tag(0x00005150), vr: CS, minVM: 1, maxVM: 1, retired: true, desc: "Add Gray Scale", keyword: "AddGrayScale"},
+		Tag(0x00005160): {tag: Tag(0x00005160), maxValue: Tag(0x00005160), vr: CS, minVM: 1, maxVM: 1, retired: true, desc: "Borders", keyword: "Borders"},
+		Tag(0x00005170): {tag: Tag(0x00005170), maxValue: Tag(0x00005170), vr: IS, minVM: 1, maxVM: 1, retired: true, desc: "Copies", keyword: "Copies"},
+		Tag(0x00005180): {tag: Tag(0x00005180), maxValue: Tag(0x00005180), vr: CS, minVM: 1, maxVM: 1, retired: true, desc: "Command Magnification Type", keyword: "CommandMagnificationType"},
+		Tag(0x00005190): {tag: Tag(0x00005190), maxValue: Tag(0x00005190), vr: CS, minVM: 1, maxVM: 1, retired: true, desc: "Erase", keyword: "Erase"},
+		Tag(0x000051A0): {tag: Tag(0x000051A0), maxValue: Tag(0x000051A0), vr: CS, minVM: 1, maxVM: 1, retired: true, desc: "Print", keyword: "Print"},
+		Tag(0x000051B0): {tag: Tag(0x000051B0), maxValue: Tag(0x000051B0), vr: US, minVM: 1, maxVM: -1, retired: true, desc: "Overlays", keyword: "Overlays"},
 		Tag(0x00020000): {tag: Tag(0x00020000), maxValue: Tag(0x00020000), vr: UL, retired: false, desc: "File Meta Information Group Length", keyword: "FileMetaInformationGroupLength"},
 		Tag(0x00020001): {tag: Tag(0x00020001), maxValue: Tag(0x00020001), vr: OB, retired: false, desc: "File Meta Information Version", keyword: "FileMetaInformationVersion"},
 		Tag(0x00020002): {tag: Tag(0x00020002), maxValue: Tag(0x00020002), vr: UI, retired: false, desc: "Media Storage SOP Class UID", keyword: "MediaStorageSOPClassUID"},
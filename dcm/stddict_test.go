@@ -4,12 +4,17 @@
 
 package dcm
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestVRForTag(t *testing.T) {
 	privateTag := Tag(0x00010001)
+	privateDataTag := Tag(0x00290001)
 	NewDataDictionary("private", map[Tag]ElementSpec{
-		privateTag: {tag: privateTag, vr: UC},
+		privateTag:     {tag: privateTag, vr: UC},
+		privateDataTag: {tag: privateDataTag, vr: FD},
 	})
 
 	for _, test := range []struct {
@@ -21,6 +26,11 @@ func TestVRForTag(t *testing.T) {
 		{"", privateTag, UN},
 		{"private", privateTag, UC},
 		{"private", PatientID, UN},
+		// the block is ignored for private data elements:
+		{"private", Tag(0x00291001), FD},
+		{"private", Tag(0x00294201), FD},
+		{"", Tag(0x00291001), UN},
+		{"", Tag(0x00290010), LO},
 	} {
 		if vr := VRForTag(test.privateCreatorUID, test.tag); vr != test.vr {
 			t.Errorf("unexpected vr %s for %s/%s",
@@ -29,3 +39,74 @@ func TestVRForTag(t *testing.T) {
 	}
 
 }
+
+func TestSpecForKeyword(t *testing.T) {
+	spec := SpecForKeyword("PatientName")
+	if spec == nil {
+		t.Fatal("PatientName not found")
+	}
+
+	if spec.GetTag() != PatientName || spec.GetVR() != PN ||
+		spec.GetKeyword() != "PatientName" {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+
+	if spec := SpecForKeyword("NotAKeyword"); spec != nil {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+}
+
+func TestSpecForRepeatingTag(t *testing.T) {
+	for _, test := range []struct {
+		tag     Tag
+		keyword string
+	}{
+		{Tag(0x60003000), "OverlayData"},
+		{Tag(0x60023000), "OverlayData"},
+		{Tag(0x601E0010), "OverlayRows"},
+		{Tag(0x50100005), "CurveDimensions"},
+		{Tag(0x002804A1), "ColumnsForNthOrderCoefficients"},
+		{Tag(0x10001230), "EscapeTriplet"},
+		// private, not a repeating group:
+		{Tag(0x60013000), ""},
+		{Tag(0x60023001), ""},
+		{Tag(0x002804A4), ""},
+	} {
+		spec := SpecForTag("", test.tag)
+		if test.keyword == "" {
+			if spec != nil {
+				t.Errorf("%s: unexpected spec %+v", test.tag, spec)
+			}
+		} else if spec == nil || spec.GetKeyword() != test.keyword {
+			t.Errorf("%s: expected %s, got %+v", test.tag, test.keyword, spec)
+		}
+	}
+}
+
+func TestElementSpecVM(t *testing.T) {
+	err := LoadPrivateDictionaries(strings.NewReader(`[
+		{"creator": "TEST VM", "tag": "(0019,xx01)", "vr": "DS", "vm": "2-2n"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spec := SpecForTag("TEST VM", Tag(0x00191001)); spec.minVM != 2 || spec.maxVM != -1 {
+		t.Errorf("unexpected vm %d-%d", spec.minVM, spec.maxVM)
+	}
+}
+
+func TestCommandElementSpecs(t *testing.T) {
+	spec := SpecForTag("", CommandField)
+	if spec == nil {
+		t.Fatal("CommandField not found")
+	}
+	if spec.minVM != 1 || spec.maxVM != 1 || spec.retired {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+
+	spec = SpecForTag("", CommandLengthToEnd)
+	if spec == nil || !spec.retired {
+		t.Errorf("expected CommandLengthToEnd to be retired: %+v", spec)
+	}
+}
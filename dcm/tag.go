@@ -64,7 +64,38 @@ func NewTag(group, element uint16) Tag {
 	return Tag((uint32(group) << 16) + uint32(element))
 }
 
-// TODO:
-// IsPrivateData
-// IsPrivateCreator
-// GetPrivateCreator
+// IsPrivate returns true if the tag is in a private group, which is any odd
+// group except 0001, 0003, 0005, 0007 and FFFF.
+// See PS 3.5, Section 7.8.
+func (t Tag) IsPrivate() bool {
+	switch group := t.Group(); group {
+	case 0x0001, 0x0003, 0x0005, 0x0007, 0xFFFF:
+		return false
+	default:
+		return group%2 == 1
+	}
+}
+
+// IsPrivateCreator returns true if the tag reserves a block of private data
+// elements, that is, if it is (gggg,0010-00FF) in a private group.
+// Private data element (gggg,xx00-xxFF) is reserved by (gggg,00xx).
+func (t Tag) IsPrivateCreator() bool {
+	return t.IsPrivate() && t.Element() >= 0x0010 && t.Element() <= 0x00FF
+}
+
+// IsPrivateData returns true if the tag is a private data element,
+// that is, if it is (gggg,1000-FFFF) in a private group.
+func (t Tag) IsPrivateData() bool {
+	return t.IsPrivate() && t.Element() >= 0x1000
+}
+
+// PrivateCreator returns the tag of the private creator element that
+// reserves the block of a private data element, or false if this isn't
+// a private data element.
+func (t Tag) PrivateCreator() (Tag, bool) {
+	if !t.IsPrivateData() {
+		return 0, false
+	}
+
+	return NewTag(t.Group(), t.Element()>>8), true
+}
@@ -71,3 +71,39 @@ func TestFileMetaInfoElement(t *testing.T) {
 		}
 	}
 }
+
+func TestPrivateTags(t *testing.T) {
+	for _, test := range []struct {
+		tag     Tag
+		private bool
+		creator bool
+		data    bool
+	}{
+		{PatientID, false, false, false},
+		{Tag(0x00010010), false, false, false},
+		{Tag(0x00090000), true, false, false},
+		{Tag(0x00090010), true, true, false},
+		{Tag(0x000900FF), true, true, false},
+		{Tag(0x00090100), true, false, false},
+		{Tag(0x00091000), true, false, true},
+		{Tag(0x0009FFFF), true, false, true},
+	} {
+		if got := test.tag.IsPrivate(); got != test.private {
+			t.Errorf("%s: IsPrivate() = %v", test.tag, got)
+		}
+		if got := test.tag.IsPrivateCreator(); got != test.creator {
+			t.Errorf("%s: IsPrivateCreator() = %v", test.tag, got)
+		}
+		if got := test.tag.IsPrivateData(); got != test.data {
+			t.Errorf("%s: IsPrivateData() = %v", test.tag, got)
+		}
+	}
+
+	if creator, ok := Tag(0x00291234).PrivateCreator(); !ok || creator != Tag(0x00290012) {
+		t.Errorf("unexpected private creator %s, %v", creator, ok)
+	}
+
+	if _, ok := PatientID.PrivateCreator(); ok {
+		t.Error("unexpected private creator for a standard tag")
+	}
+}
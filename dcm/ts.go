@@ -2,6 +2,7 @@ package dcm
 
 import (
 	"encoding/binary"
+	"sort"
 )
 
 type Explicitness bool
@@ -26,26 +27,44 @@ const (
 	Native
 )
 
+type Lossiness bool
+
+const (
+	Lossy    Lossiness = true
+	Lossless Lossiness = false
+)
+
 type TransferSyntax interface {
 	UID() string
+	// Name is human-readable, and empty for unknown syntaxes
+	Name() string
 	ByteOrder() binary.ByteOrder
 	VR() Explicitness
 	Deflation() Deflation
 	PixelStorage() PixelStorage
+	// Lossiness tells whether pixel data may have lost information by
+	// being compressed in this syntax.
+	Lossiness() Lossiness
 }
 
 type transferSyntax struct {
 	uid          string
+	name         string
 	order        binary.ByteOrder
 	vr           Explicitness
 	deflation    Deflation
 	pixelStorage PixelStorage
+	lossiness    Lossiness
 }
 
 func (ts *transferSyntax) UID() string {
 	return ts.uid
 }
 
+func (ts *transferSyntax) Name() string {
+	return ts.name
+}
+
 func (ts *transferSyntax) ByteOrder() binary.ByteOrder {
 	return ts.order
 }
@@ -62,6 +81,18 @@ func (ts *transferSyntax) PixelStorage() PixelStorage {
 	return ts.pixelStorage
 }
 
+func (ts *transferSyntax) Lossiness() Lossiness {
+	return ts.lossiness
+}
+
+func (ts *transferSyntax) String() string {
+	if ts.name == "" {
+		return ts.uid
+	}
+
+	return ts.name
+}
+
 var tsmap = make(map[string]TransferSyntax)
 
 func regts(ts TransferSyntax) TransferSyntax {
@@ -69,43 +100,134 @@ func regts(ts TransferSyntax) TransferSyntax {
 	return ts
 }
 
+// regencapsulated registers a syntax for compressed pixel data.
+// These are all explicit vr little endian for the rest of the data.
+func regencapsulated(uid, name string, lossiness Lossiness) TransferSyntax {
+	return regts(&transferSyntax{
+		uid,
+		name,
+		binary.LittleEndian,
+		Explicit,
+		Inflated,
+		Encapsulated,
+		lossiness,
+	})
+}
+
 // Known transfer syntaxes:
 var (
 	ExplicitVRLittleEndian = regts(&transferSyntax{
 		"1.2.840.10008.1.2.1",
+		"Explicit VR Little Endian",
 		binary.LittleEndian,
 		Explicit,
 		Inflated,
 		Native,
+		Lossless,
 	})
 
 	ImplicitVRLittleEndian = regts(&transferSyntax{
 		"1.2.840.10008.1.2",
+		"Implicit VR Little Endian",
 		binary.LittleEndian,
 		Implicit,
 		Inflated,
 		Native,
+		Lossless,
 	})
 
 	ExplicitVRBigEndian = regts(&transferSyntax{
 		"1.2.840.10008.1.2.2",
+		"Explicit VR Big Endian",
 		binary.BigEndian,
 		Explicit,
 		Inflated,
 		Native,
+		Lossless,
 	})
 
 	ImplicitVRBigEndian = regts(&transferSyntax{
 		"1.2.840.113619.5.2",
+		"Implicit VR Big Endian (GE Private)",
 		binary.BigEndian,
 		Implicit,
 		Inflated,
 		Native,
+		Lossless,
 	})
 
-	// TODO: add other known syntaxes
+	DeflatedExplicitVRLittleEndian = regts(&transferSyntax{
+		"1.2.840.10008.1.2.1.99",
+		"Deflated Explicit VR Little Endian",
+		binary.LittleEndian,
+		Explicit,
+		Deflated,
+		Native,
+		Lossless,
+	})
+
+	JPEGBaseline = regencapsulated("1.2.840.10008.1.2.4.50",
+		"JPEG Baseline (Process 1)", Lossy)
+	JPEGExtended = regencapsulated("1.2.840.10008.1.2.4.51",
+		"JPEG Extended (Process 2 & 4)", Lossy)
+	JPEGLossless = regencapsulated("1.2.840.10008.1.2.4.57",
+		"JPEG Lossless, Non-Hierarchical (Process 14)", Lossless)
+	JPEGLosslessSV1 = regencapsulated("1.2.840.10008.1.2.4.70",
+		"JPEG Lossless, Non-Hierarchical, First-Order Prediction "+
+			"(Process 14 [Selection Value 1])", Lossless)
+
+	JPEGLSLossless = regencapsulated("1.2.840.10008.1.2.4.80",
+		"JPEG-LS Lossless Image Compression", Lossless)
+	JPEGLSNearLossless = regencapsulated("1.2.840.10008.1.2.4.81",
+		"JPEG-LS Lossy (Near-Lossless) Image Compression", Lossy)
+
+	JPEG2000Lossless = regencapsulated("1.2.840.10008.1.2.4.90",
+		"JPEG 2000 Image Compression (Lossless Only)", Lossless)
+	JPEG2000 = regencapsulated("1.2.840.10008.1.2.4.91",
+		"JPEG 2000 Image Compression", Lossy)
+	JPEG2000Part2Lossless = regencapsulated("1.2.840.10008.1.2.4.92",
+		"JPEG 2000 Part 2 Multi-component Image Compression "+
+			"(Lossless Only)", Lossless)
+	JPEG2000Part2 = regencapsulated("1.2.840.10008.1.2.4.93",
+		"JPEG 2000 Part 2 Multi-component Image Compression", Lossy)
+
+	MPEG2MainLevel = regencapsulated("1.2.840.10008.1.2.4.100",
+		"MPEG2 Main Profile / Main Level", Lossy)
+	MPEG2HighLevel = regencapsulated("1.2.840.10008.1.2.4.101",
+		"MPEG2 Main Profile / High Level", Lossy)
+	MPEG4HP41 = regencapsulated("1.2.840.10008.1.2.4.102",
+		"MPEG-4 AVC/H.264 High Profile / Level 4.1", Lossy)
+	MPEG4HP41BD = regencapsulated("1.2.840.10008.1.2.4.103",
+		"MPEG-4 AVC/H.264 BD-compatible High Profile / Level 4.1", Lossy)
+	MPEG4HP422D = regencapsulated("1.2.840.10008.1.2.4.104",
+		"MPEG-4 AVC/H.264 High Profile / Level 4.2 For 2D Video", Lossy)
+	MPEG4HP423D = regencapsulated("1.2.840.10008.1.2.4.105",
+		"MPEG-4 AVC/H.264 High Profile / Level 4.2 For 3D Video", Lossy)
+	MPEG4StereoHP42 = regencapsulated("1.2.840.10008.1.2.4.106",
+		"MPEG-4 AVC/H.264 Stereo High Profile / Level 4.2", Lossy)
+	HEVCMain = regencapsulated("1.2.840.10008.1.2.4.107",
+		"HEVC/H.265 Main Profile / Level 5.1", Lossy)
+	HEVCMain10 = regencapsulated("1.2.840.10008.1.2.4.108",
+		"HEVC/H.265 Main 10 Profile / Level 5.1", Lossy)
+
+	RLELossless = regencapsulated("1.2.840.10008.1.2.5",
+		"RLE Lossless", Lossless)
 )
 
+// TransferSyntaxes returns all the known transfer syntaxes, ordered by uid.
+func TransferSyntaxes() []TransferSyntax {
+	all := make([]TransferSyntax, 0, len(tsmap))
+	for _, ts := range tsmap {
+		all = append(all, ts)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].UID() < all[j].UID()
+	})
+
+	return all
+}
+
 // Get the transfer syntax defined by the given uid.
 // If the uid is unknown, default properties are returned.
 func GetTransferSyntax(uid string) TransferSyntax {
@@ -115,11 +237,15 @@ func GetTransferSyntax(uid string) TransferSyntax {
 
 	// I believe these are the defaults for all non-raw
 	// image types...
+	// We can't know whether unknown compression is lossy, so assume the
+	// worst.
 	return &transferSyntax{
 		uid,
+		"",
 		binary.LittleEndian,
 		Explicit,
 		Inflated,
 		Encapsulated,
+		Lossy,
 	}
 }
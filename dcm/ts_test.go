@@ -15,3 +15,79 @@ func TestIVRLE(t *testing.T) {
 		}
 	}
 }
+
+func TestDeflated(t *testing.T) {
+	ts := GetTransferSyntax("1.2.840.10008.1.2.1.99")
+	if ts.Deflation() != Deflated {
+		t.Errorf("expected %s to be deflated", ts.UID())
+	}
+	if ts.VR() != Explicit {
+		t.Errorf("expected %s to be explicit", ts.UID())
+	}
+
+	if ExplicitVRLittleEndian.Deflation() != Inflated {
+		t.Errorf("expected %s to be inflated", ExplicitVRLittleEndian.UID())
+	}
+}
+
+func TestCompressedSyntaxes(t *testing.T) {
+	for _, test := range []struct {
+		uid       string
+		ts        TransferSyntax
+		name      string
+		lossiness Lossiness
+	}{
+		{"1.2.840.10008.1.2.4.50", JPEGBaseline, "JPEG Baseline (Process 1)", Lossy},
+		{"1.2.840.10008.1.2.4.70", JPEGLosslessSV1, "JPEG Lossless, Non-Hierarchical, " +
+			"First-Order Prediction (Process 14 [Selection Value 1])", Lossless},
+		{"1.2.840.10008.1.2.4.90", JPEG2000Lossless, "JPEG 2000 Image Compression (Lossless Only)", Lossless},
+		{"1.2.840.10008.1.2.4.107", HEVCMain, "HEVC/H.265 Main Profile / Level 5.1", Lossy},
+		{"1.2.840.10008.1.2.5", RLELossless, "RLE Lossless", Lossless},
+	} {
+		ts := GetTransferSyntax(test.uid)
+		if ts != test.ts {
+			t.Errorf("unexpected syntax %s for uid %s", ts, test.uid)
+		}
+		if got := ts.Name(); got != test.name {
+			t.Errorf("unexpected name %q for uid %s", got, test.uid)
+		}
+		if got := ts.Lossiness(); got != test.lossiness {
+			t.Errorf("unexpected lossiness %t for uid %s", got, test.uid)
+		}
+		if got := ts.PixelStorage(); got != Encapsulated {
+			t.Errorf("unexpected pixel storage %s for uid %s", got, test.uid)
+		}
+		if got := ts.VR(); got != Explicit {
+			t.Errorf("unexpected vr %t for uid %s", got, test.uid)
+		}
+	}
+}
+
+func TestUnknownSyntax(t *testing.T) {
+	ts := GetTransferSyntax("1.2.3.4")
+	if ts.Name() != "" {
+		t.Errorf("unexpected name %q for unknown syntax", ts.Name())
+	}
+	if ts.Lossiness() != Lossy {
+		t.Error("unknown syntax should be assumed to be lossy")
+	}
+	if ts.PixelStorage() != Encapsulated {
+		t.Error("unknown syntax should be encapsulated")
+	}
+}
+
+func TestTransferSyntaxes(t *testing.T) {
+	all := TransferSyntaxes()
+	if len(all) != len(tsmap) {
+		t.Fatalf("expected %d syntaxes, got %d", len(tsmap), len(all))
+	}
+
+	for i, ts := range all {
+		if i > 0 && all[i-1].UID() >= ts.UID() {
+			t.Errorf("syntaxes not sorted: %s >= %s", all[i-1].UID(), ts.UID())
+		}
+		if ts.Name() == "" {
+			t.Errorf("known syntax %s has no name", ts.UID())
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package dcm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Typed access to the values of simple elements.
+// See PS 3.5, 6.2 for the encoding of each VR.
+
+func (se SimpleElement) order() binary.ByteOrder {
+	if se.ByteOrder == nil {
+		return binary.LittleEndian
+	}
+
+	return se.ByteOrder
+}
+
+// wordSize gives the size of the binary numbers that a VR holds, or 0 if
+// the VR is not affected by byte order
+func (vr VR) wordSize() int {
+	switch vr.Name {
+	case "AT", "OW", "SS", "US":
+		return 2
+	case "FL", "OF", "SL", "UL":
+		return 4
+	case "FD", "OD":
+		return 8
+	default:
+		return 0
+	}
+}
+
+// WithByteOrder returns a copy of the element with its binary values
+// converted to the given byte order.  Little endian is always stored as nil.
+func (se SimpleElement) WithByteOrder(order binary.ByteOrder) SimpleElement {
+	if order == nil {
+		order = binary.LittleEndian
+	}
+
+	from := se.order()
+	se.ByteOrder = order
+	if order == binary.LittleEndian {
+		se.ByteOrder = nil
+	}
+
+	size := se.VR.wordSize()
+	if size == 0 || from == order {
+		return se
+	}
+
+	data := make([]byte, len(se.Data))
+	copy(data, se.Data)
+	for i := 0; i+size <= len(data); i += size {
+		word := data[i : i+size]
+		for j := 0; j < size/2; j++ {
+			word[j], word[size-1-j] = word[size-1-j], word[j]
+		}
+	}
+
+	se.Data = data
+	return se
+}
+
+// simple finds a simple element, or returns nil if the tag is absent
+func (o Object) simple(tag Tag) (*SimpleElement, error) {
+	el, ok := o.elements[tag]
+	if !ok {
+		return nil, nil
+	}
+
+	se, ok := el.(SimpleElement)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a simple element", el)
+	}
+
+	return &se, nil
+}
+
+func isText(vr VR) bool {
+	return vr.Padding == text || vr.Name == UI.Name
+}
+
+// splitText removes padding from a text value and splits it into its
+// values
+func splitText(vr VR, data []byte) []string {
+	str := strings.TrimRight(string(data), " \x00")
+	if str == "" {
+		return nil
+	}
+
+	switch vr.Name {
+	case LT.Name, ST.Name, UT.Name, UR.Name:
+		// single valued, and may contain backslashes
+		return []string{str}
+	}
+
+	values := strings.Split(str, "\\")
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+	}
+
+	return values
+}
+
+// The typed accessors return an empty value and no error if the tag is
+// absent.  It is an error if the element's VR doesn't hold the requested
+// type of value.
+
+// Strings returns the values of a text element, split at backslashes and
+// with padding removed.  LT, ST, UT and UR values are never split.
+func (o Object) Strings(tag Tag) ([]string, error) {
+	se, err := o.simple(tag)
+	if se == nil {
+		return nil, err
+	}
+
+	if !isText(se.VR) {
+		return nil, fmt.Errorf("cannot read %s as text", se)
+	}
+
+	return splitText(se.VR, se.Data), nil
+}
+
+// Ints returns the values of an IS, US, SS, UL or SL element.
+func (o Object) Ints(tag Tag) ([]int64, error) {
+	se, err := o.simple(tag)
+	if se == nil {
+		return nil, err
+	}
+
+	switch se.VR.Name {
+	case IS.Name:
+		strs := splitText(se.VR, se.Data)
+		values := make([]int64, len(strs))
+		for i, str := range strs {
+			values[i], err = strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q in %s", str, se)
+			}
+		}
+		return values, nil
+
+	case US.Name, SS.Name, UL.Name, SL.Name:
+		order := se.order()
+		size := se.VR.wordSize()
+		if len(se.Data)%size != 0 {
+			return nil, fmt.Errorf("invalid length %d for %s",
+				len(se.Data), se)
+		}
+
+		values := make([]int64, len(se.Data)/size)
+		for i := range values {
+			word := se.Data[i*size:]
+			switch se.VR.Name {
+			case US.Name:
+				values[i] = int64(order.Uint16(word))
+			case SS.Name:
+				values[i] = int64(int16(order.Uint16(word)))
+			case UL.Name:
+				values[i] = int64(order.Uint32(word))
+			case SL.Name:
+				values[i] = int64(int32(order.Uint32(word)))
+			}
+		}
+		return values, nil
+
+	default:
+		return nil, fmt.Errorf("cannot read %s as integers", se)
+	}
+}
+
+// Floats returns the values of a DS, FL, FD, OF or OD element, or of any
+// element that Ints can read.
+func (o Object) Floats(tag Tag) ([]float64, error) {
+	se, err := o.simple(tag)
+	if se == nil {
+		return nil, err
+	}
+
+	switch se.VR.Name {
+	case DS.Name:
+		strs := splitText(se.VR, se.Data)
+		values := make([]float64, len(strs))
+		for i, str := range strs {
+			values[i], err = strconv.ParseFloat(str, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q in %s", str, se)
+			}
+		}
+		return values, nil
+
+	case FL.Name, OF.Name, FD.Name, OD.Name:
+		order := se.order()
+		size := se.VR.wordSize()
+		if len(se.Data)%size != 0 {
+			return nil, fmt.Errorf("invalid length %d for %s",
+				len(se.Data), se)
+		}
+
+		values := make([]float64, len(se.Data)/size)
+		for i := range values {
+			word := se.Data[i*size:]
+			if size == 4 {
+				values[i] = float64(math.Float32frombits(order.Uint32(word)))
+			} else {
+				values[i] = math.Float64frombits(order.Uint64(word))
+			}
+		}
+		return values, nil
+
+	default:
+		ints, err := o.Ints(tag)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s as decimals", se)
+		}
+
+		values := make([]float64, len(ints))
+		for i, value := range ints {
+			values[i] = float64(value)
+		}
+		return values, nil
+	}
+}
+
+// Tags returns the values of an AT element.
+func (o Object) Tags(tag Tag) ([]Tag, error) {
+	se, err := o.simple(tag)
+	if se == nil {
+		return nil, err
+	}
+
+	if !VREq(&se.VR, &AT) {
+		return nil, fmt.Errorf("cannot read %s as tags", se)
+	}
+
+	if len(se.Data)%4 != 0 {
+		return nil, fmt.Errorf("invalid length %d for %s", len(se.Data), se)
+	}
+
+	order := se.order()
+	values := make([]Tag, len(se.Data)/4)
+	for i := range values {
+		values[i] = NewTag(
+			order.Uint16(se.Data[i*4:]),
+			order.Uint16(se.Data[i*4+2:]))
+	}
+
+	return values, nil
+}
+
+// Precision is the least significant component present in a date or time,
+// which may leave out the components after it.
+type Precision int
+
+const (
+	PrecisionYear Precision = iota
+	PrecisionMonth
+	PrecisionDay
+	PrecisionHour
+	PrecisionMinute
+	PrecisionSecond
+	PrecisionFraction
+)
+
+// PartialTime is the value of a DA, TM or DT element.  Components after
+// the precision are zero (or 1 for month and day).  Times without a date
+// are on January 1st of year 0.
+type PartialTime struct {
+	time.Time
+	Precision Precision
+}
+
+// Date returns the first value of a DA element.
+func (o Object) Date(tag Tag) (PartialTime, error) {
+	return o.partialTime(tag, DA)
+}
+
+// Time returns the first value of a TM element.
+func (o Object) Time(tag Tag) (PartialTime, error) {
+	return o.partialTime(tag, TM)
+}
+
+// DateTime returns the first value of a DT element.  If the value has no
+// UTC offset, TimezoneOffsetFromUTC is used, or the local time zone if that
+// is absent as well.
+func (o Object) DateTime(tag Tag) (PartialTime, error) {
+	return o.partialTime(tag, DT)
+}
+
+func (o Object) partialTime(tag Tag, vr VR) (PartialTime, error) {
+	se, err := o.simple(tag)
+	if se == nil {
+		return PartialTime{}, err
+	}
+
+	if !VREq(&se.VR, &vr) {
+		return PartialTime{}, fmt.Errorf("cannot read %s as %s", se, vr)
+	}
+
+	values := splitText(se.VR, se.Data)
+	if len(values) == 0 || values[0] == "" {
+		return PartialTime{}, nil
+	}
+
+	loc, err := o.location()
+	if err != nil {
+		return PartialTime{}, err
+	}
+
+	pt, err := parsePartialTime(values[0], vr, loc)
+	if err != nil {
+		return pt, fmt.Errorf("invalid value %q in %s: %s", values[0], se, err)
+	}
+
+	return pt, nil
+}
+
+// location gives the default time zone of the object
+func (o Object) location() (*time.Location, error) {
+	values, err := o.Strings(TimezoneOffsetFromUTC)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return time.Local, nil
+	}
+
+	loc, err := parseOffset(values[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid TimezoneOffsetFromUTC %q: %s",
+			values[0], err)
+	}
+
+	return loc, nil
+}
+
+// parseOffset parses a UTC offset of the form &ZZXX
+func parseOffset(str string) (*time.Location, error) {
+	if len(str) != 5 || (str[0] != '+' && str[0] != '-') {
+		return nil, fmt.Errorf("expected +HHMM or -HHMM")
+	}
+
+	hours, err := strconv.Atoi(str[1:3])
+	if err != nil {
+		return nil, err
+	}
+
+	minutes, err := strconv.Atoi(str[3:5])
+	if err != nil {
+		return nil, err
+	}
+
+	offset := hours*60*60 + minutes*60
+	if str[0] == '-' {
+		offset = -offset
+	}
+
+	return time.FixedZone(str, offset), nil
+}
+
+// partialTimeFields are the digits in each component of a DT, in order
+var partialTimeFields = [...]struct {
+	digits   int
+	min, max int
+}{
+	PrecisionYear:   {4, 0, 9999},
+	PrecisionMonth:  {2, 1, 12},
+	PrecisionDay:    {2, 1, 31},
+	PrecisionHour:   {2, 0, 23},
+	PrecisionMinute: {2, 0, 59},
+	PrecisionSecond: {2, 0, 60},
+}
+
+// parsePartialTime parses a DT, or the date or time subset of it for DA
+// or TM.
+func parsePartialTime(str string, vr VR, loc *time.Location) (PartialTime, error) {
+	first, last := PrecisionYear, PrecisionSecond
+	switch vr.Name {
+	case DA.Name:
+		// older versions of the standard allowed yyyy.mm.dd
+		str = strings.Replace(str, ".", "", -1)
+		last = PrecisionDay
+	case TM.Name:
+		// and hh:mm:ss
+		str = strings.Replace(str, ":", "", -1)
+		first = PrecisionHour
+	case DT.Name:
+		if i := strings.IndexAny(str, "+-"); i >= 0 {
+			var err error
+			if loc, err = parseOffset(str[i:]); err != nil {
+				return PartialTime{}, err
+			}
+			str = str[:i]
+		}
+	}
+
+	values := [...]int{0, 1, 1, 0, 0, 0}
+	pt := PartialTime{Precision: -1}
+	for p := first; p <= last && str != "" && str[0] != '.'; p++ {
+		field := partialTimeFields[p]
+		if len(str) < field.digits {
+			return pt, fmt.Errorf("incomplete component")
+		}
+
+		value, err := strconv.Atoi(str[:field.digits])
+		if err != nil || value < field.min || value > field.max {
+			return pt, fmt.Errorf("invalid component %q", str[:field.digits])
+		}
+
+		values[p] = value
+		pt.Precision = p
+		str = str[field.digits:]
+	}
+
+	if pt.Precision < first {
+		return pt, fmt.Errorf("no components")
+	}
+
+	var nanos int
+	if str != "" {
+		fraction := strings.TrimPrefix(str, ".")
+		if pt.Precision != PrecisionSecond || fraction == str ||
+			len(fraction) < 1 || len(fraction) > 6 {
+			return pt, fmt.Errorf("unexpected %q", str)
+		}
+
+		micros, err := strconv.Atoi(fraction)
+		if err != nil || micros < 0 {
+			return pt, fmt.Errorf("invalid fraction %q", fraction)
+		}
+
+		nanos = micros * int(math.Pow10(9-len(fraction)))
+		pt.Precision = PrecisionFraction
+	}
+
+	pt.Time = time.Date(values[PrecisionYear], time.Month(values[PrecisionMonth]),
+		values[PrecisionDay], values[PrecisionHour], values[PrecisionMinute],
+		values[PrecisionSecond], nanos, loc)
+
+	return pt, nil
+}
+
+// Name is the value of a PN (person name) element, with up to three
+// component groups.  See PS 3.5, 6.2.1.
+type Name struct {
+	Alphabetic  NameGroup
+	Ideographic NameGroup
+	Phonetic    NameGroup
+}
+
+// NameGroup are the components of one group of a person name.
+type NameGroup struct {
+	Family string
+	Given  string
+	Middle string
+	Prefix string
+	Suffix string
+}
+
+func parsePersonName(str string) (pn Name) {
+	groups := []*NameGroup{&pn.Alphabetic, &pn.Ideographic, &pn.Phonetic}
+	for i, group := range strings.SplitN(str, "=", len(groups)) {
+		fields := []*string{
+			&groups[i].Family,
+			&groups[i].Given,
+			&groups[i].Middle,
+			&groups[i].Prefix,
+			&groups[i].Suffix,
+		}
+
+		for j, component := range strings.SplitN(group, "^", len(fields)) {
+			*fields[j] = strings.TrimSpace(component)
+		}
+	}
+
+	return pn
+}
+
+// PersonNames returns the values of a PN element.
+func (o Object) PersonNames(tag Tag) ([]Name, error) {
+	se, err := o.simple(tag)
+	if se == nil {
+		return nil, err
+	}
+
+	if !VREq(&se.VR, &PN) {
+		return nil, fmt.Errorf("cannot read %s as person names", se)
+	}
+
+	strs := splitText(se.VR, se.Data)
+	names := make([]Name, len(strs))
+	for i, str := range strs {
+		names[i] = parsePersonName(str)
+	}
+
+	return names, nil
+}
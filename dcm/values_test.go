@@ -0,0 +1,229 @@
+package dcm
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStrings(t *testing.T) {
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: ImageType, VR: CS, Data: []byte("ORIGINAL\\PRIMARY ")})
+	obj.Put(SimpleElement{Tag: SOPClassUID, VR: UI, Data: []byte("1.2.3\x00")})
+	obj.Put(SimpleElement{Tag: ImageComments, VR: LT, Data: []byte(" a\\b ")})
+	obj.Put(us(Rows, 2))
+
+	for _, test := range []struct {
+		tag Tag
+		exp []string
+	}{
+		{ImageType, []string{"ORIGINAL", "PRIMARY"}},
+		{SOPClassUID, []string{"1.2.3"}},
+		{ImageComments, []string{" a\\b"}},
+		{PatientID, nil},
+	} {
+		got, err := obj.Strings(test.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.exp, got) {
+			t.Errorf("%s: expected %q, got %q", test.tag, test.exp, got)
+		}
+	}
+
+	if _, err := obj.Strings(Rows); err == nil {
+		t.Fatal("expected error reading US as text")
+	}
+}
+
+func TestInts(t *testing.T) {
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: NumberOfFrames, VR: IS, Data: []byte(" 12\\-3 ")})
+	obj.Put(SimpleElement{Tag: Rows, VR: US, Data: []byte{0x01, 0x02},
+		ByteOrder: binary.BigEndian})
+	obj.Put(SimpleElement{Tag: SmallestImagePixelValue, VR: SS, Data: []byte{0xFE, 0xFF}})
+	obj.Put(SimpleElement{Tag: SimpleFrameList, VR: UL, Data: []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x01, 0x00, 0x00, 0x00,
+	}})
+	obj.Put(SimpleElement{Tag: PatientID, VR: LO, Data: []byte("12")})
+
+	for _, test := range []struct {
+		tag Tag
+		exp []int64
+	}{
+		{NumberOfFrames, []int64{12, -3}},
+		{Rows, []int64{0x0102}},
+		{SmallestImagePixelValue, []int64{-2}},
+		{SimpleFrameList, []int64{0xFFFFFFFF, 1}},
+	} {
+		got, err := obj.Ints(test.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.exp, got) {
+			t.Errorf("%s: expected %v, got %v", test.tag, test.exp, got)
+		}
+	}
+
+	if _, err := obj.Ints(PatientID); err == nil {
+		t.Fatal("expected error reading LO as integers")
+	}
+
+	obj.Put(SimpleElement{Tag: Rows, VR: US, Data: []byte{0x01}})
+	if _, err := obj.Ints(Rows); err == nil {
+		t.Fatal("expected error for odd length US")
+	}
+}
+
+func TestFloats(t *testing.T) {
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: PixelSpacing, VR: DS, Data: []byte("0.5\\1e1 ")})
+	obj.Put(SimpleElement{Tag: RecommendedDisplayFrameRateInFloat, VR: FL,
+		Data: []byte{0x40, 0x20, 0x00, 0x00}, ByteOrder: binary.BigEndian})
+	obj.Put(SimpleElement{Tag: Rows, VR: US, Data: []byte{0x02, 0x00}})
+
+	for _, test := range []struct {
+		tag Tag
+		exp []float64
+	}{
+		{PixelSpacing, []float64{0.5, 10}},
+		{RecommendedDisplayFrameRateInFloat, []float64{2.5}},
+		{Rows, []float64{2}},
+	} {
+		got, err := obj.Floats(test.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.exp, got) {
+			t.Errorf("%s: expected %v, got %v", test.tag, test.exp, got)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: FrameIncrementPointer, VR: AT, Data: []byte{
+		0x00, 0x18, 0x10, 0x63,
+	}, ByteOrder: binary.BigEndian})
+
+	got, err := obj.Tags(FrameIncrementPointer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []Tag{FrameTime}; !reflect.DeepEqual(exp, got) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+func TestDateTime(t *testing.T) {
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: TimezoneOffsetFromUTC, VR: SH, Data: []byte("-0500 ")})
+	est := time.FixedZone("", -5*60*60)
+
+	for _, test := range []struct {
+		vr        VR
+		value     string
+		exp       time.Time
+		precision Precision
+	}{
+		{DA, "20240229", time.Date(2024, 2, 29, 0, 0, 0, 0, est), PrecisionDay},
+		{DA, "2024.02.29", time.Date(2024, 2, 29, 0, 0, 0, 0, est), PrecisionDay},
+		{TM, "0930", time.Date(0, 1, 1, 9, 30, 0, 0, est), PrecisionMinute},
+		{TM, "09:30:15", time.Date(0, 1, 1, 9, 30, 15, 0, est), PrecisionSecond},
+		{TM, "093015.25 ", time.Date(0, 1, 1, 9, 30, 15, 250000000, est), PrecisionFraction},
+		{DT, "2024", time.Date(2024, 1, 1, 0, 0, 0, 0, est), PrecisionYear},
+		{DT, "202402291030", time.Date(2024, 2, 29, 10, 30, 0, 0, est), PrecisionMinute},
+		{DT, "20240229103000.000001+0100", time.Date(2024, 2, 29, 9, 30, 0, 1000, time.UTC), PrecisionFraction},
+	} {
+		obj.Put(SimpleElement{Tag: AcquisitionDateTime, VR: test.vr, Data: []byte(test.value)})
+
+		got, err := obj.partialTime(AcquisitionDateTime, test.vr)
+		if err != nil {
+			t.Errorf("%s %q: %s", test.vr, test.value, err)
+			continue
+		}
+
+		if !got.Equal(test.exp) || got.Precision != test.precision {
+			t.Errorf("%s %q: expected %s (%d), got %s (%d)", test.vr, test.value,
+				test.exp, test.precision, got.Time, got.Precision)
+		}
+	}
+
+	for _, value := range []string{"2024021", "20241301", "2024022910", "x"} {
+		obj.Put(SimpleElement{Tag: StudyDate, VR: DA, Data: []byte(value)})
+		if _, err := obj.Date(StudyDate); err == nil {
+			t.Errorf("expected error for DA %q", value)
+		}
+	}
+
+	obj.Put(SimpleElement{Tag: StudyTime, VR: TM, Data: []byte("10.5")})
+	if _, err := obj.Time(StudyTime); err == nil {
+		t.Error("expected error for fraction of hours")
+	}
+
+	if _, err := obj.DateTime(StudyTime); err == nil {
+		t.Error("expected error for reading TM as DT")
+	}
+
+	if got, err := obj.Date(PatientBirthDate); err != nil || !got.IsZero() {
+		t.Errorf("expected zero value for absent date, got %s, %v", got.Time, err)
+	}
+}
+
+func TestPersonNames(t *testing.T) {
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: PatientName, VR: PN, Data: []byte(
+		"Yamada^Tarou=山田^太郎=やまだ^たろう\\Doe^John^^Dr ")})
+
+	got, err := obj.PersonNames(PatientName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Name{
+		{
+			Alphabetic:  NameGroup{Family: "Yamada", Given: "Tarou"},
+			Ideographic: NameGroup{Family: "山田", Given: "太郎"},
+			Phonetic:    NameGroup{Family: "やまだ", Given: "たろう"},
+		},
+		{
+			Alphabetic: NameGroup{Family: "Doe", Given: "John", Prefix: "Dr"},
+		},
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("expected %+v, got %+v", exp, got)
+	}
+}
+
+func TestWithByteOrder(t *testing.T) {
+	le := SimpleElement{Tag: PixelSpacing, VR: FD, Data: []byte{
+		1, 2, 3, 4, 5, 6, 7, 8,
+	}}
+
+	be := le.WithByteOrder(binary.BigEndian)
+	if exp := []byte{8, 7, 6, 5, 4, 3, 2, 1}; !reflect.DeepEqual(exp, be.Data) {
+		t.Fatalf("unexpected data: %v", be.Data)
+	}
+	if le.Data[0] != 1 {
+		t.Fatal("original data was modified")
+	}
+
+	if back := be.WithByteOrder(nil); !reflect.DeepEqual(le, back) {
+		t.Fatalf("expected %#v, got %#v", le, back)
+	}
+
+	text := SimpleElement{Tag: PatientID, VR: LO, Data: []byte("ab")}
+	if got := text.WithByteOrder(binary.BigEndian); string(got.Data) != "ab" {
+		t.Fatalf("text was swapped: %q", got.Data)
+	}
+
+	var value uint16
+	obj := NewObject()
+	obj.Put(SimpleElement{Tag: Rows, VR: US, Data: []byte{0x01, 0x02},
+		ByteOrder: binary.BigEndian})
+	if err := obj.Scan(Rows, &value); err != nil || value != 0x0102 {
+		t.Fatalf("unexpected scan result: %X, %v", value, err)
+	}
+}
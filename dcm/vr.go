@@ -55,7 +55,7 @@ var (
 	OW = vr("OW", bin, long)
 	PN = vr("PN", text, short)
 	SH = vr("SH", text, short)
-	SL = vr("SL", text, short)
+	SL = vr("SL", bin, short)
 	SQ = vr("SQ", bin, long)
 	SS = vr("SS", bin, short)
 	ST = vr("ST", text, short)
@@ -1,50 +1,105 @@
 package dcmio
 
 import (
+	bin "encoding/binary"
+	"fmt"
+	"io"
 	"io/ioutil"
 
 	"github.com/jeremyhuiskamp/dcm/dcm"
 )
 
-func Build(parser Parser) (obj dcm.Object, err error) {
+// noEnd is the end position for objects that are not limited by a length
+const noEnd = ^uint64(0)
+
+func Build(parser Parser) (dcm.Object, error) {
+	return BuildWithCharacterSet(parser, dcm.DefaultCharacterSet)
+}
+
+// BuildWithCharacterSet is like Build, but decodes text with the given
+// character set until the object has its own SpecificCharacterSet, instead
+// of the default (ASCII).
+func BuildWithCharacterSet(parser Parser, cs *dcm.CharacterSet) (dcm.Object, error) {
+	obj, _, err := buildObject(parser, noEnd, cs)
+	return obj, err
+}
+
+// endOf gives the position where the value of the tag ends, or noEnd if the
+// tag has undefined length
+func endOf(tag *Tag) uint64 {
+	if tag.HasUndefinedLength() {
+		return noEnd
+	}
+
+	return tag.ValueOffset + uint64(tag.ValueLength)
+}
+
+// buildObject reads elements into a new object until the parser reaches the
+// given end position, an ItemDelimitationItem or the end of the stream.
+// If it was an ItemDelimitationItem, that is returned as well.
+//
+// Text values are decoded to UTF-8 with the given character set, which is
+// that of the enclosing object, until the object has its own
+// SpecificCharacterSet.
+func buildObject(parser Parser, end uint64, cs *dcm.CharacterSet) (obj dcm.Object, delim *Tag, err error) {
 	obj = dcm.NewObject()
 
-	for {
+	for parser.GetPosition() < end {
 		tag, err := parser.NextTag()
-		if err != nil || tag == nil || dcm.ItemDelimitationItem == tag.Tag {
-			return obj, err
+		if err != nil || tag == nil {
+			return obj, nil, err
+		}
+
+		if dcm.ItemDelimitationItem == tag.Tag {
+			return obj, tag, nil
+		}
+
+		if tag.Tag == dcm.Item || tag.Tag == dcm.SequenceDelimitationItem {
+			return obj, nil, fmt.Errorf("Unexpected %s at offset %d "+
+				"outside of a sequence", tag.Tag, tag.Offset)
 		}
 
 		if tag.Tag.IsGroupLength() {
 			// group length
+			// we still have to read it, so that the parser's
+			// position is accurate
+			_, err = io.Copy(ioutil.Discard, tag.Value)
+			if err != nil {
+				return obj, nil, err
+			}
 			continue
 		}
 
 		vr := tag.VR
 		if vr == nil {
-			// TODO: private creator support
-			tagvr := dcm.VRForTag("", tag.Tag)
+			tagvr := dcm.VRForTag(tag.PrivateCreator, tag.Tag)
 			if !dcm.VREq(&tagvr, &dcm.UN) {
 				vr = &tagvr
 			}
 		}
 
-		if vr == nil && tag.ValueLength == -1 {
+		if vr == nil && tag.HasUndefinedLength() {
 			vr = &dcm.SQ
 		}
 
 		if dcm.VREq(vr, &dcm.SQ) {
-			sq := dcm.SequenceElement{
-				Tag: tag.Tag,
+			sq, err := buildSequence(parser, tag, cs)
+			if err != nil {
+				return obj, nil, err
 			}
 
 			obj.Put(sq)
+		} else if tag.HasUndefinedLength() {
+			el, err := buildEncapsulated(parser, tag, *vr)
+			if err != nil {
+				return obj, nil, err
+			}
 
-			// TODO iterate through items
+			obj.Put(el)
 		} else {
 			data, err := ioutil.ReadAll(tag.Value)
 			if err != nil {
-				return obj, err
+				return obj, nil, err
 			}
 
 			el := dcm.SimpleElement{
@@ -53,7 +108,106 @@ func Build(parser Parser) (obj dcm.Object, err error) {
 				Data: data,
 			}
 
-			obj.Put(el)
+			// little endian is the default, so leave it out, which keeps
+			// the element equal to ones that are created in memory
+			if tag.ByteOrder != bin.LittleEndian {
+				el.ByteOrder = tag.ByteOrder
+			}
+
+			if el.Tag == dcm.SpecificCharacterSet {
+				obj.Put(el)
+				// an unknown character set is no reason to give up
+				// on the rest of the object, so fall back to the
+				// default
+				if cs, err = obj.CharacterSet(); err != nil {
+					cs = dcm.DefaultCharacterSet
+				}
+			}
+
+			obj.Put(cs.DecodeElement(el))
+		}
+	}
+
+	return obj, nil, nil
+}
+
+// buildSequence reads the items of a sequence, which has either a defined
+// length or is terminated by a SequenceDelimitationItem.
+// Each item similarly has a defined length or is terminated by an
+// ItemDelimitationItem.
+func buildSequence(parser Parser, sqtag *Tag, cs *dcm.CharacterSet) (sq dcm.SequenceElement, err error) {
+	sq.Tag = sqtag.Tag
+	end := endOf(sqtag)
+
+	for parser.GetPosition() < end {
+		tag, err := parser.NextTag()
+		if err != nil {
+			return sq, err
+		}
+
+		if tag == nil {
+			return sq, io.ErrUnexpectedEOF
+		}
+
+		switch tag.Tag {
+		case dcm.SequenceDelimitationItem:
+			return sq, nil
+
+		case dcm.Item:
+			itemEnd := endOf(tag)
+			item, delim, err := buildObject(parser, itemEnd, cs)
+			if err != nil {
+				return sq, err
+			}
+
+			if delim == nil && parser.GetPosition() < itemEnd {
+				return sq, io.ErrUnexpectedEOF
+			}
+
+			sq.Objects = append(sq.Objects, item)
+
+		default:
+			return sq, fmt.Errorf("Unexpected %s at offset %d in "+
+				"sequence %s", tag.Tag, tag.Offset, sq.Tag)
+		}
+	}
+
+	return sq, nil
+}
+
+// buildEncapsulated reads the fragments of encapsulated data (normally
+// PixelData in a transfer syntax with encapsulated pixel storage).  Each
+// fragment is an item, and they are terminated by a SequenceDelimitationItem.
+// The first fragment is the basic offset table.
+func buildEncapsulated(parser Parser, eltag *Tag, vr dcm.VR) (el dcm.EncapsulatedElement, err error) {
+	el.Tag = eltag.Tag
+	el.VR = vr
+
+	for {
+		tag, err := parser.NextTag()
+		if err != nil {
+			return el, err
+		}
+
+		if tag == nil {
+			return el, io.ErrUnexpectedEOF
+		}
+
+		switch tag.Tag {
+		case dcm.SequenceDelimitationItem:
+			return el, nil
+
+		case dcm.Item:
+			fragment, err := ioutil.ReadAll(tag.Value)
+			if err != nil {
+				return el, err
+			}
+
+			el.Data = append(el.Data, fragment)
+
+		default:
+			return el, fmt.Errorf("Unexpected %s at offset %d in "+
+				"encapsulated data %s", tag.Tag, tag.Offset, el.Tag)
 		}
 	}
 }
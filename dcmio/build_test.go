@@ -1,7 +1,10 @@
 package dcmio
 
 import (
+	"bytes"
+	"io"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -67,3 +70,243 @@ func getInt(tag dcm.Tag, obj dcm.Object, ts dcm.TransferSyntax) int {
 
 	return -1
 }
+
+func TestBuildSequences(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		ts   dcm.TransferSyntax
+		data []byte
+	}{
+		{"explicit, defined lengths", dcm.ExplicitVRLittleEndian, []byte{
+			0x08, 0x00, 0x15, 0x11, 'S', 'Q', 0x00, 0x00,
+			0x28, 0x00, 0x00, 0x00,
+			0xFE, 0xFF, 0x00, 0xE0, 0x0C, 0x00, 0x00, 0x00,
+			0x20, 0x00, 0x0E, 0x00, 'U', 'I', 0x04, 0x00,
+			'1', '.', '2', 0x00,
+			0xFE, 0xFF, 0x00, 0xE0, 0x0C, 0x00, 0x00, 0x00,
+			0x20, 0x00, 0x0E, 0x00, 'U', 'I', 0x04, 0x00,
+			'1', '.', '3', 0x00,
+			0x10, 0x00, 0x20, 0x00, 'L', 'O', 0x04, 0x00,
+			'p', 'i', 'd', ' ',
+		}},
+		{"explicit, undefined lengths", dcm.ExplicitVRLittleEndian, []byte{
+			0x08, 0x00, 0x15, 0x11, 'S', 'Q', 0x00, 0x00,
+			0xFF, 0xFF, 0xFF, 0xFF,
+			0xFE, 0xFF, 0x00, 0xE0, 0xFF, 0xFF, 0xFF, 0xFF,
+			0x20, 0x00, 0x0E, 0x00, 'U', 'I', 0x04, 0x00,
+			'1', '.', '2', 0x00,
+			0xFE, 0xFF, 0x0D, 0xE0, 0x00, 0x00, 0x00, 0x00,
+			0xFE, 0xFF, 0x00, 0xE0, 0xFF, 0xFF, 0xFF, 0xFF,
+			0x20, 0x00, 0x0E, 0x00, 'U', 'I', 0x04, 0x00,
+			'1', '.', '3', 0x00,
+			0xFE, 0xFF, 0x0D, 0xE0, 0x00, 0x00, 0x00, 0x00,
+			0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+			0x10, 0x00, 0x20, 0x00, 'L', 'O', 0x04, 0x00,
+			'p', 'i', 'd', ' ',
+		}},
+		{"implicit, mixed lengths", dcm.ImplicitVRLittleEndian, []byte{
+			0x08, 0x00, 0x15, 0x11, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFE, 0xFF, 0x00, 0xE0, 0x0C, 0x00, 0x00, 0x00,
+			0x20, 0x00, 0x0E, 0x00, 0x04, 0x00, 0x00, 0x00,
+			'1', '.', '2', 0x00,
+			0xFE, 0xFF, 0x00, 0xE0, 0xFF, 0xFF, 0xFF, 0xFF,
+			0x20, 0x00, 0x0E, 0x00, 0x04, 0x00, 0x00, 0x00,
+			'1', '.', '3', 0x00,
+			0xFE, 0xFF, 0x0D, 0xE0, 0x00, 0x00, 0x00, 0x00,
+			0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+			0x10, 0x00, 0x20, 0x00, 0x04, 0x00, 0x00, 0x00,
+			'p', 'i', 'd', ' ',
+		}},
+	} {
+		obj, err := Build(NewStreamParser(bytes.NewReader(test.data), test.ts))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if got := getString(dcm.PatientID, obj); got != "pid " {
+			t.Errorf("%s: unexpected patient id %q", test.name, got)
+		}
+
+		el := obj.Get(dcm.ReferencedSeriesSequence)
+		if el == nil {
+			t.Fatalf("%s: sequence not found", test.name)
+		}
+
+		sq, ok := (*el).(dcm.SequenceElement)
+		if !ok {
+			t.Fatalf("%s: unexpected sequence element %s", test.name, *el)
+		}
+
+		if len(sq.Objects) != 2 {
+			t.Fatalf("%s: unexpected number of items: %d",
+				test.name, len(sq.Objects))
+		}
+
+		for i, exp := range []string{"1.2", "1.3"} {
+			got := getString(dcm.SeriesInstanceUID, sq.Objects[i])
+			if got != exp {
+				t.Errorf("%s: item %d has unexpected uid %q",
+					test.name, i, got)
+			}
+		}
+	}
+}
+
+func TestBuildSequenceRoundTrip(t *testing.T) {
+	inner := dcm.NewObject()
+	inner.Put(dcm.SimpleElement{
+		Tag:  dcm.CodeValue,
+		VR:   dcm.SH,
+		Data: []byte("T-D1"),
+	})
+
+	item := dcm.NewObject()
+	item.Put(dcm.SimpleElement{
+		Tag:  dcm.SeriesInstanceUID,
+		VR:   dcm.UI,
+		Data: []byte("1.2\x00"),
+	})
+	item.Put(dcm.SequenceElement{
+		Tag:     dcm.AnatomicRegionSequence,
+		Objects: []dcm.Object{inner},
+	})
+
+	obj := dcm.NewObject()
+	obj.Put(dcm.SequenceElement{
+		Tag:     dcm.ReferencedSeriesSequence,
+		Objects: []dcm.Object{item, dcm.NewObject()},
+	})
+	obj.Put(dcm.SequenceElement{
+		Tag: dcm.ReferencedStudySequence,
+	})
+
+	for _, ts := range []dcm.TransferSyntax{
+		dcm.ImplicitVRLittleEndian,
+		dcm.ExplicitVRLittleEndian,
+		dcm.ExplicitVRBigEndian,
+	} {
+		var buf bytes.Buffer
+		if err := NewStreamEncoder(&buf, ts).Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Build(NewStreamParser(&buf, ts))
+		if err != nil {
+			t.Fatalf("%s: %s", ts.UID(), err)
+		}
+
+		if got = littleEndian(got); !reflect.DeepEqual(obj, got) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", ts.UID(), obj, got)
+		}
+	}
+}
+
+func TestBuildTruncatedSequence(t *testing.T) {
+	data := []byte{
+		0x08, 0x00, 0x15, 0x11, 'S', 'Q', 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x20, 0x00, 0x0E, 0x00, 'U', 'I', 0x04, 0x00,
+		'1', '.', '2', 0x00,
+	}
+
+	_, err := Build(NewStreamParser(bytes.NewReader(data),
+		dcm.ExplicitVRLittleEndian))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected unexpected eof, got %v", err)
+	}
+}
+
+func TestBuildEncapsulated(t *testing.T) {
+	data := []byte{
+		0xE0, 0x7F, 0x10, 0x00, 'O', 'B', 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0x00, 0xE0, 0x04, 0x00, 0x00, 0x00,
+		0x01, 0x02, 0x03, 0x04,
+		0xFE, 0xFF, 0x00, 0xE0, 0x02, 0x00, 0x00, 0x00,
+		0x05, 0x06,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	obj, err := Build(NewStreamParser(bytes.NewReader(data),
+		dcm.ExplicitVRLittleEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := dcm.NewObject()
+	exp.Put(dcm.EncapsulatedElement{
+		Tag: dcm.PixelData,
+		VR:  dcm.OB,
+		Data: [][]byte{
+			{},
+			{0x01, 0x02, 0x03, 0x04},
+			{0x05, 0x06},
+		},
+	})
+
+	if !reflect.DeepEqual(exp, obj) {
+		t.Fatalf("expected\n%s\ngot\n%s", exp, obj)
+	}
+
+	// and back again:
+	var buf bytes.Buffer
+	err = NewStreamEncoder(&buf, dcm.ExplicitVRLittleEndian).Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Fatalf("unexpected encoding:\n% X\nexpected:\n% X",
+			buf.Bytes(), data)
+	}
+}
+
+// littleEndian converts all the elements of an object to little endian, for
+// comparison with objects that were created in memory
+func littleEndian(obj dcm.Object) dcm.Object {
+	le := dcm.NewObject()
+	obj.ForEach(func(tag dcm.Tag, el dcm.Element) bool {
+		switch el := el.(type) {
+		case dcm.SimpleElement:
+			le.Put(el.WithByteOrder(nil))
+
+		case dcm.SequenceElement:
+			var items []dcm.Object
+			for _, item := range el.Objects {
+				items = append(items, littleEndian(item))
+			}
+			le.Put(dcm.SequenceElement{Tag: el.Tag, Objects: items})
+
+		default:
+			le.Put(el)
+		}
+		return true
+	})
+
+	return le
+}
+
+func TestBuildWithCharacterSet(t *testing.T) {
+	// LO "café" in Latin-1, without a SpecificCharacterSet
+	data := []byte("\x10\x00\x20\x00LO\x04\x00caf\xe9")
+
+	for _, test := range []struct {
+		cs  *dcm.CharacterSet
+		exp string
+	}{
+		// not decoded, but not lost either:
+		{dcm.DefaultCharacterSet, "caf\xe9"},
+		{dcm.Latin1CharacterSet, "café"},
+	} {
+		parser := NewStreamParser(bytes.NewReader(data), dcm.ExplicitVRLittleEndian)
+		obj, err := BuildWithCharacterSet(parser, test.cs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := obj.GetString(dcm.PatientID); got != test.exp {
+			t.Errorf("expected %q, got %q", test.exp, got)
+		}
+	}
+}
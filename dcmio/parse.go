@@ -1,15 +1,16 @@
 package dcmio
 
 // Things to try to add:
-// - switch transfer syntaxes in sequence items with an unknown VR
 // - implement fast jumps over known-length things
 // -- eg, if we have group, sequence or item lengths
 // -- might want to check if underlying stream supports seek, for speed
 
 import (
 	"bytes"
+	"compress/flate"
 	bin "encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/jeremyhuiskamp/dcm/dcm"
 	"io"
 	"io/ioutil"
@@ -40,6 +41,21 @@ type Tag struct {
 	ValueOffset uint64
 	ValueLength int32
 	Value       io.Reader
+	// byte order of the value, from the transfer syntax
+	ByteOrder bin.ByteOrder
+	// for private data elements, the private creator that reserved the
+	// block, if it was found
+	PrivateCreator string
+}
+
+// UndefinedLength is the ValueLength of sequences, items and encapsulated
+// data that are terminated by delimitation items instead.
+const UndefinedLength int32 = -1
+
+// HasUndefinedLength returns true if the tag is terminated by a delimitation
+// item instead of having a known length.
+func (t *Tag) HasUndefinedLength() bool {
+	return t.ValueLength == UndefinedLength
 }
 
 type Parser interface {
@@ -49,6 +65,13 @@ type Parser interface {
 
 	// Reads the next tag from the stream.
 	// Returns (nil, nil) if EOF is reached gracefully
+	//
+	// Sequences and their items are not returned as a single value.
+	// Instead, the sequence tag, each item tag, the elements inside the
+	// items and any delimitation items are all returned in turn.
+	// Encapsulated data (an element with undefined length that is not a
+	// sequence) is similarly followed by items, but their values hold the
+	// fragments of data.
 	NextTag() (*Tag, error)
 }
 
@@ -63,6 +86,66 @@ type SimpleParser struct {
 	// track the previous tag we returned, so that we can
 	// make sure it's stream is drained
 	previousTag *Tag
+
+	// whether we're in the items of encapsulated data, which hold
+	// fragments of data instead of more elements
+	fragments bool
+
+	// the private creators of the data set and the items we're in,
+	// innermost last
+	datasets []privateCreators
+
+	// the transfer syntaxes to return to at the end of the undefined
+	// length sequences and encapsulated data we're in, innermost last
+	sequences []dcm.TransferSyntax
+}
+
+// privateCreators records the private creator elements of a data set, since
+// the blocks they reserve are only valid within it.
+type privateCreators struct {
+	// where the data set ends, or noEnd if it has undefined length
+	end uint64
+	// values of the private creator elements, by tag
+	creators map[dcm.Tag]string
+}
+
+// enterDataSet is called at the start of the top level data set or an item
+func (p *SimpleParser) enterDataSet(end uint64) {
+	p.datasets = append(p.datasets, privateCreators{end, nil})
+}
+
+// leaveDataSets forgets the items that have ended before the given position
+func (p *SimpleParser) leaveDataSets(position uint64) {
+	for len(p.datasets) > 1 && p.datasets[len(p.datasets)-1].end <= position {
+		p.datasets = p.datasets[:len(p.datasets)-1]
+	}
+}
+
+// recordPrivateCreator remembers the value of a private creator element
+func (p *SimpleParser) recordPrivateCreator(tag *Tag) error {
+	buf, err := bufferValue(tag)
+	if err != nil {
+		return err
+	}
+
+	dataset := &p.datasets[len(p.datasets)-1]
+	if dataset.creators == nil {
+		dataset.creators = make(map[dcm.Tag]string)
+	}
+
+	dataset.creators[tag.Tag] = strings.Trim(buf.String(), " \x00")
+	return nil
+}
+
+// privateCreator finds the private creator of a private data element in
+// the current data set.
+func (p *SimpleParser) privateCreator(tag dcm.Tag) string {
+	creator, ok := tag.PrivateCreator()
+	if !ok {
+		return ""
+	}
+
+	return p.datasets[len(p.datasets)-1].creators[creator]
 }
 
 func (p *SimpleParser) GetPosition() uint64 {
@@ -84,6 +167,7 @@ func (p *SimpleParser) readTag() (tag *Tag, err error) {
 	}
 
 	order := p.ts.ByteOrder()
+	tag.ByteOrder = order
 	tag.Tag = dcm.NewTag(
 		order.Uint16(bytes[:2]),
 		order.Uint16(bytes[2:]))
@@ -98,7 +182,22 @@ func (p *SimpleParser) skipPreviousValue() (err error) {
 	return err
 }
 
-func (p *SimpleParser) NextTag() (tag *Tag, err error) {
+func (p *SimpleParser) NextTag() (*Tag, error) {
+	tag, err := p.nextTag()
+	if err != nil || tag == nil {
+		return tag, err
+	}
+
+	if tag.Tag.IsPrivateCreator() && !tag.HasUndefinedLength() {
+		if err = p.recordPrivateCreator(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	return tag, nil
+}
+
+func (p *SimpleParser) nextTag() (tag *Tag, err error) {
 	err = p.skipPreviousValue()
 	if err != nil {
 		return nil, err
@@ -111,8 +210,18 @@ func (p *SimpleParser) NextTag() (tag *Tag, err error) {
 
 	p.previousTag = tag
 
+	if p.datasets == nil {
+		p.enterDataSet(noEnd)
+	}
+	p.leaveDataSets(tag.Offset)
+	tag.PrivateCreator = p.privateCreator(tag.Tag)
+
 	// we leave the stream at the beginning of the value, so:
-	defer func() { tag.ValueOffset = p.GetPosition() }()
+	defer func() {
+		if tag != nil {
+			tag.ValueOffset = p.GetPosition()
+		}
+	}()
 
 	if tag.Tag.HasVR() && p.ts.VR() == dcm.Explicit {
 
@@ -131,8 +240,8 @@ func (p *SimpleParser) NextTag() (tag *Tag, err error) {
 		}
 
 		if !tag.VR.Long {
+			// short lengths can't be undefined
 			tag.ValueLength = int32(vallen)
-			// TODO: make sure vallen != -1
 			tag.Value = io.LimitReader(p.in, int64(vallen))
 			return tag, nil
 		}
@@ -140,10 +249,9 @@ func (p *SimpleParser) NextTag() (tag *Tag, err error) {
 		// if header is long, the previous 2 bytes were actually just
 		// meaningless filler and we go on to do the 4-byte length
 
-	} else {
+	} else if tag.Tag.HasVR() {
 		// implicit vr, have to guess:
-		// TODO: track private creator uids
-		vr := dcm.VRForTag("", tag.Tag)
+		vr := dcm.VRForTag(tag.PrivateCreator, tag.Tag)
 		tag.VR = &vr
 	}
 
@@ -155,7 +263,64 @@ func (p *SimpleParser) NextTag() (tag *Tag, err error) {
 	}
 
 	tag.ValueLength = vallen
-	// TODO: make sure vallen != -1
+
+	switch {
+	case tag.Tag == dcm.Item && p.fragments:
+		// items in encapsulated data hold the fragments themselves
+		if tag.HasUndefinedLength() {
+			return nil, fmt.Errorf("Fragment at offset %d has "+
+				"undefined length", tag.Offset)
+		}
+
+	case tag.Tag == dcm.SequenceDelimitationItem:
+		p.fragments = false
+		if n := len(p.sequences); n > 0 {
+			p.ts = p.sequences[n-1]
+			p.sequences = p.sequences[:n-1]
+		}
+
+	case tag.Tag == dcm.ItemDelimitationItem:
+		if len(p.datasets) > 1 {
+			p.datasets = p.datasets[:len(p.datasets)-1]
+		}
+
+	case tag.HasUndefinedLength() && dcm.VREq(tag.VR, &dcm.UN):
+		// An unknown element with undefined length can only be a sequence,
+		// and its content is in implicit vr little endian until the
+		// SequenceDelimitationItem (see PS 3.5, 6.2.2).
+		tag.VR = &dcm.SQ
+		p.sequences = append(p.sequences, p.ts)
+		p.ts = dcm.ImplicitVRLittleEndian
+
+	case tag.HasUndefinedLength() && dcm.VREq(tag.VR, &dcm.SQ):
+		p.sequences = append(p.sequences, p.ts)
+
+	case tag.HasUndefinedLength() && tag.Tag != dcm.Item &&
+		!dcm.VREq(tag.VR, &dcm.SQ):
+		// Encapsulated data (typically PixelData): a sequence of items
+		// holding fragments, terminated by a SequenceDelimitationItem.
+		p.fragments = true
+		p.sequences = append(p.sequences, p.ts)
+		tag.Value = bytes.NewReader(nil)
+		return tag, nil
+	}
+
+	if !p.fragments && tag.Tag == dcm.Item {
+		if tag.HasUndefinedLength() {
+			p.enterDataSet(noEnd)
+		} else {
+			p.enterDataSet(p.GetPosition() + uint64(vallen))
+		}
+	}
+
+	if !p.fragments && (tag.Tag == dcm.Item || dcm.VREq(tag.VR, &dcm.SQ)) {
+		// The value of a sequence or item consists of more elements, which
+		// are returned by subsequent calls.  See Build for how to put them
+		// back together.
+		tag.Value = bytes.NewReader(nil)
+		return tag, nil
+	}
+
 	tag.Value = io.LimitReader(p.in, int64(vallen))
 
 	return tag, nil
@@ -179,8 +344,11 @@ type Part10Parser struct {
 	ts     dcm.TransferSyntax
 }
 
+// GetPosition returns the position of the current parser.
+// If the data set is deflated, positions after the file meta information
+// count uncompressed bytes.
 func (p *Part10Parser) GetPosition() uint64 {
-	return p.basein.position
+	return p.parser.GetPosition()
 }
 
 func (p *Part10Parser) NextTag() (tag *Tag, err error) {
@@ -234,9 +402,19 @@ func (p *Part10Parser) NextTag() (tag *Tag, err error) {
 				p.ts = dcm.ExplicitVRLittleEndian
 			}
 
+			basein := p.basein
+			if p.ts.Deflation() == dcm.Deflated {
+				// the rest of the stream is compressed, without a
+				// zlib header (see PS 3.5, A.5)
+				basein = &positionReader{
+					position: p.basein.position,
+					in:       flate.NewReader(p.basein),
+				}
+			}
+
 			p.parser = SimpleParser{
-				basein: p.basein,
-				in:     p.basein,
+				basein: basein,
+				in:     basein,
 				ts:     p.ts,
 			}
 
@@ -250,7 +428,7 @@ func (p *Part10Parser) NextTag() (tag *Tag, err error) {
 				return tag, err
 			}
 
-			// TODO: learn how buf.String() handles charsets
+			// UIDs are always ASCII, regardless of SpecificCharacterSet
 			// TODO: factor this out into a better place
 			tsstr := strings.Trim(buf.String(), " \x00")
 
@@ -2,7 +2,9 @@ package dcmio
 
 import (
 	"bytes"
+	bin "encoding/binary"
 	"github.com/jeremyhuiskamp/dcm/dcm"
+	"io/ioutil"
 	"testing"
 )
 
@@ -50,8 +52,7 @@ func assertElement(t *testing.T, el *Tag, offset uint64, tag dcm.Tag,
 		t.Fatalf("Wrong tag: %s (expected %s", el.Tag, tag)
 	}
 
-	// TODO: this blows up for nil
-	if *el.VR != *vr {
+	if !dcm.VREq(el.VR, vr) {
 		t.Fatalf("Wrong vr for %s: %v (expected %v)", el.Tag, el.VR, vr)
 	}
 
@@ -116,3 +117,179 @@ func TestPart10Ivrle(t *testing.T) {
 	assertNextElement(t, p, 170, dcm.PatientID, &dcm.LO, 178, 4)
 	assertNoMoreElements(t, p)
 }
+
+func TestUndefinedLengthSequence(t *testing.T) {
+	p := NewStreamParser(bytes.NewBuffer([]byte{
+		0x08, 0x00, 0x15, 0x11, 'S', 'Q', 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x20, 0x00, 0x0E, 0x00, 'U', 'I', 0x04, 0x00,
+		'1', '.', '2', 0x00,
+		0xFE, 0xFF, 0x0D, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0x10, 0x00, 0x20, 0x00, 'L', 'O', 0x04, 0x00,
+		'p', 'i', 'd', ' ',
+	}), dcm.ExplicitVRLittleEndian)
+
+	assertNextElement(t, p, 0, dcm.ReferencedSeriesSequence, &dcm.SQ, 12, UndefinedLength)
+	assertNextStructure(t, p, 12, dcm.Item, 20, UndefinedLength)
+	assertNextElement(t, p, 20, dcm.SeriesInstanceUID, &dcm.UI, 28, 4)
+	assertNextStructure(t, p, 32, dcm.ItemDelimitationItem, 40, 0)
+	assertNextStructure(t, p, 40, dcm.SequenceDelimitationItem, 48, 0)
+	assertNextElement(t, p, 48, dcm.PatientID, &dcm.LO, 56, 4)
+	assertNoMoreElements(t, p)
+}
+
+func TestUndefinedLengthUnknownIsSequence(t *testing.T) {
+	p := NewStreamParser(bytes.NewBuffer([]byte{
+		0x09, 0x00, 0x10, 0x10, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+	}), dcm.ImplicitVRLittleEndian)
+
+	assertNextElement(t, p, 0, dcm.Tag(0x00091010), &dcm.SQ, 8, UndefinedLength)
+	assertNextStructure(t, p, 8, dcm.Item, 16, 0)
+	assertNextStructure(t, p, 16, dcm.SequenceDelimitationItem, 24, 0)
+	assertNoMoreElements(t, p)
+}
+
+func TestUndefinedLengthUnknownIsImplicit(t *testing.T) {
+	// the content of the sequence is implicit vr little endian, even
+	// though the rest is explicit vr big endian:
+	p := NewStreamParser(bytes.NewBuffer([]byte{
+		0x00, 0x09, 0x10, 0x10, 'U', 'N', 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x10, 0x00, 0x20, 0x00, 0x04, 0x00, 0x00, 0x00,
+		'p', 'i', 'd', ' ',
+		0xFE, 0xFF, 0x0D, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x10, 0x00, 0x10, 'P', 'N', 0x00, 0x04,
+		'a', 'b', 'c', 'd',
+	}), dcm.ExplicitVRBigEndian)
+
+	assertNextElement(t, p, 0, dcm.Tag(0x00091010), &dcm.SQ, 12, UndefinedLength)
+	assertNextStructure(t, p, 12, dcm.Item, 20, UndefinedLength)
+
+	el, err := p.NextTag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertElement(t, el, 20, dcm.PatientID, &dcm.LO, 28, 4)
+	if el.ByteOrder != bin.LittleEndian {
+		t.Fatalf("expected little endian content, got %s", el.ByteOrder)
+	}
+
+	assertNextStructure(t, p, 32, dcm.ItemDelimitationItem, 40, 0)
+	assertNextStructure(t, p, 40, dcm.SequenceDelimitationItem, 48, 0)
+	assertNextElement(t, p, 48, dcm.PatientName, &dcm.PN, 56, 4)
+	assertNoMoreElements(t, p)
+}
+
+func TestEncapsulatedFragments(t *testing.T) {
+	p := NewStreamParser(bytes.NewBuffer([]byte{
+		0xE0, 0x7F, 0x10, 0x00, 'O', 'B', 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0x00, 0xE0, 0x04, 0x00, 0x00, 0x00,
+		0x01, 0x02, 0x03, 0x04,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0x10, 0x00, 0x20, 0x00, 'L', 'O', 0x04, 0x00,
+		'p', 'i', 'd', ' ',
+	}), dcm.ExplicitVRLittleEndian)
+
+	assertNextElement(t, p, 0, dcm.PixelData, &dcm.OB, 12, UndefinedLength)
+	assertNextStructure(t, p, 12, dcm.Item, 20, 0)
+
+	fragment := assertNextStructure(t, p, 20, dcm.Item, 28, 4)
+	if got, err := ioutil.ReadAll(fragment.Value); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Fatalf("unexpected fragment: % X", got)
+	}
+
+	assertNextStructure(t, p, 32, dcm.SequenceDelimitationItem, 40, 0)
+	assertNextElement(t, p, 40, dcm.PatientID, &dcm.LO, 48, 4)
+	assertNoMoreElements(t, p)
+}
+
+// assertNextStructure checks for items and delimiters, which have no vr
+func assertNextStructure(t *testing.T, p Parser, offset uint64,
+	tag dcm.Tag, valueOffset uint64, valueLength int32) *Tag {
+	el, err := p.NextTag()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if el == nil {
+		t.Fatal("element == nil")
+	}
+
+	if el.VR != nil {
+		t.Fatalf("unexpected vr for %s: %v", el.Tag, el.VR)
+	}
+
+	assertElement(t, el, offset, tag, el.VR, valueOffset, valueLength)
+
+	return el
+}
+
+func TestPrivateCreators(t *testing.T) {
+	p := NewStreamParser(bytes.NewBuffer([]byte{
+		0x09, 0x00, 0x10, 0x00, 0x04, 0x00, 0x00, 0x00,
+		'A', 'C', 'M', 'E',
+		0x09, 0x00, 0x01, 0x10, 0x02, 0x00, 0x00, 0x00,
+		0x01, 0x00,
+		0x09, 0x00, 0x02, 0x10, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0x14, 0x00, 0x00, 0x00,
+		// the item reserves the same block for another creator:
+		0x09, 0x00, 0x10, 0x00, 0x04, 0x00, 0x00, 0x00,
+		'B', 'E', 'T', 'A',
+		0x09, 0x00, 0x01, 0x10, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0x09, 0x00, 0x03, 0x10, 0x00, 0x00, 0x00, 0x00,
+	}), dcm.ImplicitVRLittleEndian)
+
+	for _, exp := range []struct {
+		tag     dcm.Tag
+		vr      *dcm.VR
+		creator string
+	}{
+		{dcm.Tag(0x00090010), &dcm.LO, ""},
+		{dcm.Tag(0x00091001), &dcm.UN, "ACME"},
+		{dcm.Tag(0x00091002), &dcm.SQ, "ACME"},
+		{dcm.Item, nil, ""},
+		{dcm.Tag(0x00090010), &dcm.LO, ""},
+		{dcm.Tag(0x00091001), &dcm.UN, "BETA"},
+		{dcm.SequenceDelimitationItem, nil, ""},
+		{dcm.Tag(0x00091003), &dcm.UN, "ACME"},
+	} {
+		tag, err := p.NextTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tag == nil {
+			t.Fatalf("expected %s", exp.tag)
+		}
+
+		if tag.Tag != exp.tag || !dcm.VREq(tag.VR, exp.vr) ||
+			tag.PrivateCreator != exp.creator {
+			t.Fatalf("expected %s %v %q, got %s %v %q",
+				exp.tag, exp.vr, exp.creator,
+				tag.Tag, tag.VR, tag.PrivateCreator)
+		}
+
+		if tag.Tag.IsPrivateCreator() {
+			// the value is still available after the parser reads it
+			value, err := ioutil.ReadAll(tag.Value)
+			if err != nil || len(value) != 4 {
+				t.Fatalf("unexpected private creator value %q, %v",
+					value, err)
+			}
+		}
+	}
+
+	assertNoMoreElements(t, p)
+}
@@ -0,0 +1,260 @@
+package dcmio
+
+import (
+	"bytes"
+	"compress/flate"
+	bin "encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+// Encoder writes dicom objects to a stream, always using a given
+// transfer syntax
+type Encoder struct {
+	out io.Writer
+	ts  dcm.TransferSyntax
+	// charset is that of the object being encoded, or nil for the default
+	charset *dcm.CharacterSet
+}
+
+// Construct a new Encoder for a stream without a part-10 header.
+// This is the inverse of NewStreamParser.
+func NewStreamEncoder(out io.Writer, ts dcm.TransferSyntax) *Encoder {
+	return &Encoder{out: out, ts: ts}
+}
+
+// Encode writes all the elements of the object to the stream, in tag order.
+// Group length elements are not written.
+//
+// Text values are encoded from UTF-8 with the object's SpecificCharacterSet,
+// or that of the enclosing object for items that don't have one.
+func (e *Encoder) Encode(obj dcm.Object) (err error) {
+	if obj.Get(dcm.SpecificCharacterSet) != nil {
+		cs, err := obj.CharacterSet()
+		if err != nil {
+			// as when building, an unknown character set falls back
+			// to the default
+			cs = dcm.DefaultCharacterSet
+		}
+
+		defer func(prev *dcm.CharacterSet) { e.charset = prev }(e.charset)
+		e.charset = cs
+	}
+
+	obj.ForEach(func(tag dcm.Tag, el dcm.Element) bool {
+		if tag.IsGroupLength() {
+			// these are optional (and mostly retired), and we can't
+			// trust the value anyway if the object has been modified
+			return true
+		}
+
+		err = e.EncodeElement(el)
+		return err == nil
+	})
+
+	return err
+}
+
+// undefinedLength is used for the sequences and items that we write, so
+// that we don't have to calculate their lengths up front
+const undefinedLength uint32 = 0xFFFFFFFF
+
+// EncodeElement writes a single element to the stream.
+// Sequences, their items and encapsulated data are written with undefined
+// lengths.
+func (e *Encoder) EncodeElement(el dcm.Element) error {
+	switch el := el.(type) {
+	case dcm.SimpleElement:
+		cs := e.charset
+		if cs == nil {
+			cs = dcm.DefaultCharacterSet
+		}
+
+		el, err := cs.EncodeElement(el.WithByteOrder(e.ts.ByteOrder()))
+		if err != nil {
+			return err
+		}
+
+		err = e.writeHeader(el.Tag, el.VR, uint32(paddedLen(el.Data)))
+		if err != nil {
+			return err
+		}
+
+		return e.writeValue(el.Data, el.VR)
+
+	case dcm.SequenceElement:
+		err := e.writeHeader(el.Tag, dcm.SQ, undefinedLength)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range el.Objects {
+			err = e.writeHeader(dcm.Item, dcm.UN, undefinedLength)
+			if err != nil {
+				return err
+			}
+
+			if err = e.Encode(item); err != nil {
+				return err
+			}
+
+			err = e.writeHeader(dcm.ItemDelimitationItem, dcm.UN, 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		return e.writeHeader(dcm.SequenceDelimitationItem, dcm.UN, 0)
+
+	case dcm.EncapsulatedElement:
+		err := e.writeHeader(el.Tag, el.VR, undefinedLength)
+		if err != nil {
+			return err
+		}
+
+		// the first fragment is the basic offset table, which must be
+		// present even if empty:
+		fragments := el.Data
+		if len(fragments) == 0 {
+			fragments = [][]byte{nil}
+		}
+
+		for _, fragment := range fragments {
+			err = e.writeHeader(dcm.Item, dcm.UN, uint32(paddedLen(fragment)))
+			if err != nil {
+				return err
+			}
+
+			if err = e.writeValue(fragment, el.VR); err != nil {
+				return err
+			}
+		}
+
+		return e.writeHeader(dcm.SequenceDelimitationItem, dcm.UN, 0)
+
+	default:
+		return fmt.Errorf("unable to write element %s of type %T", el, el)
+	}
+}
+
+// paddedLen gives the length of the data once padded to an even length
+func paddedLen(data []byte) int {
+	return len(data) + len(data)%2
+}
+
+func (e *Encoder) writeValue(data []byte, vr dcm.VR) error {
+	_, err := e.out.Write(data)
+	if err != nil || len(data)%2 == 0 {
+		return err
+	}
+
+	_, err = e.out.Write([]byte{vr.Padding})
+	return err
+}
+
+func (e *Encoder) writeHeader(tag dcm.Tag, vr dcm.VR, length uint32) error {
+	order := e.ts.ByteOrder()
+
+	var header [12]byte
+	order.PutUint16(header[0:2], tag.Group())
+	order.PutUint16(header[2:4], tag.Element())
+
+	if !tag.HasVR() || e.ts.VR() == dcm.Implicit {
+		order.PutUint32(header[4:8], length)
+		_, err := e.out.Write(header[:8])
+		return err
+	}
+
+	copy(header[4:6], vr.Name)
+
+	if !vr.Long {
+		if length > 0xFFFF {
+			return fmt.Errorf("value of %s is too long for vr %s: %d",
+				tag, vr, length)
+		}
+
+		order.PutUint16(header[6:8], uint16(length))
+		_, err := e.out.Write(header[:8])
+		return err
+	}
+
+	// long header: 2 reserved bytes, then a 4-byte length
+	order.PutUint32(header[8:12], length)
+	_, err := e.out.Write(header[:12])
+	return err
+}
+
+// WriteFile writes a part-10 file containing the given object.
+// This is the inverse of building an object from NewFileParser.
+//
+// The file meta information (group 0002) is taken from the object and always
+// written in Explicit VR Little Endian, with FileMetaInformationGroupLength
+// calculated from the other group 0002 elements.  The rest of the object is
+// written in the transfer syntax named by TransferSyntaxUID, which must be
+// present.  If that transfer syntax is deflated, the rest of the object is
+// compressed.
+func WriteFile(out io.Writer, obj dcm.Object) error {
+	tsuid := strings.Trim(obj.GetString(dcm.TransferSyntaxUID), " \x00")
+	if tsuid == "" {
+		return errors.New("Object has no TransferSyntaxUID")
+	}
+
+	meta := dcm.NewObject()
+	data := dcm.NewObject()
+	obj.ForEach(func(tag dcm.Tag, el dcm.Element) bool {
+		if tag.IsFileMetaInfoElement() {
+			meta.Put(el)
+		} else {
+			data.Put(el)
+		}
+		return true
+	})
+
+	var metabuf bytes.Buffer
+	metaenc := NewStreamEncoder(&metabuf, dcm.ExplicitVRLittleEndian)
+	if err := metaenc.Encode(meta); err != nil {
+		return err
+	}
+
+	var preamble [132]byte
+	copy(preamble[128:], "DICM")
+	if _, err := out.Write(preamble[:]); err != nil {
+		return err
+	}
+
+	var fmiLength [4]byte
+	bin.LittleEndian.PutUint32(fmiLength[:], uint32(metabuf.Len()))
+	metaenc.out = out
+	err := metaenc.EncodeElement(dcm.SimpleElement{
+		Tag:  dcm.FileMetaInformationGroupLength,
+		VR:   dcm.UL,
+		Data: fmiLength[:],
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := metabuf.WriteTo(out); err != nil {
+		return err
+	}
+
+	ts := dcm.GetTransferSyntax(tsuid)
+	if ts.Deflation() != dcm.Deflated {
+		return NewStreamEncoder(out, ts).Encode(data)
+	}
+
+	deflater, err := flate.NewWriter(out, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+
+	if err = NewStreamEncoder(deflater, ts).Encode(data); err != nil {
+		return err
+	}
+
+	return deflater.Close()
+}
@@ -0,0 +1,426 @@
+package dcmio
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+// same content as TestPart10Ivrle
+func TestWriteFileIvrle(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.TransferSyntaxUID,
+		VR:   dcm.UI,
+		Data: []byte(dcm.ImplicitVRLittleEndian.UID()),
+	})
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientID,
+		VR:   dcm.LO,
+		Data: []byte("pid"),
+	})
+
+	var buf bytes.Buffer
+	if err := WriteFile(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := combine(part10header,
+		[]byte{
+			0x02, 0x00, 0x00, 0x00, 0x55, 0x4C, 0x04, 0x00,
+			0x1A, 0x00, 0x00, 0x00, 0x02, 0x00, 0x10, 0x00,
+			0x55, 0x49, 0x12, 0x00, 0x31, 0x2E, 0x32, 0x2E,
+			0x38, 0x34, 0x30, 0x2E, 0x31, 0x30, 0x30, 0x30,
+			0x38, 0x2E, 0x31, 0x2E, 0x32, 0x00, 0x10, 0x00,
+			0x20, 0x00, 0x04, 0x00, 0x00, 0x00, 0x70, 0x69,
+			0x64, 0x20,
+		})
+
+	if !bytes.Equal(exp, buf.Bytes()) {
+		t.Fatalf("unexpected file content:\n% X\nexpected:\n% X",
+			buf.Bytes(), exp)
+	}
+}
+
+func TestWriteFileRoundTrip(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.FileMetaInformationVersion,
+		VR:   dcm.OB,
+		Data: []byte{0x00, 0x01},
+	})
+	obj.Put(dcm.SimpleElement{
+		Tag: dcm.TransferSyntaxUID,
+		VR:  dcm.UI,
+		// odd length, so include the padding for comparison below:
+		Data: []byte(dcm.ExplicitVRBigEndian.UID() + "\x00"),
+	})
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientName,
+		VR:   dcm.PN,
+		Data: []byte("Doe^John"),
+	})
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.Rows,
+		VR:   dcm.US,
+		Data: []byte{0x02, 0x00},
+	})
+
+	var buf bytes.Buffer
+	if err := WriteFile(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewFileParser(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertNextElement(t, p, 132, dcm.FileMetaInformationGroupLength, &dcm.UL, 140, 4)
+	assertNextElement(t, p, 144, dcm.FileMetaInformationVersion, &dcm.OB, 156, 2)
+	assertNextElement(t, p, 158, dcm.TransferSyntaxUID, &dcm.UI, 166, 20)
+	assertNextElement(t, p, 186, dcm.PatientName, &dcm.PN, 194, 8)
+	assertNextElement(t, p, 202, dcm.Rows, &dcm.US, 210, 2)
+	assertNoMoreElements(t, p)
+
+	var rebuf bytes.Buffer
+	if err := WriteFile(&rebuf, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err = NewFileParser(&rebuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Build(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := got.Ints(dcm.Rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0] != 2 {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+
+	if got = littleEndian(got); !reflect.DeepEqual(obj, got) {
+		t.Fatalf("expected\n%s\ngot\n%s", obj, got)
+	}
+}
+
+func TestWriteFileNoTransferSyntax(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientID,
+		VR:   dcm.LO,
+		Data: []byte("pid "),
+	})
+
+	var buf bytes.Buffer
+	if err := WriteFile(&buf, obj); err == nil {
+		t.Fatal("expected error for missing transfer syntax")
+	}
+}
+
+func TestEncodeSimpleElements(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientID,
+		VR:   dcm.LO,
+		Data: []byte("pid"),
+	})
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PixelData,
+		VR:   dcm.OB,
+		Data: []byte{0x01, 0x02},
+	})
+
+	for _, test := range []struct {
+		ts  dcm.TransferSyntax
+		exp []byte
+	}{
+		{dcm.ImplicitVRLittleEndian, []byte{
+			0x10, 0x00, 0x20, 0x00, 0x04, 0x00, 0x00, 0x00,
+			'p', 'i', 'd', ' ',
+			0xE0, 0x7F, 0x10, 0x00, 0x02, 0x00, 0x00, 0x00,
+			0x01, 0x02,
+		}},
+		{dcm.ExplicitVRLittleEndian, []byte{
+			0x10, 0x00, 0x20, 0x00, 'L', 'O', 0x04, 0x00,
+			'p', 'i', 'd', ' ',
+			0xE0, 0x7F, 0x10, 0x00, 'O', 'B', 0x00, 0x00,
+			0x02, 0x00, 0x00, 0x00,
+			0x01, 0x02,
+		}},
+		{dcm.ExplicitVRBigEndian, []byte{
+			0x00, 0x10, 0x00, 0x20, 'L', 'O', 0x00, 0x04,
+			'p', 'i', 'd', ' ',
+			0x7F, 0xE0, 0x00, 0x10, 'O', 'B', 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x02,
+			0x01, 0x02,
+		}},
+	} {
+		var buf bytes.Buffer
+		if err := NewStreamEncoder(&buf, test.ts).Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(test.exp, buf.Bytes()) {
+			t.Errorf("unexpected encoding for %s:\n% X\nexpected:\n% X",
+				test.ts.UID(), buf.Bytes(), test.exp)
+		}
+	}
+}
+
+func TestEncodeSequence(t *testing.T) {
+	item := dcm.NewObject()
+	item.Put(dcm.SimpleElement{
+		Tag:  dcm.SeriesInstanceUID,
+		VR:   dcm.UI,
+		Data: []byte("1.2"),
+	})
+
+	obj := dcm.NewObject()
+	obj.Put(dcm.SequenceElement{
+		Tag:     dcm.ReferencedSeriesSequence,
+		Objects: []dcm.Object{item},
+	})
+
+	var buf bytes.Buffer
+	err := NewStreamEncoder(&buf, dcm.ExplicitVRLittleEndian).Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []byte{
+		0x08, 0x00, 0x15, 0x11, 'S', 'Q', 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x20, 0x00, 0x0E, 0x00, 'U', 'I', 0x04, 0x00,
+		'1', '.', '2', 0x00,
+		0xFE, 0xFF, 0x0D, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	if !bytes.Equal(exp, buf.Bytes()) {
+		t.Fatalf("unexpected encoding:\n% X\nexpected:\n% X",
+			buf.Bytes(), exp)
+	}
+}
+
+func TestEncodeEncapsulated(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.EncapsulatedElement{
+		Tag:  dcm.PixelData,
+		VR:   dcm.OB,
+		Data: [][]byte{nil, {0x01, 0x02, 0x03}},
+	})
+
+	var buf bytes.Buffer
+	err := NewStreamEncoder(&buf, dcm.ExplicitVRLittleEndian).Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []byte{
+		0xE0, 0x7F, 0x10, 0x00, 'O', 'B', 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0xFE, 0xFF, 0x00, 0xE0, 0x00, 0x00, 0x00, 0x00,
+		0xFE, 0xFF, 0x00, 0xE0, 0x04, 0x00, 0x00, 0x00,
+		0x01, 0x02, 0x03, 0x00,
+		0xFE, 0xFF, 0xDD, 0xE0, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	if !bytes.Equal(exp, buf.Bytes()) {
+		t.Fatalf("unexpected encoding:\n% X\nexpected:\n% X",
+			buf.Bytes(), exp)
+	}
+}
+
+func TestEncodeShortValueTooLong(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientComments,
+		VR:   dcm.LT,
+		Data: make([]byte, 0x10000),
+	})
+
+	var buf bytes.Buffer
+	err := NewStreamEncoder(&buf, dcm.ExplicitVRLittleEndian).Encode(obj)
+	if err == nil {
+		t.Fatal("expected error for over-long value")
+	}
+
+	// but implicit vr has room for it:
+	buf.Reset()
+	err = NewStreamEncoder(&buf, dcm.ImplicitVRLittleEndian).Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteFileDeflated(t *testing.T) {
+	item := dcm.NewObject()
+	item.Put(dcm.SimpleElement{
+		Tag:  dcm.SeriesInstanceUID,
+		VR:   dcm.UI,
+		Data: []byte("1.2\x00"),
+	})
+
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.TransferSyntaxUID,
+		VR:   dcm.UI,
+		Data: []byte(dcm.DeflatedExplicitVRLittleEndian.UID()),
+	})
+	obj.Put(dcm.SequenceElement{
+		Tag:     dcm.ReferencedSeriesSequence,
+		Objects: []dcm.Object{item},
+	})
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientComments,
+		VR:   dcm.LT,
+		Data: []byte(strings.Repeat("squash me ", 100)),
+	})
+
+	var buf bytes.Buffer
+	if err := WriteFile(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("squash me squash me")) {
+		t.Fatal("data set was not compressed")
+	}
+
+	p, err := NewFileParser(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// positions after group 2 are uncompressed:
+	assertNextElement(t, p, 132, dcm.FileMetaInformationGroupLength, &dcm.UL, 140, 4)
+	assertNextElement(t, p, 144, dcm.TransferSyntaxUID, &dcm.UI, 152, 22)
+	assertNextElement(t, p, 174, dcm.ReferencedSeriesSequence, &dcm.SQ, 186, UndefinedLength)
+	assertNextStructure(t, p, 186, dcm.Item, 194, UndefinedLength)
+
+	p, err = NewFileParser(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Build(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(obj, got) {
+		t.Fatalf("expected\n%s\ngot\n%s", obj, got)
+	}
+}
+
+func TestEncodeCharacterSetRoundTrip(t *testing.T) {
+	item := dcm.NewObject()
+	item.Put(dcm.SimpleElement{
+		Tag:  dcm.PersonName,
+		VR:   dcm.PN,
+		Data: []byte("山田"),
+	})
+
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.SpecificCharacterSet,
+		VR:   dcm.CS,
+		Data: []byte("\\ISO 2022 IR 87 "),
+	})
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientName,
+		VR:   dcm.PN,
+		Data: []byte("Yamada^Tarou=山田^太郎"),
+	})
+	obj.Put(dcm.SequenceElement{
+		Tag:     dcm.ContentSequence,
+		Objects: []dcm.Object{item},
+	})
+
+	var buf bytes.Buffer
+	err := NewStreamEncoder(&buf, dcm.ExplicitVRLittleEndian).Encode(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the item inherits the character set:
+	for _, exp := range []string{
+		"Yamada^Tarou=\x1b$B;3ED\x1b(B^\x1b$BB@O:\x1b(B",
+		"PN\x0A\x00\x1b$B;3ED\x1b(B",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(exp)) {
+			t.Errorf("expected encoding to contain %q:\n% X",
+				exp, buf.Bytes())
+		}
+	}
+
+	got, err := Build(NewStreamParser(&buf, dcm.ExplicitVRLittleEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(obj, got) {
+		t.Fatalf("expected\n%s\ngot\n%s", obj, got)
+	}
+}
+
+func TestEncodeCharacterSetUnencodable(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{
+		Tag:  dcm.PatientName,
+		VR:   dcm.PN,
+		Data: []byte("山田"),
+	})
+
+	var buf bytes.Buffer
+	err := NewStreamEncoder(&buf, dcm.ExplicitVRLittleEndian).Encode(obj)
+	if err == nil {
+		t.Fatal("expected error writing kanji without a character set")
+	}
+}
+
+func TestWriteFileUndeclaredCharacterSet(t *testing.T) {
+	// as in TestPart10Ivrle, but PatientID is ISO 8859-1 without a
+	// SpecificCharacterSet:
+	file := combine(part10header,
+		[]byte{
+			0x02, 0x00, 0x00, 0x00, 0x55, 0x4C, 0x04, 0x00,
+			0x1A, 0x00, 0x00, 0x00, 0x02, 0x00, 0x10, 0x00,
+			0x55, 0x49, 0x12, 0x00, 0x31, 0x2E, 0x32, 0x2E,
+			0x38, 0x34, 0x30, 0x2E, 0x31, 0x30, 0x30, 0x30,
+			0x38, 0x2E, 0x31, 0x2E, 0x32, 0x00, 0x10, 0x00,
+			0x20, 0x00, 0x04, 0x00, 0x00, 0x00, 0x70, 0xE9,
+			0x64, 0x20,
+		})
+
+	p, err := NewFileParser(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := Build(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFile(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(file, buf.Bytes()) {
+		t.Fatalf("unexpected file content:\n% X\nexpected:\n% X",
+			buf.Bytes(), file)
+	}
+}
@@ -0,0 +1,414 @@
+// Package dcmjson converts dicom objects to and from the DICOM JSON Model,
+// as used by DICOMweb.  See PS 3.18, Annex F.
+package dcmjson
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+// element is an attribute of the JSON model.  Only one of Value,
+// InlineBinary and BulkDataURI is present, or none if the value is empty.
+type element struct {
+	VR           string            `json:"vr"`
+	Value        []json.RawMessage `json:"Value,omitempty"`
+	InlineBinary []byte            `json:"InlineBinary,omitempty"`
+	BulkDataURI  string            `json:"BulkDataURI,omitempty"`
+}
+
+// personName is the value of a PN attribute
+type personName struct {
+	Alphabetic  string `json:"Alphabetic,omitempty"`
+	Ideographic string `json:"Ideographic,omitempty"`
+	Phonetic    string `json:"Phonetic,omitempty"`
+}
+
+// Encoder converts objects to JSON.
+type Encoder struct {
+	// BulkDataURI, if set, is called for the binary elements (OB, OW,
+	// UN, etc, including encapsulated data).  If it returns a URI, that is
+	// written instead of the value.  Otherwise, the value is written
+	// inline, which isn't possible for encapsulated data.
+	BulkDataURI func(el dcm.Element) string
+}
+
+// Decoder converts JSON to objects.
+type Decoder struct {
+	// BulkData, if set, retrieves the values of elements that are given
+	// by BulkDataURI.  Otherwise, they are an error.
+	BulkData func(uri string) ([]byte, error)
+}
+
+// Marshal converts an object to JSON, with binary values written inline.
+func Marshal(obj dcm.Object) ([]byte, error) {
+	return Encoder{}.Marshal(obj)
+}
+
+// Unmarshal converts JSON to an object, which must not have elements
+// with BulkDataURI.
+func Unmarshal(data []byte) (dcm.Object, error) {
+	return Decoder{}.Unmarshal(data)
+}
+
+// Marshal converts an object to JSON.  Text values are written as they
+// are held in memory (UTF-8), and group length elements are left out.
+func (e Encoder) Marshal(obj dcm.Object) ([]byte, error) {
+	attrs, err := e.object(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(attrs)
+}
+
+// tagKey formats a tag as an attribute name, eg 0020000D
+func tagKey(tag dcm.Tag) string {
+	return fmt.Sprintf("%08X", uint32(tag))
+}
+
+func (e Encoder) object(obj dcm.Object) (attrs map[string]element, err error) {
+	attrs = make(map[string]element)
+
+	obj.ForEach(func(tag dcm.Tag, el dcm.Element) bool {
+		if tag.IsGroupLength() {
+			return true
+		}
+
+		var attr element
+		attr, err = e.element(obj, el)
+		if err != nil {
+			err = fmt.Errorf("unable to convert %s to json: %s", el, err)
+			return false
+		}
+
+		attrs[tagKey(tag)] = attr
+		return true
+	})
+
+	return attrs, err
+}
+
+func (e Encoder) element(obj dcm.Object, el dcm.Element) (attr element, err error) {
+	switch el := el.(type) {
+	case dcm.SequenceElement:
+		attr.VR = dcm.SQ.Name
+		for _, item := range el.Objects {
+			itemAttrs, err := e.object(item)
+			if err != nil {
+				return attr, err
+			}
+
+			if err = attr.appendValue(itemAttrs); err != nil {
+				return attr, err
+			}
+		}
+		return attr, nil
+
+	case dcm.EncapsulatedElement:
+		attr.VR = el.VR.Name
+		if e.BulkDataURI != nil {
+			attr.BulkDataURI = e.BulkDataURI(el)
+		}
+		if attr.BulkDataURI == "" {
+			return attr, errors.New("encapsulated data can only be bulk data")
+		}
+		return attr, nil
+
+	case dcm.SimpleElement:
+		attr.VR = el.VR.Name
+		if isBinary(el.VR) {
+			if len(el.Data) == 0 {
+				return attr, nil
+			}
+
+			if e.BulkDataURI != nil {
+				attr.BulkDataURI = e.BulkDataURI(el)
+			}
+			if attr.BulkDataURI == "" {
+				attr.InlineBinary = el.WithByteOrder(binary.LittleEndian).Data
+			}
+			return attr, nil
+		}
+
+		values, err := simpleValues(obj, el)
+		if err != nil {
+			return attr, err
+		}
+
+		for _, value := range values {
+			if err = attr.appendValue(value); err != nil {
+				return attr, err
+			}
+		}
+		return attr, nil
+
+	default:
+		return attr, fmt.Errorf("unsupported element type %T", el)
+	}
+}
+
+func (attr *element) appendValue(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	attr.Value = append(attr.Value, data)
+	return nil
+}
+
+// isBinary returns whether values of the VR are written as InlineBinary
+// or BulkDataURI instead of Value
+func isBinary(vr dcm.VR) bool {
+	switch vr.Name {
+	case dcm.OB.Name, dcm.OD.Name, dcm.OF.Name, dcm.OW.Name, dcm.UN.Name:
+		return true
+	default:
+		return false
+	}
+}
+
+// simpleValues gives the JSON values of an element that isn't binary.
+func simpleValues(obj dcm.Object, el dcm.SimpleElement) ([]interface{}, error) {
+	var values []interface{}
+
+	switch el.VR.Name {
+	case dcm.IS.Name, dcm.US.Name, dcm.SS.Name, dcm.UL.Name, dcm.SL.Name:
+		ints, err := obj.Ints(el.Tag)
+		for _, value := range ints {
+			values = append(values, value)
+		}
+		return values, err
+
+	case dcm.DS.Name, dcm.FL.Name, dcm.FD.Name:
+		floats, err := obj.Floats(el.Tag)
+		for _, value := range floats {
+			values = append(values, value)
+		}
+		return values, err
+
+	case dcm.AT.Name:
+		tags, err := obj.Tags(el.Tag)
+		for _, value := range tags {
+			values = append(values, tagKey(value))
+		}
+		return values, err
+	}
+
+	strs, err := obj.Strings(el.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range strs {
+		switch {
+		case value == "":
+			values = append(values, nil)
+
+		case el.VR.Name == dcm.PN.Name:
+			groups := strings.SplitN(value, "=", 3)
+			groups = append(groups, "", "")
+			values = append(values, personName{groups[0], groups[1], groups[2]})
+
+		default:
+			values = append(values, value)
+		}
+	}
+
+	return values, nil
+}
+
+// Unmarshal converts JSON to an object.  Text values are kept in UTF-8, so
+// SpecificCharacterSet should allow for them if the object is to be written
+// out again.
+func (d Decoder) Unmarshal(data []byte) (dcm.Object, error) {
+	var attrs map[string]element
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return dcm.Object{}, err
+	}
+
+	return d.object(attrs)
+}
+
+func (d Decoder) object(attrs map[string]element) (dcm.Object, error) {
+	obj := dcm.NewObject()
+
+	for key, attr := range attrs {
+		tag, err := parseTagKey(key)
+		if err != nil {
+			return obj, err
+		}
+
+		el, err := d.element(tag, attr)
+		if err != nil {
+			return obj, fmt.Errorf("invalid attribute %s: %s", key, err)
+		}
+
+		obj.Put(el)
+	}
+
+	return obj, nil
+}
+
+func parseTagKey(key string) (dcm.Tag, error) {
+	if len(key) != 8 {
+		return 0, fmt.Errorf("invalid attribute name %q", key)
+	}
+
+	value, err := strconv.ParseUint(key, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid attribute name %q", key)
+	}
+
+	return dcm.Tag(value), nil
+}
+
+func (d Decoder) element(tag dcm.Tag, attr element) (dcm.Element, error) {
+	vr := dcm.GetVRByName(attr.VR)
+	if vr.Name != attr.VR {
+		return nil, fmt.Errorf("unknown vr %q", attr.VR)
+	}
+
+	if dcm.VREq(vr, &dcm.SQ) {
+		sq := dcm.SequenceElement{Tag: tag}
+		for _, value := range attr.Value {
+			var itemAttrs map[string]element
+			if err := json.Unmarshal(value, &itemAttrs); err != nil {
+				return nil, err
+			}
+
+			item, err := d.object(itemAttrs)
+			if err != nil {
+				return nil, err
+			}
+
+			sq.Objects = append(sq.Objects, item)
+		}
+		return sq, nil
+	}
+
+	switch {
+	case attr.BulkDataURI != "":
+		if d.BulkData == nil {
+			return nil, fmt.Errorf("unable to retrieve bulk data %s",
+				attr.BulkDataURI)
+		}
+
+		data, err := d.BulkData(attr.BulkDataURI)
+		if err != nil {
+			return nil, err
+		}
+		return dcm.NewElement(tag, vr, data)
+
+	case attr.InlineBinary != nil:
+		return dcm.NewElement(tag, vr, attr.InlineBinary)
+
+	case len(attr.Value) == 0:
+		return dcm.SimpleElement{Tag: tag, VR: *vr}, nil
+	}
+
+	value, err := parseValues(*vr, attr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return dcm.NewElement(tag, vr, value)
+}
+
+// parseValues converts JSON values to a value for dcm.NewElement
+func parseValues(vr dcm.VR, values []json.RawMessage) (interface{}, error) {
+	switch vr.Name {
+	case dcm.US.Name, dcm.SS.Name, dcm.UL.Name, dcm.SL.Name:
+		ints := make([]int64, len(values))
+		for i, value := range values {
+			if err := json.Unmarshal(value, &ints[i]); err != nil {
+				return nil, err
+			}
+		}
+		return ints, nil
+
+	case dcm.FL.Name, dcm.FD.Name:
+		floats := make([]float64, len(values))
+		for i, value := range values {
+			if err := json.Unmarshal(value, &floats[i]); err != nil {
+				return nil, err
+			}
+		}
+		return floats, nil
+
+	case dcm.AT.Name:
+		tags := make([]dcm.Tag, len(values))
+		for i, value := range values {
+			var key string
+			if err := json.Unmarshal(value, &key); err != nil {
+				return nil, err
+			}
+
+			tag, err := parseTagKey(key)
+			if err != nil {
+				return nil, err
+			}
+			tags[i] = tag
+		}
+		return tags, nil
+	}
+
+	strs := make([]string, len(values))
+	for i, value := range values {
+		str, err := parseString(vr, value)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = str
+	}
+
+	return strs, nil
+}
+
+// parseString converts a JSON value of a text VR to a string.  IS and DS
+// values are numbers, but strings are accepted as well, and numbers are
+// kept as written.
+func parseString(vr dcm.VR, value json.RawMessage) (string, error) {
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(value)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", err
+	}
+
+	switch decoded := decoded.(type) {
+	case nil:
+		return "", nil
+
+	case string:
+		return decoded, nil
+
+	case json.Number:
+		if vr.Name != dcm.IS.Name && vr.Name != dcm.DS.Name {
+			return "", fmt.Errorf("unexpected number %s", decoded)
+		}
+		return decoded.String(), nil
+
+	case map[string]interface{}:
+		if vr.Name != dcm.PN.Name {
+			return "", fmt.Errorf("unexpected object %s", value)
+		}
+
+		var pn personName
+		if err := json.Unmarshal(value, &pn); err != nil {
+			return "", err
+		}
+
+		groups := []string{pn.Alphabetic, pn.Ideographic, pn.Phonetic}
+		return strings.TrimRight(strings.Join(groups, "="), "="), nil
+
+	default:
+		return "", fmt.Errorf("unexpected value %s", value)
+	}
+}
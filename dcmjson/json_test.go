@@ -0,0 +1,166 @@
+package dcmjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+func newElement(t *testing.T, tag dcm.Tag, value interface{}) dcm.Element {
+	el, err := dcm.NewElement(tag, nil, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return el
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	item := dcm.NewObject()
+	item.Put(newElement(t, dcm.SeriesInstanceUID, "1.2.3"))
+
+	obj := dcm.NewObject()
+	obj.Put(newElement(t, dcm.SpecificCharacterSet, "ISO_IR 192"))
+	obj.Put(newElement(t, dcm.ImageType, []string{"ORIGINAL", "", "AXIAL"}))
+	obj.Put(newElement(t, dcm.PatientName, dcm.Name{
+		Alphabetic:  dcm.NameGroup{Family: "Yamada", Given: "Tarou"},
+		Ideographic: dcm.NameGroup{Family: "山田", Given: "太郎"},
+	}))
+	obj.Put(dcm.SimpleElement{Tag: dcm.PatientBirthDate, VR: dcm.DA})
+	obj.Put(newElement(t, dcm.NumberOfFrames, 12))
+	obj.Put(newElement(t, dcm.Rows, 512))
+	obj.Put(newElement(t, dcm.PixelSpacing, []float64{0.5, 0.25}))
+	obj.Put(newElement(t, dcm.FrameIncrementPointer, dcm.FrameTime))
+	obj.Put(newElement(t, dcm.RecommendedDisplayFrameRateInFloat, 2.5))
+	obj.Put(dcm.SequenceElement{
+		Tag:     dcm.ReferencedSeriesSequence,
+		Objects: []dcm.Object{item},
+	})
+	obj.Put(dcm.SimpleElement{Tag: dcm.PixelData, VR: dcm.OW, Data: []byte{1, 2, 3, 4}})
+
+	data, err := Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp bytes.Buffer
+	err = json.Compact(&exp, []byte(`{
+		"00080005": {"vr": "CS", "Value": ["ISO_IR 192"]},
+		"00080008": {"vr": "CS", "Value": ["ORIGINAL", null, "AXIAL"]},
+		"00081115": {"vr": "SQ", "Value": [
+			{"0020000E": {"vr": "UI", "Value": ["1.2.3"]}}
+		]},
+		"00089459": {"vr": "FL", "Value": [2.5]},
+		"00100010": {"vr": "PN", "Value": [
+			{"Alphabetic": "Yamada^Tarou", "Ideographic": "山田^太郎"}
+		]},
+		"00100030": {"vr": "DA"},
+		"00280008": {"vr": "IS", "Value": [12]},
+		"00280009": {"vr": "AT", "Value": ["00181063"]},
+		"00280010": {"vr": "US", "Value": [512]},
+		"00280030": {"vr": "DS", "Value": [0.5, 0.25]},
+		"7FE00010": {"vr": "OW", "InlineBinary": "AQIDBA=="}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(exp.Bytes(), data) {
+		t.Fatalf("unexpected json:\n%s\nexpected:\n%s", data, exp.Bytes())
+	}
+
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(obj, got) {
+		t.Fatalf("expected\n%s\ngot\n%s", obj, got)
+	}
+}
+
+func TestUnmarshalStringNumbers(t *testing.T) {
+	obj, err := Unmarshal([]byte(`{
+		"00280008": {"vr": "IS", "Value": ["12"]},
+		"00280030": {"vr": "DS", "Value": ["0.50", 1e-7]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values, _ := obj.Strings(dcm.PixelSpacing); !reflect.DeepEqual(values, []string{"0.50", "1e-7"}) {
+		t.Fatalf("unexpected values %q", values)
+	}
+
+	if values, _ := obj.Ints(dcm.NumberOfFrames); !reflect.DeepEqual(values, []int64{12}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+}
+
+func TestBulkData(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.EncapsulatedElement{
+		Tag:  dcm.PixelData,
+		VR:   dcm.OB,
+		Data: [][]byte{nil, {1, 2}},
+	})
+
+	if _, err := Marshal(obj); err == nil {
+		t.Fatal("expected error writing encapsulated data inline")
+	}
+
+	encoder := Encoder{BulkDataURI: func(el dcm.Element) string {
+		return "http://example.com/" + el.GetTag().String()
+	}}
+
+	data, err := encoder.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"7FE00010":{"vr":"OB","BulkDataURI":"http://example.com/(7FE0,0010)"}}`
+	if string(data) != exp {
+		t.Fatalf("unexpected json %s", data)
+	}
+
+	if _, err = Unmarshal(data); err == nil {
+		t.Fatal("expected error reading bulk data")
+	}
+
+	decoder := Decoder{BulkData: func(uri string) ([]byte, error) {
+		if uri != "http://example.com/(7FE0,0010)" {
+			return nil, errors.New("unexpected uri " + uri)
+		}
+		return []byte{1, 2}, nil
+	}}
+
+	got, err := decoder.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	el := got.Get(dcm.PixelData)
+	if el == nil || !reflect.DeepEqual(*el, dcm.SimpleElement{
+		Tag: dcm.PixelData, VR: dcm.OB, Data: []byte{1, 2}}) {
+		t.Fatalf("unexpected element %v", el)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, data := range []string{
+		`[]`,
+		`{"0010": {"vr": "LO"}}`,
+		`{"00100020": {"vr": "XX"}}`,
+		`{"00280010": {"vr": "US", "Value": ["512"]}}`,
+		`{"00100020": {"vr": "LO", "Value": [1]}}`,
+		`{"00100020": {"vr": "LO", "Value": [{"Alphabetic": "x"}]}}`,
+		`{"00081115": {"vr": "SQ", "Value": [1]}}`,
+	} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package dcmnet
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -15,91 +16,273 @@ type AssociateRQAC struct {
 	MaxPDULength           uint32
 	ImplementationClassUID string
 	ImplementationVersion  string
-	MaxOperationsInvoked   uint16
-	MaxOperationsPerformed uint16
-	// TODO:
-	// Roles
-	// Extended Negotiation
-	// Common Extended Negotiation
+	// NegotiateAsyncOperations is whether the maximum number of operations
+	// are given.  If not, they are both 1.  Otherwise, 0 means no limit.
+	NegotiateAsyncOperations bool
+	MaxOperationsInvoked     uint16
+	MaxOperationsPerformed   uint16
+	// Roles are proposed in a request and accepted in a response.  There
+	// may be only one per SOP class.
+	Roles                []SOPClassRole
+	ExtendedNegotiations []ExtendedNegotiation
 }
 
 type AssociateRQ struct {
 	AssociateRQAC
 
-	// TODO:
-	// User identity
+	CommonExtendedNegotiations []CommonExtendedNegotiation
+	UserIdentity               *UserIdentity
 }
 
 type AssociateAC struct {
 	AssociateRQAC
 
-	// TODO:
-	// User identity
+	// UserIdentity is only given if the request asked for a positive
+	// response.
+	UserIdentity *UserIdentityResult
 }
 
-// TODO: major amounts of error handling
+// headerLen is the length of the fixed fields of an A-ASSOCIATE-RQ or AC,
+// before the variable items.  See PS 3.8, 9.3.2 and 9.3.3.
+const headerLen = 68
 
-func (rq AssociateRQAC) Write(dst io.Writer) {
-	binary.Write(dst, binary.BigEndian, rq.ProtocolVersion)
-	// padding:
-	binary.Write(dst, binary.BigEndian, uint16(0))
+// Write writes the body of an A-ASSOCIATE-RQ PDU.
+func (rq AssociateRQ) Write(dst io.Writer) error {
+	return rq.write(dst, RequestPresentationContext,
+		func(userInfo *bytes.Buffer) error {
+			for _, cen := range rq.CommonExtendedNegotiations {
+				if err := cen.write(userInfo); err != nil {
+					return err
+				}
+			}
+
+			if rq.UserIdentity != nil {
+				return rq.UserIdentity.write(userInfo)
+			}
+
+			return nil
+		})
+}
+
+// Write writes the body of an A-ASSOCIATE-AC PDU.
+func (ac AssociateAC) Write(dst io.Writer) error {
+	return ac.write(dst, AcceptPresentationContext,
+		func(userInfo *bytes.Buffer) error {
+			if ac.UserIdentity != nil {
+				return ac.UserIdentity.write(userInfo)
+			}
+
+			return nil
+		})
+}
+
+// write writes the fields common to requests and responses.  They differ in
+// the item type of their presentation contexts and in the last sub-items of
+// the user information, which are written by writeSpecific.  An empty
+// application context is written as DICOMApplicationContext.
+func (rqac AssociateRQAC) write(dst io.Writer, pcType ItemType,
+	writeSpecific func(*bytes.Buffer) error) error {
+	buf := bytes.NewBuffer(make([]byte, 0, headerLen))
 
-	fmt.Fprintf(dst, "%-16s", rq.CalledAE)
-	fmt.Fprintf(dst, "%-16s", rq.CallingAE)
+	binary.Write(buf, binary.BigEndian, rqac.ProtocolVersion)
+	// reserved:
+	binary.Write(buf, binary.BigEndian, uint16(0))
 
-	// reserved bytes:
+	for _, ae := range []string{rqac.CalledAE, rqac.CallingAE} {
+		if len(ae) > 16 {
+			return fmt.Errorf("AE title %q is longer than 16 characters", ae)
+		}
+		fmt.Fprintf(buf, "%-16s", ae)
+	}
+
+	// reserved:
 	var reserved [32]byte
-	dst.Write(reserved[:])
+	buf.Write(reserved[:])
+
+	appContext := rqac.ApplicationContext
+	if appContext == "" {
+		appContext = DICOMApplicationContext
+	}
+	if err := writeItem(buf, ApplicationContext, []byte(appContext)); err != nil {
+		return err
+	}
+
+	for _, pc := range rqac.PresentationContexts {
+		var pcbuf bytes.Buffer
+		if err := pc.Write(&pcbuf); err != nil {
+			return err
+		}
 
-	for _, presentationContext := range rq.PresentationContexts {
-		presentationContext.Write(dst)
+		if err := writeItem(buf, pcType, pcbuf.Bytes()); err != nil {
+			return err
+		}
 	}
+
+	var userInfo bytes.Buffer
+	if err := rqac.writeUserInfo(&userInfo); err != nil {
+		return err
+	}
+	if err := writeSpecific(&userInfo); err != nil {
+		return err
+	}
+	if err := writeItem(buf, UserInfo, userInfo.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := buf.WriteTo(dst)
+	return err
 }
 
-func readAE(src io.Reader) string {
+// writeUserInfo writes the sub-items of the user information item.
+// See PS 3.7, D.3.3.
+func (rqac AssociateRQAC) writeUserInfo(dst *bytes.Buffer) error {
+	// the maximum length is mandatory, but may be 0 for no limit:
+	var maxPDULength [4]byte
+	binary.BigEndian.PutUint32(maxPDULength[:], rqac.MaxPDULength)
+	if err := writeItem(dst, MaxPDULength, maxPDULength[:]); err != nil {
+		return err
+	}
+
+	if rqac.ImplementationClassUID != "" {
+		err := writeItem(dst, ImplementationClassUID,
+			[]byte(rqac.ImplementationClassUID))
+		if err != nil {
+			return err
+		}
+	}
+
+	if rqac.NegotiateAsyncOperations {
+		var values [4]byte
+		binary.BigEndian.PutUint16(values[:2], rqac.MaxOperationsInvoked)
+		binary.BigEndian.PutUint16(values[2:], rqac.MaxOperationsPerformed)
+		if err := writeItem(dst, AsyncOperations, values[:]); err != nil {
+			return err
+		}
+	}
+
+	if err := checkRoles(rqac.Roles); err != nil {
+		return err
+	}
+	for _, role := range rqac.Roles {
+		if err := role.write(dst); err != nil {
+			return err
+		}
+	}
+
+	if rqac.ImplementationVersion != "" {
+		if len(rqac.ImplementationVersion) > 16 {
+			return fmt.Errorf("implementation version %q is longer than "+
+				"16 characters", rqac.ImplementationVersion)
+		}
+
+		err := writeItem(dst, ImplementationVersion,
+			[]byte(rqac.ImplementationVersion))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, en := range rqac.ExtendedNegotiations {
+		if err := en.write(dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readAE(src io.Reader) (string, error) {
 	var bytes [16]byte
-	io.ReadFull(src, bytes[:])
-	return strings.TrimSpace(string(bytes[:]))
+	if _, err := io.ReadFull(src, bytes[:]); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes[:])), nil
+}
+
+// Read reads the body of an A-ASSOCIATE-RQ PDU.
+func (rq *AssociateRQ) Read(src io.Reader) error {
+	return rq.read(src, func(item *Item) error {
+		switch item.Type {
+		case SOPClassCommonExtendedNegotiation:
+			var cen CommonExtendedNegotiation
+			if err := cen.read(item.Data); err != nil {
+				return err
+			}
+			rq.CommonExtendedNegotiations = append(
+				rq.CommonExtendedNegotiations, cen)
+
+		case UserIdentityRQ:
+			rq.UserIdentity = &UserIdentity{}
+			return rq.UserIdentity.read(item.Data)
+		}
+
+		return nil
+	})
+}
+
+// Read reads the body of an A-ASSOCIATE-AC PDU.
+func (ac *AssociateAC) Read(src io.Reader) error {
+	return ac.read(src, func(item *Item) error {
+		if item.Type == UserIdentityAC {
+			ac.UserIdentity = &UserIdentityResult{}
+			return ac.UserIdentity.read(item.Data)
+		}
+
+		return nil
+	})
 }
 
+// Read reads the body of an A-ASSOCIATE-RQ or AC PDU, without the
+// sub-items that are specific to either.
 func (rq *AssociateRQAC) Read(src io.Reader) error {
-	binary.Read(src, binary.BigEndian, &rq.ProtocolVersion)
+	return rq.read(src, nil)
+}
 
-	var padding uint16
-	binary.Read(src, binary.BigEndian, &padding)
+// read reads the common fields, passing the sub-items of the user
+// information that it doesn't know to readSpecific, if given.
+func (rq *AssociateRQAC) read(src io.Reader, readSpecific func(*Item) error) (err error) {
+	var header [headerLen]byte
+	if _, err = io.ReadFull(src, header[:4]); err != nil {
+		return err
+	}
+	rq.ProtocolVersion = int16(binary.BigEndian.Uint16(header[:2]))
 
-	rq.CalledAE = readAE(src)
+	if rq.CalledAE, err = readAE(src); err != nil {
+		return err
+	}
 
-	rq.CallingAE = readAE(src)
+	if rq.CallingAE, err = readAE(src); err != nil {
+		return err
+	}
 
-	var reserved [32]byte
-	io.ReadFull(src, reserved[:])
+	// reserved:
+	if _, err = io.ReadFull(src, header[36:]); err != nil {
+		return err
+	}
 
-	return EachItem(src, func(item *Item) error {
+	return EachItem(src, func(item *Item) (err error) {
 		switch item.Type {
 		case ApplicationContext:
-			s, err := readString(item.Data)
-			if err != nil {
-				return err
-			}
-			// hmm, weird, why can't I assign this directly from readString?
-			rq.ApplicationContext = s
+			rq.ApplicationContext, err = readString(item.Data)
+			return err
 
 		case RequestPresentationContext, AcceptPresentationContext:
 			pc := PresentationContext{}
-			pc.Read(item.Data)
+			if err = pc.Read(item.Data); err != nil {
+				return err
+			}
 			rq.PresentationContexts = append(rq.PresentationContexts, pc)
 
-		case UserInfo: // user info
-			return readUserInfo(item.Data, rq)
+		case UserInfo:
+			return readUserInfo(item.Data, rq, readSpecific)
 		}
 
-		// TODO: actual error handling above
 		return nil
 	})
 }
 
-func readUserInfo(src io.Reader, rqac *AssociateRQAC) error {
+func readUserInfo(src io.Reader, rqac *AssociateRQAC,
+	readSpecific func(*Item) error) error {
 	return EachItem(src, func(item *Item) (err error) {
 		switch item.Type {
 		case MaxPDULength:
@@ -124,10 +307,269 @@ func readUserInfo(src io.Reader, rqac *AssociateRQAC) error {
 				return err
 			}
 
+			rqac.NegotiateAsyncOperations = true
 			rqac.MaxOperationsInvoked = binary.BigEndian.Uint16(values[:2])
 			rqac.MaxOperationsPerformed = binary.BigEndian.Uint16(values[2:])
+
+		case RoleSelection:
+			var role SOPClassRole
+			if err = role.read(item.Data); err != nil {
+				return err
+			}
+			rqac.Roles = append(rqac.Roles, role)
+
+		case SOPClassExtendedNegotiation:
+			var en ExtendedNegotiation
+			if err = en.read(item.Data); err != nil {
+				return err
+			}
+			rqac.ExtendedNegotiations = append(rqac.ExtendedNegotiations, en)
+
+		default:
+			if readSpecific != nil {
+				return readSpecific(item)
+			}
 		}
 
 		return nil
 	})
 }
+
+// RJResult is the result field of an A-ASSOCIATE-RJ.
+type RJResult uint8
+
+const (
+	RJPermanent RJResult = 1
+	RJTransient RJResult = 2
+)
+
+func (r RJResult) String() string {
+	switch r {
+	case RJPermanent:
+		return "rejected-permanent"
+	case RJTransient:
+		return "rejected-transient"
+	default:
+		return fmt.Sprintf("RJResult(%d)", uint8(r))
+	}
+}
+
+// RJSource is the source field of an A-ASSOCIATE-RJ, ie, who rejected the
+// association.
+type RJSource uint8
+
+const (
+	RJServiceUser                 RJSource = 1
+	RJServiceProviderACSE         RJSource = 2
+	RJServiceProviderPresentation RJSource = 3
+)
+
+func (s RJSource) String() string {
+	switch s {
+	case RJServiceUser:
+		return "service-user"
+	case RJServiceProviderACSE:
+		return "service-provider (ACSE)"
+	case RJServiceProviderPresentation:
+		return "service-provider (presentation)"
+	default:
+		return fmt.Sprintf("RJSource(%d)", uint8(s))
+	}
+}
+
+// RJReason is the reason/diag field of an A-ASSOCIATE-RJ.  Its meaning
+// depends on the source, so the constants below are grouped by source.
+type RJReason uint8
+
+const (
+	// RJServiceUser:
+	RJNoReasonGiven                  RJReason = 1
+	RJApplicationContextNotSupported RJReason = 2
+	RJCallingAENotRecognized         RJReason = 3
+	RJCalledAENotRecognized          RJReason = 7
+
+	// RJServiceProviderACSE (also RJNoReasonGiven):
+	RJProtocolVersionNotSupported RJReason = 2
+
+	// RJServiceProviderPresentation:
+	RJTemporaryCongestion RJReason = 1
+	RJLocalLimitExceeded  RJReason = 2
+)
+
+// AssociateRJ is the rejection of an association request.
+// See PS 3.8, 9.3.4.
+type AssociateRJ struct {
+	Result RJResult
+	Source RJSource
+	Reason RJReason
+}
+
+func (rj AssociateRJ) reason() string {
+	switch {
+	case rj.Source == RJServiceUser && rj.Reason == RJNoReasonGiven,
+		rj.Source == RJServiceProviderACSE && rj.Reason == RJNoReasonGiven:
+		return "no reason given"
+	case rj.Source == RJServiceUser && rj.Reason == RJApplicationContextNotSupported:
+		return "application context name not supported"
+	case rj.Source == RJServiceUser && rj.Reason == RJCallingAENotRecognized:
+		return "calling AE title not recognized"
+	case rj.Source == RJServiceUser && rj.Reason == RJCalledAENotRecognized:
+		return "called AE title not recognized"
+	case rj.Source == RJServiceProviderACSE && rj.Reason == RJProtocolVersionNotSupported:
+		return "protocol version not supported"
+	case rj.Source == RJServiceProviderPresentation && rj.Reason == RJTemporaryCongestion:
+		return "temporary congestion"
+	case rj.Source == RJServiceProviderPresentation && rj.Reason == RJLocalLimitExceeded:
+		return "local limit exceeded"
+	default:
+		return fmt.Sprintf("reason %d", uint8(rj.Reason))
+	}
+}
+
+// Error allows a rejection to be returned as the error of an association
+// attempt.
+func (rj AssociateRJ) Error() string {
+	return fmt.Sprintf("association %s by %s: %s",
+		rj.Result, rj.Source, rj.reason())
+}
+
+func (rj AssociateRJ) Write(dst io.Writer) error {
+	_, err := dst.Write([]byte{0, byte(rj.Result), byte(rj.Source), byte(rj.Reason)})
+	return err
+}
+
+func (rj *AssociateRJ) Read(src io.Reader) error {
+	var buf [4]byte
+	if _, err := io.ReadFull(src, buf[:]); err != nil {
+		return err
+	}
+
+	rj.Result = RJResult(buf[1])
+	rj.Source = RJSource(buf[2])
+	rj.Reason = RJReason(buf[3])
+
+	return nil
+}
+
+// ReleaseRQ is the request to release an association.  It has no fields.
+// See PS 3.8, 9.3.6.
+type ReleaseRQ struct{}
+
+func (ReleaseRQ) Write(dst io.Writer) error {
+	return writeReserved(dst)
+}
+
+func (*ReleaseRQ) Read(src io.Reader) error {
+	return readReserved(src)
+}
+
+// ReleaseRP is the response to a release request.  It has no fields.
+// See PS 3.8, 9.3.7.
+type ReleaseRP struct{}
+
+func (ReleaseRP) Write(dst io.Writer) error {
+	return writeReserved(dst)
+}
+
+func (*ReleaseRP) Read(src io.Reader) error {
+	return readReserved(src)
+}
+
+// writeReserved writes the body of a release request or response
+func writeReserved(dst io.Writer) error {
+	_, err := dst.Write(make([]byte, 4))
+	return err
+}
+
+// readReserved reads the body of a release request or response
+func readReserved(src io.Reader) error {
+	var buf [4]byte
+	_, err := io.ReadFull(src, buf[:])
+	return err
+}
+
+// AbortSource is the source field of an A-ABORT, ie, who aborted the
+// association.
+type AbortSource uint8
+
+const (
+	AbortServiceUser     AbortSource = 0
+	AbortServiceProvider AbortSource = 2
+)
+
+func (s AbortSource) String() string {
+	switch s {
+	case AbortServiceUser:
+		return "service-user"
+	case AbortServiceProvider:
+		return "service-provider"
+	default:
+		return fmt.Sprintf("AbortSource(%d)", uint8(s))
+	}
+}
+
+// AbortReason is the reason/diag field of an A-ABORT.  It is only
+// significant if the source is AbortServiceProvider.
+type AbortReason uint8
+
+const (
+	AbortReasonNotSpecified       AbortReason = 0
+	AbortUnrecognizedPDU          AbortReason = 1
+	AbortUnexpectedPDU            AbortReason = 2
+	AbortUnrecognizedPDUParameter AbortReason = 4
+	AbortUnexpectedPDUParameter   AbortReason = 5
+	AbortInvalidPDUParameterValue AbortReason = 6
+)
+
+func (r AbortReason) String() string {
+	switch r {
+	case AbortReasonNotSpecified:
+		return "reason not specified"
+	case AbortUnrecognizedPDU:
+		return "unrecognized PDU"
+	case AbortUnexpectedPDU:
+		return "unexpected PDU"
+	case AbortUnrecognizedPDUParameter:
+		return "unrecognized PDU parameter"
+	case AbortUnexpectedPDUParameter:
+		return "unexpected PDU parameter"
+	case AbortInvalidPDUParameterValue:
+		return "invalid PDU parameter value"
+	default:
+		return fmt.Sprintf("reason %d", uint8(r))
+	}
+}
+
+// Abort is the abrupt end of an association, by either peer.
+// See PS 3.8, 9.3.8.
+type Abort struct {
+	Source AbortSource
+	Reason AbortReason
+}
+
+// Error allows an abort to be returned as the reason that an association
+// ended.
+func (a Abort) Error() string {
+	if a.Source == AbortServiceUser {
+		return "association aborted by service-user"
+	}
+
+	return fmt.Sprintf("association aborted by %s: %s", a.Source, a.Reason)
+}
+
+func (a Abort) Write(dst io.Writer) error {
+	_, err := dst.Write([]byte{0, 0, byte(a.Source), byte(a.Reason)})
+	return err
+}
+
+func (a *Abort) Read(src io.Reader) error {
+	var buf [4]byte
+	if _, err := io.ReadFull(src, buf[:]); err != nil {
+		return err
+	}
+
+	a.Source = AbortSource(buf[2])
+	a.Reason = AbortReason(buf[3])
+
+	return nil
+}
@@ -26,9 +26,10 @@ func TestParseAssocRQCEcho(t *testing.T) {
 		MaxPDULength:           16384,
 		ImplementationClassUID: "1.2.40.0.13.1.1",
 		ImplementationVersion:  "dcm4che-2.0",
-		// hmm, not a good test, since these are the defaults:
-		MaxOperationsInvoked:   0,
-		MaxOperationsPerformed: 0,
+		// unlimited:
+		NegotiateAsyncOperations: true,
+		MaxOperationsInvoked:     0,
+		MaxOperationsPerformed:   0,
 	}
 	got := readAssocRQAC(t, "testdata/assocrq_cecho.bin", PDUAssociateRQ)
 
@@ -54,9 +55,10 @@ func TestParseAssocACCEcho(t *testing.T) {
 		MaxPDULength:           16384,
 		ImplementationClassUID: "1.2.40.0.13.1.1",
 		ImplementationVersion:  "dcm4che-2.0",
-		// hmm, not a good test, since these are the defaults:
-		MaxOperationsInvoked:   0,
-		MaxOperationsPerformed: 0,
+		// unlimited:
+		NegotiateAsyncOperations: true,
+		MaxOperationsInvoked:     0,
+		MaxOperationsPerformed:   0,
 	}
 	got := readAssocRQAC(t, "testdata/assocac_cecho.bin", PDUAssociateAC)
 
@@ -65,6 +67,110 @@ func TestParseAssocACCEcho(t *testing.T) {
 	}
 }
 
+func TestWriteAssocRQCEcho(t *testing.T) {
+	rq := AssociateRQ{
+		AssociateRQAC: readAssocRQAC(t, "testdata/assocrq_cecho.bin",
+			PDUAssociateRQ),
+	}
+
+	var buf bytes.Buffer
+	if err := rq.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPDUBody(t, "testdata/assocrq_cecho.bin", buf.Bytes())
+}
+
+func TestWriteAssocACCEcho(t *testing.T) {
+	ac := AssociateAC{
+		AssociateRQAC: readAssocRQAC(t, "testdata/assocac_cecho.bin",
+			PDUAssociateAC),
+	}
+
+	var buf bytes.Buffer
+	if err := ac.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPDUBody(t, "testdata/assocac_cecho.bin", buf.Bytes())
+}
+
+func TestAssocACRoundTrip(t *testing.T) {
+	exp := AssociateRQAC{
+		ProtocolVersion:    1,
+		CalledAE:           "CALLED",
+		CallingAE:          "CALLING",
+		ApplicationContext: DICOMApplicationContext,
+		PresentationContexts: []PresentationContext{
+			{
+				ID:     1,
+				Result: PCAcceptance,
+				TransferSyntaxes: []dcm.TransferSyntax{
+					dcm.ExplicitVRLittleEndian,
+				},
+			},
+			{
+				ID:     3,
+				Result: PCProviderRejectionTransferSyntaxesNotSupported,
+				TransferSyntaxes: []dcm.TransferSyntax{
+					dcm.ImplicitVRLittleEndian,
+				},
+			},
+		},
+		MaxPDULength:           0,
+		ImplementationClassUID: "1.2.3.4",
+		// async operations absent
+	}
+
+	var buf bytes.Buffer
+	if err := (AssociateAC{AssociateRQAC: exp}).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got AssociateRQAC
+	if err := got.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("expected\n%#v\ngot\n%#v", exp, got)
+	}
+}
+
+func TestWriteAssocRQInvalid(t *testing.T) {
+	rq := AssociateRQ{AssociateRQAC: AssociateRQAC{
+		CalledAE: "THIS AE TITLE IS TOO LONG",
+	}}
+
+	if err := rq.Write(ioutil.Discard); err == nil {
+		t.Fatal("expected error for long AE title")
+	}
+}
+
+func TestReadAssocRQTruncated(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/assocrq_cecho.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rq AssociateRQAC
+	if err = rq.Read(bytes.NewReader(b[6:40])); err == nil {
+		t.Fatal("expected error for truncated request")
+	}
+}
+
+// expectPDUBody compares data to the body of the pdu in a file
+func expectPDUBody(t *testing.T, file string, data []byte) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b[6:], data) {
+		t.Fatalf("expected\n% x\ngot\n% x", b[6:], data)
+	}
+}
+
 func readAssocRQAC(t *testing.T, file string, pduType PDUType) AssociateRQAC {
 	b, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -89,7 +195,9 @@ func readAssocRQAC(t *testing.T, file string, pduType PDUType) AssociateRQAC {
 			pduType, pdu.Type)
 	}
 	var rqac AssociateRQAC
-	rqac.Read(pdu.Data)
+	if err = rqac.Read(pdu.Data); err != nil {
+		t.Fatal(err)
+	}
 
 	if "don't read me bro" != buf.String() {
 		t.Fatal("didn't stop reading at the right place")
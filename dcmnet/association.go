@@ -0,0 +1,543 @@
+package dcmnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+const (
+	// DICOMApplicationContext is the only application context name defined
+	// by the standard.  See PS 3.7, A.2.1.
+	DICOMApplicationContext = "1.2.840.10008.3.1.1.1"
+
+	// DefaultMaxPDULength is the maximum length of the PDUs that we are
+	// willing to receive, if the request doesn't say otherwise.  It is also
+	// the length of the PDUs that we send if the peer has no limit.
+	DefaultMaxPDULength = 16384
+
+	// maxSendPDULength limits the PDUs that we send, even if the peer
+	// allows larger ones, since each message element is buffered a whole
+	// PDU at a time.
+	maxSendPDULength = 1 << 20
+)
+
+// artimTimeout is how long the ARTIM timer runs, ie, how long to wait for
+// the peer to close the connection once the association is over.
+var artimTimeout = 30 * time.Second
+
+// Association is an established association, over which DIMSE messages
+// can be exchanged using the presentation contexts that were accepted.
+type Association struct {
+	conn     net.Conn
+	rq       AssociateRQ
+	ac       AssociateAC
+	contexts PresentationContexts
+
+	// the peer's limit, which applies to what we send
+	maxPDULength uint32
+
+	sm      stateMachine
+	pdus    PDUEncoder
+	pdata   *PDataReader
+	encoder MessageEncoder
+	decoder MessageDecoder
+}
+
+// Dial connects to the address and requests an association.  If the peer
+// rejects the association, the error is an AssociateRJ.
+//
+// The context limits the connection and the negotiation of the association,
+// but not its use afterwards.
+func Dial(ctx context.Context, addr string, rq AssociateRQ) (*Association, error) {
+	if len(rq.PresentationContexts) == 0 {
+		return nil, errNoPresentationContexts
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return Associate(ctx, conn, rq)
+}
+
+var errNoPresentationContexts = errors.New("no presentation contexts to request")
+
+// Associate requests an association over a connection that is already open.
+// See Dial.  If the association is not established, the connection is
+// closed.
+//
+// Unset fields of the request take the usual values: protocol version 1,
+// the DICOM application context and DefaultMaxPDULength.
+func Associate(ctx context.Context, conn net.Conn, rq AssociateRQ) (*Association, error) {
+	if rq.ProtocolVersion == 0 {
+		rq.ProtocolVersion = 1
+	}
+	if rq.ApplicationContext == "" {
+		rq.ApplicationContext = DICOMApplicationContext
+	}
+	if rq.MaxPDULength == 0 {
+		rq.MaxPDULength = DefaultMaxPDULength
+	}
+
+	if len(rq.PresentationContexts) == 0 {
+		conn.Close()
+		return nil, errNoPresentationContexts
+	}
+
+	as := &Association{
+		conn: conn,
+		rq:   rq,
+		sm:   newStateMachine(true),
+		pdus: NewPDUEncoder(conn),
+	}
+
+	// the connection is already open, so AE-1 is done:
+	as.sm.handle(evtAssociateRQLocal)
+	as.sm.handle(evtTransportConfirm)
+
+	stop := interruptOnDone(ctx, conn)
+	pdus, err := as.negotiate()
+	stop()
+
+	if ctx.Err() != nil {
+		// the caller won't wait for the peer to close the connection:
+		if _, aerr := as.sm.handle(evtAbortLocal); aerr == nil {
+			as.sendAbort(Abort{Source: AbortServiceUser})
+		}
+		as.Close()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		as.Close()
+		return nil, err
+	}
+
+	as.maxPDULength = as.ac.MaxPDULength
+	as.start(pdus)
+
+	return as, nil
+}
+
+// start sets up the message encoder and decoder once the association is
+// established.
+func (as *Association) start(pdus PDUDecoder) {
+	sendLength := as.maxPDULength
+	if sendLength == 0 || sendLength > maxSendPDULength {
+		sendLength = maxSendPDULength
+	}
+
+	pdata := NewPDataReader(pdus)
+	as.pdata = &pdata
+	as.decoder = NewMessageDecoder(as.contexts,
+		NewMessageElementDecoder(NewPDVDecoder(as.pdata)))
+	as.encoder = NewMessageEncoder(as.contexts,
+		NewMessageElementEncoder(NewPDUEncoder(pdataWriter{as}), sendLength))
+}
+
+// pdataWriter sends presentation data, as long as the state allows it.
+type pdataWriter struct {
+	as *Association
+}
+
+func (w pdataWriter) Write(buf []byte) (int, error) {
+	if _, err := w.as.sm.handle(evtPDataLocal); err != nil {
+		return 0, err
+	}
+
+	return w.as.conn.Write(buf)
+}
+
+// interruptOnDone makes I/O on the connection fail if the context is done,
+// until the returned function is called.
+func interruptOnDone(ctx context.Context, conn net.Conn) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// a deadline in the past interrupts any blocked calls:
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		conn.SetDeadline(time.Time{})
+	}
+}
+
+// negotiate sends the request and reads the response, returning the decoder
+// for the PDUs that follow.
+func (as *Association) negotiate() (pdus PDUDecoder, err error) {
+	if err = as.send(PDUAssociateRQ, as.rq); err != nil {
+		return pdus, err
+	}
+
+	pdus = NewPDUDecoder(as.conn)
+	pdu, err := pdus.NextPDU()
+	if err != nil {
+		return pdus, err
+	}
+
+	evt := pduEvent(pdu)
+	if evt == evtAssociateACPDU {
+		if err = as.ac.Read(pdu.Data); err != nil {
+			evt = evtInvalidPDU
+		}
+	}
+
+	act, rerr := as.receive(evt, pdu)
+	if rerr != nil {
+		return pdus, rerr
+	}
+
+	if act == ae4 {
+		var rj AssociateRJ
+		if err = rj.Read(pdu.Data); err != nil {
+			return pdus, err
+		}
+		return pdus, rj
+	}
+
+	as.contexts = PresentationContexts{
+		Requested: as.rq.PresentationContexts,
+		Accepted:  as.ac.PresentationContexts,
+	}
+
+	if err = checkAccepted(as.contexts); err != nil {
+		as.Abort()
+		return pdus, err
+	}
+
+	return pdus, nil
+}
+
+// receive moves the state machine for an event that came from the peer:
+// a PDU, or the connection closing if pdu is nil.  Aborts and closes are
+// carried out here, and reported as errors.  Other actions are left to the
+// caller.
+func (as *Association) receive(evt event, pdu *PDU) (action, error) {
+	act, err := as.sm.handle(evt)
+	if err != nil {
+		return act, err
+	}
+
+	switch act {
+	case aa1, aa8:
+		abort := Abort{Source: AbortServiceUser}
+		if act == aa8 {
+			abort = Abort{AbortServiceProvider, abortReason(evt, pdu)}
+		}
+		as.sendAbort(abort)
+		as.awaitClose()
+
+		if evt == evtInvalidPDU {
+			return act, fmt.Errorf("invalid pdu %s, association aborted", pdu)
+		}
+		return act, fmt.Errorf("unexpected pdu %s, association aborted", pdu)
+
+	case aa2, aa3:
+		var abort Abort
+		err = abort.Read(pdu.Data)
+		as.conn.Close()
+
+		if err != nil {
+			return act, err
+		}
+		return act, abort
+
+	case aa4, aa5:
+		as.conn.Close()
+		return act, io.ErrUnexpectedEOF
+	}
+
+	return act, nil
+}
+
+// abortReason explains why a PDU caused the association to be aborted
+func abortReason(evt event, pdu *PDU) AbortReason {
+	switch {
+	case evt != evtInvalidPDU:
+		return AbortUnexpectedPDU
+	case pduEvent(pdu) == evtInvalidPDU:
+		return AbortUnrecognizedPDU
+	default:
+		return AbortInvalidPDUParameterValue
+	}
+}
+
+// checkAccepted verifies that each accepted presentation context answers a
+// requested one, with one of the transfer syntaxes that was proposed.
+func checkAccepted(contexts PresentationContexts) error {
+	for _, ac := range contexts.Accepted {
+		var rq *PresentationContext
+		for i := range contexts.Requested {
+			if contexts.Requested[i].ID == ac.ID {
+				rq = &contexts.Requested[i]
+				break
+			}
+		}
+
+		if rq == nil {
+			return fmt.Errorf("response has presentation context "+
+				"id %d, which was not requested", ac.ID)
+		}
+
+		if !ac.Result.IsAcceptance() {
+			continue
+		}
+
+		if len(ac.TransferSyntaxes) != 1 ||
+			!overlap(ac.TransferSyntaxes, rq.TransferSyntaxes) {
+			return fmt.Errorf("presentation context id %d was accepted "+
+				"with transfer syntaxes %s, but %s were proposed",
+				ac.ID, ac.TransferSyntaxes, rq.TransferSyntaxes)
+		}
+	}
+
+	return nil
+}
+
+// Request returns the association request, as sent.
+func (as *Association) Request() AssociateRQ {
+	return as.rq
+}
+
+// Response returns the acceptance of the association request.
+func (as *Association) Response() AssociateAC {
+	return as.ac
+}
+
+// RemoteAddr returns the address of the peer.
+func (as *Association) RemoteAddr() net.Addr {
+	return as.conn.RemoteAddr()
+}
+
+// PresentationContexts returns the requested presentation contexts and the
+// responses to them, which may be rejections.
+func (as *Association) PresentationContexts() PresentationContexts {
+	return as.contexts
+}
+
+// AcceptedTCaps returns the transfer capabilities of the accepted
+// presentation contexts, in the order that they were requested.  Their
+// roles are those of the requestor.
+func (as *Association) AcceptedTCaps() []TransferCapability {
+	var tcaps []TransferCapability
+	for _, rq := range as.contexts.Requested {
+		if tcap := as.contexts.FindAcceptedTCap(rq.ID); tcap != nil {
+			tcap.Role = as.Role(tcap.AbstractSyntax)
+			tcaps = append(tcaps, *tcap)
+		}
+	}
+	return tcaps
+}
+
+// Role returns the negotiated role of the requestor for a SOP class.  The
+// acceptor takes the opposite role.
+func (as *Association) Role(sopClass string) Role {
+	return NegotiatedRole(as.rq.Roles, as.ac.Roles, sopClass)
+}
+
+// MaxPDULength returns the length of the largest PDU that the peer is
+// willing to receive, or 0 if there is no limit.
+func (as *Association) MaxPDULength() uint32 {
+	return as.maxPDULength
+}
+
+// Encoder returns the encoder for sending messages to the peer.
+func (as *Association) Encoder() *MessageEncoder {
+	return &as.encoder
+}
+
+// Decoder returns the decoder for receiving messages from the peer.  It
+// reports no more messages when the peer sends something other than
+// presentation data, eg, a release request.
+func (as *Association) Decoder() *MessageDecoder {
+	return &as.decoder
+}
+
+// State returns the state of the association, in terms of the upper layer
+// state machine.  See PS 3.8, 9.2.
+func (as *Association) State() State {
+	return as.sm.current()
+}
+
+// Release asks the peer to release the association, waits for it to agree
+// and closes the connection.  Any messages that haven't been received yet
+// are discarded.
+//
+// If the peer has already asked for a release, ie, the decoder has no more
+// messages, Release agrees to it instead.  If both peers ask at the same
+// time, the collision is resolved as in PS 3.8, 7.2.
+func (as *Association) Release() error {
+	if as.pdata.ended() {
+		return as.finish()
+	}
+
+	if _, err := as.sm.handle(evtReleaseRQLocal); err != nil {
+		return err
+	}
+	if err := as.send(PDUReleaseRQ, ReleaseRQ{}); err != nil {
+		as.Close()
+		return err
+	}
+
+	// messages that are still arriving are discarded:
+	_, err := io.Copy(ioutil.Discard, as.pdata)
+
+	for err == nil {
+		act, rerr := as.receive(pduEvent(as.pdata.GetFinalPDU()),
+			as.pdata.GetFinalPDU())
+		if rerr != nil {
+			return rerr
+		}
+
+		switch act {
+		case ar3:
+			return as.conn.Close()
+
+		case ar8:
+			// the requestor answers the collision first:
+			if as.sm.requestor {
+				if err = as.respondRelease(); err != nil {
+					as.Close()
+					return err
+				}
+			}
+
+		case ar10:
+			return as.respondRelease()
+		}
+
+		err = as.pdata.nextPDU()
+	}
+
+	as.Close()
+	return err
+}
+
+// finish ends an association once the decoder has no more messages, or
+// aborts it if that isn't the case.  If the peer asked to release it, the
+// release is confirmed.
+func (as *Association) finish() error {
+	switch {
+	case as.State() == Sta1:
+		// already released or aborted
+		return nil
+
+	case !as.pdata.ended():
+		return as.Abort()
+
+	case as.pdata.err != nil && as.pdata.err != io.EOF:
+		as.Close()
+		return as.pdata.err
+	}
+
+	pdu := as.pdata.GetFinalPDU()
+	act, err := as.receive(pduEvent(pdu), pdu)
+	if err != nil {
+		return err
+	}
+
+	if act == ar2 {
+		return as.respondRelease()
+	}
+
+	return nil
+}
+
+// respondRelease agrees to the peer's release request.  After AR-4, the
+// peer closes the connection.
+func (as *Association) respondRelease() error {
+	act, err := as.sm.handle(evtReleaseRPLocal)
+	if err != nil {
+		return err
+	}
+
+	err = as.send(PDUReleaseRP, ReleaseRP{})
+	if act == ar4 {
+		as.awaitClose()
+	}
+
+	return err
+}
+
+// Abort tells the peer that the association is over, waits for it to close
+// the connection and closes it anyway if it takes too long.  Any messages
+// that haven't been received yet are discarded.
+//
+// Abort must not be called while another goroutine is receiving messages.
+// Close can be used instead.
+func (as *Association) Abort() error {
+	act, err := as.sm.handle(evtAbortLocal)
+	if err != nil {
+		return err
+	}
+
+	if act == aa2 {
+		return as.conn.Close()
+	}
+
+	err = as.sendAbort(Abort{Source: AbortServiceUser})
+	as.awaitClose()
+
+	return err
+}
+
+// sendAbort sends an A-ABORT, without waiting forever if the peer isn't
+// reading.
+func (as *Association) sendAbort(abort Abort) error {
+	as.conn.SetWriteDeadline(time.Now().Add(artimTimeout))
+	return as.send(PDUAbort, abort)
+}
+
+// awaitClose waits for the peer to close the connection, until the ARTIM
+// timer expires, and then closes it.  PDUs that arrive meanwhile are
+// ignored.
+func (as *Association) awaitClose() {
+	as.conn.SetReadDeadline(time.Now().Add(artimTimeout))
+	_, err := io.Copy(ioutil.Discard, as.conn)
+
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		as.sm.handle(evtARTIMExpired)
+	} else {
+		as.sm.handle(evtTransportClosed)
+	}
+
+	as.conn.Close()
+}
+
+// send sends a PDU other than presentation data.
+func (as *Association) send(pduType PDUType, body interface {
+	Write(io.Writer) error
+}) error {
+	var buf bytes.Buffer
+	if err := body.Write(&buf); err != nil {
+		return err
+	}
+
+	return as.pdus.NextPDU(PDU{
+		Type:   pduType,
+		Length: uint32(buf.Len()),
+		Data:   &buf,
+	})
+}
+
+// Close closes the connection without releasing or aborting the
+// association.  The peer sees this as a provider abort.
+func (as *Association) Close() error {
+	as.sm.handle(evtTransportClosed)
+	return as.conn.Close()
+}
@@ -0,0 +1,358 @@
+package dcmnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+const verification = "1.2.840.10008.1.1"
+
+var testRQ = AssociateRQ{AssociateRQAC: AssociateRQAC{
+	CalledAE:  "CALLED",
+	CallingAE: "CALLING",
+	PresentationContexts: []PresentationContext{
+		{
+			ID:               1,
+			AbstractSyntax:   verification,
+			TransferSyntaxes: []dcm.TransferSyntax{dcm.ImplicitVRLittleEndian},
+		},
+		{
+			ID:               3,
+			AbstractSyntax:   "1.2.840.10008.5.1.4.1.1.2",
+			TransferSyntaxes: []dcm.TransferSyntax{dcm.ExplicitVRLittleEndian},
+		},
+	},
+}}
+
+// bufassoc creates an association request or response in a buffer
+func bufassoc(typ PDUType, items ...interface{}) bytes.Buffer {
+	header := make([]byte, 68)
+	binary.BigEndian.PutUint16(header[0:2], 1)
+	copy(header[4:20], fmt.Sprintf("%-16s", "CALLED"))
+	copy(header[20:36], fmt.Sprintf("%-16s", "CALLING"))
+
+	return bufpdu(typ, append([]interface{}{header}, items...)...)
+}
+
+// bufacpc creates an accepted (or not) presentation context in a buffer
+func bufacpc(id PCID, result PCResult, ts dcm.TransferSyntax) bytes.Buffer {
+	return bufitem(AcceptPresentationContext,
+		[]byte{byte(id), 0, byte(result), 0},
+		bufitem(TransferSyntax, ts.UID()))
+}
+
+// acceptor plays the part of the peer: it reads the association request,
+// sends the response and returns the request.
+func acceptor(t *testing.T, conn net.Conn, response bytes.Buffer) (
+	pdus PDUDecoder, rq AssociateRQAC) {
+
+	pdus = NewPDUDecoder(conn)
+
+	pdu, err := pdus.NextPDU()
+	if err != nil || pdu == nil {
+		t.Errorf("no association request: %v", err)
+		return
+	}
+	if pdu.Type != PDUAssociateRQ {
+		t.Errorf("unexpected pdu %s", pdu)
+	}
+
+	rq.Read(pdu.Data)
+
+	if _, err = response.WriteTo(conn); err != nil {
+		t.Error(err)
+	}
+
+	return
+}
+
+func TestAssociateAccepted(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	echoRsp := dcm.NewObject()
+	echoRsp.Set(dcm.AffectedSOPClassUID, verification)
+	echoRsp.Set(dcm.CommandField, int(CEchoRsp))
+	echoRsp.Set(dcm.MessageIDBeingRespondedTo, 1)
+	echoRsp.Set(dcm.CommandDataSetType, int(CommandHasNoDataSet))
+	echoRsp.Set(dcm.Status, 0)
+	echoRspData, err := encodeCommand(echoRsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rqs := make(chan AssociateRQAC, 1)
+	go func() {
+		defer server.Close()
+
+		pdus, rq := acceptor(t, server, bufassoc(PDUAssociateAC,
+			bufitem(ApplicationContext, DICOMApplicationContext),
+			bufacpc(1, PCAcceptance, dcm.ImplicitVRLittleEndian),
+			bufacpc(3, PCProviderRejectionAbstractSyntaxNotSupported,
+				dcm.ImplicitVRLittleEndian),
+			bufitem(UserInfo,
+				bufitem(MaxPDULength, []byte{0, 0, 0x10, 0}))))
+		rqs <- rq
+
+		pdu, _ := pdus.NextPDU()
+		if pdu == nil || pdu.Type != PDUPresentationData {
+			t.Errorf("expected echo request, got %s", pdu)
+			return
+		}
+		ioutil.ReadAll(pdu.Data)
+
+		rsp := bufpdu(PDUPresentationData,
+			bufpdv(1, Command, true, echoRspData))
+		rsp.WriteTo(server)
+
+		pdu, _ = pdus.NextPDU()
+		if pdu == nil || pdu.Type != PDUReleaseRQ {
+			t.Errorf("expected release request, got %s", pdu)
+			return
+		}
+		ioutil.ReadAll(pdu.Data)
+
+		rp := bufpdu(PDUReleaseRP, []byte{0, 0, 0, 0})
+		rp.WriteTo(server)
+	}()
+
+	as, err := Associate(context.Background(), client, testRQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rq := <-rqs
+	if rq.ApplicationContext != DICOMApplicationContext {
+		t.Errorf("unexpected application context %q", rq.ApplicationContext)
+	}
+	if rq.MaxPDULength != DefaultMaxPDULength {
+		t.Errorf("unexpected max pdu length %d", rq.MaxPDULength)
+	}
+	if rq.CalledAE != "CALLED" || rq.CallingAE != "CALLING" {
+		t.Errorf("unexpected AE titles %q, %q", rq.CalledAE, rq.CallingAE)
+	}
+
+	if as.MaxPDULength() != 4096 {
+		t.Errorf("unexpected max pdu length %d", as.MaxPDULength())
+	}
+
+	tcaps := as.AcceptedTCaps()
+	if len(tcaps) != 1 || tcaps[0].AbstractSyntax != verification {
+		t.Fatalf("unexpected accepted transfer capabilities %v", tcaps)
+	}
+
+	echoRq := dcm.NewObject()
+	echoRq.Set(dcm.AffectedSOPClassUID, verification)
+	echoRq.Set(dcm.CommandField, int(CEchoReq))
+	echoRq.Set(dcm.MessageID, 1)
+	echoRq.Set(dcm.CommandDataSetType, int(CommandHasNoDataSet))
+
+	err = as.Encoder().NextMessage(Message{Command: echoRq, TCap: tcaps[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := as.Decoder().NextMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil {
+		t.Fatal("no echo response")
+	}
+
+	var cmdField uint16
+	if err = msg.Command.Scan(dcm.CommandField, &cmdField); err != nil {
+		t.Fatal(err)
+	}
+	if CommandField(cmdField) != CEchoRsp {
+		t.Fatalf("unexpected command %s", CommandField(cmdField))
+	}
+
+	if err = as.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssociateRejected(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		acceptor(t, server, bufpdu(PDUAssociateRJ, []byte{0, 1, 1, 7}))
+	}()
+
+	_, err := Associate(context.Background(), client, testRQ)
+
+	exp := AssociateRJ{RJPermanent, RJServiceUser, RJCalledAENotRecognized}
+	if err != exp {
+		t.Fatalf("expected %#v, got %#v", exp, err)
+	}
+
+	expMsg := "association rejected-permanent by service-user: " +
+		"called AE title not recognized"
+	if err.Error() != expMsg {
+		t.Fatalf("unexpected message %q", err)
+	}
+}
+
+func TestAssociateUnrequestedContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		acceptor(t, server, bufassoc(PDUAssociateAC,
+			bufacpc(5, PCAcceptance, dcm.ImplicitVRLittleEndian)))
+	}()
+
+	_, err := Associate(context.Background(), client, testRQ)
+	if err == nil {
+		t.Fatal("expected error for unrequested presentation context")
+	}
+}
+
+func TestAssociateNoPresentationContexts(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	rq := testRQ
+	rq.PresentationContexts = nil
+
+	if _, err := Associate(context.Background(), client, rq); err != errNoPresentationContexts {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the connection is closed:
+	if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	// and Dial doesn't open one:
+	if _, err := Dial(context.Background(), "127.0.0.1:1", rq); err != errNoPresentationContexts {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestAssociateTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		// read the request, but never respond:
+		ioutil.ReadAll(server)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := Associate(ctx, client, testRQ)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
+
+func TestAssociateRJRoundTrip(t *testing.T) {
+	exp := AssociateRJ{RJTransient, RJServiceProviderPresentation,
+		RJTemporaryCongestion}
+
+	var buf bytes.Buffer
+	if err := exp.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got AssociateRJ
+	if err := got.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if got != exp {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+func TestAbortRoundTrip(t *testing.T) {
+	exp := Abort{AbortServiceProvider, AbortUnexpectedPDU}
+
+	var buf bytes.Buffer
+	if err := exp.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), []byte{0, 0, 2, 2}) {
+		t.Fatalf("unexpected encoding % x", buf.Bytes())
+	}
+
+	var got Abort
+	if err := got.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if got != exp {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	expMsg := "association aborted by service-provider: unexpected PDU"
+	if got.Error() != expMsg {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func TestAssociateUnexpectedPDU(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	aborts := make(chan bytes.Buffer, 1)
+	go func() {
+		defer server.Close()
+
+		pdus, _ := acceptor(t, server, bufassoc(PDUAssociateAC,
+			bufacpc(1, PCAcceptance, dcm.ImplicitVRLittleEndian)))
+
+		// a release response, without a request.  The pipe is synchronous,
+		// and the rest of the pdu won't be read:
+		rp := bufpdu(PDUReleaseRP, []byte{0, 0, 0, 0})
+		go rp.WriteTo(server)
+
+		pdu, err := pdus.NextPDU()
+		if err != nil || pdu == nil || pdu.Type != PDUAbort {
+			t.Errorf("expected abort, got %s: %v", pdu, err)
+			aborts <- bytes.Buffer{}
+			return
+		}
+		aborts <- toBuffer(pdu.Data)
+	}()
+
+	as, err := Associate(context.Background(), client, testRQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := as.Decoder().NextMessage()
+	if msg != nil || err != nil {
+		t.Fatalf("expected no message, got %v, %v", msg, err)
+	}
+
+	if err = as.Release(); err == nil {
+		t.Fatal("expected error for unexpected release response")
+	}
+
+	abort := <-aborts
+	if !bytes.Equal(abort.Bytes(), []byte{0, 0, 2, 2}) {
+		t.Fatalf("unexpected abort % x", abort.Bytes())
+	}
+
+	if as.State() != Sta1 {
+		t.Fatalf("expected %s, got %s", Sta1, as.State())
+	}
+}
@@ -1,9 +1,12 @@
 package dcmnet
 
 import (
+	"bytes"
 	"encoding/binary"
-	"github.com/jeremyhuiskamp/dcm/stream"
+	"fmt"
 	"io"
+
+	"github.com/jeremyhuiskamp/dcm/stream"
 )
 
 type ItemType uint8
@@ -23,6 +26,11 @@ const (
 	AsyncOperations            ItemType = 0x53
 	RoleSelection              ItemType = 0x54
 	ImplementationVersion      ItemType = 0x55
+
+	SOPClassExtendedNegotiation       ItemType = 0x56
+	SOPClassCommonExtendedNegotiation ItemType = 0x57
+	UserIdentityRQ                    ItemType = 0x58
+	UserIdentityAC                    ItemType = 0x59
 )
 
 type Item struct {
@@ -60,18 +68,34 @@ func (reader *ItemReader) NextItem() (item *Item, err error) {
 func EachItem(src io.Reader, f func(*Item) error) error {
 	items := NewItemReader(src)
 
-	// this seems uglier than it should have to be...
-	for item, err := items.NextItem(); item != nil; item, err = items.NextItem() {
+	for {
+		item, err := items.NextItem()
 		if err != nil {
 			return err
 		}
 
-		err = f(item)
+		if item == nil {
+			return nil
+		}
 
-		if err != nil {
+		if err = f(item); err != nil {
 			return err
 		}
 	}
+}
+
+// writeItem writes an item with its header
+func writeItem(dst *bytes.Buffer, typ ItemType, data []byte) error {
+	if len(data) > 0xFFFF {
+		return fmt.Errorf("%s item is too long: %d bytes", typ, len(data))
+	}
+
+	var header [4]byte
+	header[0] = byte(typ)
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(data)))
+
+	dst.Write(header[:])
+	dst.Write(data)
 
 	return nil
 }
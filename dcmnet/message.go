@@ -350,6 +350,41 @@ type MessageEncoder struct {
 	msgs     MessageElementEncoder
 }
 
+func NewMessageEncoder(
+	contexts PresentationContexts,
+	msgs MessageElementEncoder,
+) MessageEncoder {
+	return MessageEncoder{contexts, msgs}
+}
+
+// encodeCommand serializes a command object, which always uses implicit vr
+// little endian and always starts with the command group length.
+// See PS 3.7, 6.3.1.
+func encodeCommand(cmd dcm.Object) (stream.Stream, error) {
+	var body bytes.Buffer
+	err := dcmio.NewStreamEncoder(&body, dcm.ImplicitVRLittleEndian).Encode(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupLength [4]byte
+	binary.LittleEndian.PutUint32(groupLength[:], uint32(body.Len()))
+
+	var buf bytes.Buffer
+	err = dcmio.NewStreamEncoder(&buf, dcm.ImplicitVRLittleEndian).
+		EncodeElement(dcm.SimpleElement{
+			Tag:  dcm.CommandGroupLength,
+			VR:   dcm.UL,
+			Data: groupLength[:],
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = body.WriteTo(&buf)
+	return &buf, err
+}
+
 func (me *MessageEncoder) NextMessage(msg Message) error {
 	// Is the transfer capability limited to one transfer syntax?  There could
 	// be more than one transfer syntax for the abstract syntax if they were
@@ -361,10 +396,15 @@ func (me *MessageEncoder) NextMessage(msg Message) error {
 			"for transfer capability %s", msg.TCap)
 	}
 
-	err := me.msgs.NextMessageElement(MessageElement{
+	cmd, err := encodeCommand(msg.Command)
+	if err != nil {
+		return err
+	}
+
+	err = me.msgs.NextMessageElement(MessageElement{
 		Context: *pcid,
 		Type:    Command,
-		// TODO: need dicom object serializer!
+		Data:    cmd,
 	})
 
 	if err != nil {
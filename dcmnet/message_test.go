@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -344,3 +345,72 @@ func expectPDV(
 	}
 	return last, data
 }
+
+func TestWriteMessage(t *testing.T) {
+	cmd := dcm.NewObject()
+	cmd.Put(dcm.SimpleElement{
+		Tag:  dcm.AffectedSOPClassUID,
+		VR:   dcm.UI,
+		Data: []byte("1.2.3\x00"),
+	})
+	cmd.Put(dcm.SimpleElement{
+		Tag:  dcm.CommandField,
+		VR:   dcm.US,
+		Data: []byte{0x30, 0x00},
+	})
+	cmd.Put(dcm.SimpleElement{
+		Tag:  dcm.CommandDataSetType,
+		VR:   dcm.US,
+		Data: []byte{0x01, 0x01},
+	})
+
+	var data bytes.Buffer
+	me := NewMessageEncoder(simplePcs(),
+		NewMessageElementEncoder(NewPDUEncoder(&data), 16))
+	err := me.NextMessage(Message{Command: cmd, TCap: matchingTc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, msgs := setupParse(data)
+	md := NewMessageDecoder(simplePcs(), msgs)
+	msg, err := md.NextMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil {
+		t.Fatal("expected message not found")
+	}
+
+	if !reflect.DeepEqual(cmd, msg.Command) {
+		t.Fatalf("expected command\n%s\nbut got\n%s", cmd, msg.Command)
+	}
+	if msg.Data != nil {
+		t.Fatal("expected no data")
+	}
+}
+
+func TestWriteMessageGroupLength(t *testing.T) {
+	cmd := dcm.NewObject()
+	cmd.Put(dcm.SimpleElement{
+		Tag:  dcm.CommandField,
+		VR:   dcm.US,
+		Data: []byte{0x30, 0x00},
+	})
+
+	encoded, err := encodeCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00,
+		0x0A, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00,
+		0x30, 0x00,
+	}
+	if got := toString(encoded); got != string(exp) {
+		t.Fatalf("unexpected command encoding:\n% X\nexpected:\n% X",
+			got, exp)
+	}
+}
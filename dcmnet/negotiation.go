@@ -0,0 +1,371 @@
+package dcmnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Sub-items of the user information item, beyond the simple ones in
+// assoc.go.  See PS 3.7, Annex D.3.3.
+
+// SOPClassRole proposes, or accepts, the roles of the association
+// requestor for a SOP class.  See PS 3.7, D.3.3.4.
+type SOPClassRole struct {
+	SOPClass string
+	Role     Role
+}
+
+// ExtendedNegotiation is information about a SOP class whose meaning is
+// defined by its service class.  See PS 3.7, D.3.3.5.
+type ExtendedNegotiation struct {
+	SOPClass string
+	Info     []byte
+}
+
+// CommonExtendedNegotiation describes a SOP class that is proposed, so that
+// the acceptor can treat it like one that it knows.  It is only sent in
+// requests.  See PS 3.7, D.3.3.6.
+type CommonExtendedNegotiation struct {
+	SOPClass                 string
+	ServiceClass             string
+	RelatedGeneralSOPClasses []string
+}
+
+// UserIdentityType is how the user is identified.
+type UserIdentityType uint8
+
+const (
+	UserIdentityUsername         UserIdentityType = 1
+	UserIdentityUsernamePasscode UserIdentityType = 2
+	UserIdentityKerberos         UserIdentityType = 3
+	UserIdentitySAML             UserIdentityType = 4
+	UserIdentityJWT              UserIdentityType = 5
+)
+
+func (t UserIdentityType) String() string {
+	switch t {
+	case UserIdentityUsername:
+		return "username"
+	case UserIdentityUsernamePasscode:
+		return "username and passcode"
+	case UserIdentityKerberos:
+		return "kerberos"
+	case UserIdentitySAML:
+		return "saml"
+	case UserIdentityJWT:
+		return "jwt"
+	default:
+		return fmt.Sprintf("UserIdentityType(%d)", uint8(t))
+	}
+}
+
+// UserIdentity identifies the user that requests an association.  The
+// primary field holds the username, Kerberos service ticket, SAML assertion
+// or JSON web token.  The secondary field is only used for a passcode.
+// See PS 3.7, D.3.3.7.
+type UserIdentity struct {
+	Type                      UserIdentityType
+	PositiveResponseRequested bool
+	PrimaryField              []byte
+	SecondaryField            []byte
+}
+
+func NewUsernameIdentity(username string) *UserIdentity {
+	return &UserIdentity{
+		Type:         UserIdentityUsername,
+		PrimaryField: []byte(username),
+	}
+}
+
+func NewUsernamePasscodeIdentity(username, passcode string) *UserIdentity {
+	return &UserIdentity{
+		Type:           UserIdentityUsernamePasscode,
+		PrimaryField:   []byte(username),
+		SecondaryField: []byte(passcode),
+	}
+}
+
+func NewKerberosIdentity(serviceTicket []byte) *UserIdentity {
+	return &UserIdentity{
+		Type:         UserIdentityKerberos,
+		PrimaryField: serviceTicket,
+	}
+}
+
+func NewSAMLIdentity(assertion []byte) *UserIdentity {
+	return &UserIdentity{
+		Type:         UserIdentitySAML,
+		PrimaryField: assertion,
+	}
+}
+
+func NewJWTIdentity(token string) *UserIdentity {
+	return &UserIdentity{
+		Type:         UserIdentityJWT,
+		PrimaryField: []byte(token),
+	}
+}
+
+// String describes the identity without revealing the credentials, except
+// for a username.
+func (ui UserIdentity) String() string {
+	switch ui.Type {
+	case UserIdentityUsername, UserIdentityUsernamePasscode:
+		return fmt.Sprintf("%s %q", ui.Type, ui.PrimaryField)
+	default:
+		return fmt.Sprintf("%s (%d bytes)", ui.Type, len(ui.PrimaryField))
+	}
+}
+
+// validate checks that the identity has the fields its type needs
+func (ui UserIdentity) validate() error {
+	if ui.Type < UserIdentityUsername || ui.Type > UserIdentityJWT {
+		return fmt.Errorf("unknown user identity type %d", ui.Type)
+	}
+
+	if len(ui.PrimaryField) == 0 {
+		return fmt.Errorf("empty %s user identity", ui.Type)
+	}
+
+	if ui.Type == UserIdentityUsernamePasscode && len(ui.SecondaryField) == 0 {
+		return errors.New("user identity has no passcode")
+	}
+
+	return nil
+}
+
+// UserIdentityResult is the acceptor's answer to a user identity that
+// requested a positive response.  The server response is empty for a
+// username, a Kerberos server ticket, a SAML response or a JWT.
+type UserIdentityResult struct {
+	ServerResponse []byte
+}
+
+// writeField writes a value with a 2 byte length in front of it
+func writeField(dst *bytes.Buffer, value []byte) error {
+	if len(value) > 0xFFFF {
+		return fmt.Errorf("field is too long: %d bytes", len(value))
+	}
+
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(value)))
+	dst.Write(length[:])
+	dst.Write(value)
+
+	return nil
+}
+
+// readField reads a value with a 2 byte length in front of it
+func readField(src io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(src, length[:]); err != nil {
+		return nil, err
+	}
+
+	value := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(src, value); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (r SOPClassRole) write(dst *bytes.Buffer) error {
+	var body bytes.Buffer
+	if err := writeField(&body, []byte(r.SOPClass)); err != nil {
+		return err
+	}
+	body.Write(r.Role[:])
+
+	return writeItem(dst, RoleSelection, body.Bytes())
+}
+
+func (r *SOPClassRole) read(src io.Reader) error {
+	uid, err := readField(src)
+	if err != nil {
+		return err
+	}
+	r.SOPClass = trimUID(uid)
+
+	if _, err = io.ReadFull(src, r.Role[:]); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (en ExtendedNegotiation) write(dst *bytes.Buffer) error {
+	var body bytes.Buffer
+	if err := writeField(&body, []byte(en.SOPClass)); err != nil {
+		return err
+	}
+	body.Write(en.Info)
+
+	return writeItem(dst, SOPClassExtendedNegotiation, body.Bytes())
+}
+
+func (en *ExtendedNegotiation) read(src io.Reader) (err error) {
+	uid, err := readField(src)
+	if err != nil {
+		return err
+	}
+	en.SOPClass = trimUID(uid)
+
+	en.Info, err = ioutil.ReadAll(src)
+	return err
+}
+
+func (cen CommonExtendedNegotiation) write(dst *bytes.Buffer) error {
+	var body bytes.Buffer
+	if err := writeField(&body, []byte(cen.SOPClass)); err != nil {
+		return err
+	}
+	if err := writeField(&body, []byte(cen.ServiceClass)); err != nil {
+		return err
+	}
+
+	var related bytes.Buffer
+	for _, uid := range cen.RelatedGeneralSOPClasses {
+		if err := writeField(&related, []byte(uid)); err != nil {
+			return err
+		}
+	}
+	if err := writeField(&body, related.Bytes()); err != nil {
+		return err
+	}
+
+	return writeItem(dst, SOPClassCommonExtendedNegotiation, body.Bytes())
+}
+
+func (cen *CommonExtendedNegotiation) read(src io.Reader) error {
+	uid, err := readField(src)
+	if err != nil {
+		return err
+	}
+	cen.SOPClass = trimUID(uid)
+
+	if uid, err = readField(src); err != nil {
+		return err
+	}
+	cen.ServiceClass = trimUID(uid)
+
+	related, err := readField(src)
+	if err != nil {
+		return err
+	}
+
+	for relatedSrc := bytes.NewReader(related); relatedSrc.Len() > 0; {
+		if uid, err = readField(relatedSrc); err != nil {
+			return err
+		}
+		cen.RelatedGeneralSOPClasses = append(cen.RelatedGeneralSOPClasses,
+			trimUID(uid))
+	}
+
+	return nil
+}
+
+func (ui UserIdentity) write(dst *bytes.Buffer) error {
+	if err := ui.validate(); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	body.WriteByte(byte(ui.Type))
+	if ui.PositiveResponseRequested {
+		body.WriteByte(1)
+	} else {
+		body.WriteByte(0)
+	}
+
+	if err := writeField(&body, ui.PrimaryField); err != nil {
+		return err
+	}
+	if err := writeField(&body, ui.SecondaryField); err != nil {
+		return err
+	}
+
+	return writeItem(dst, UserIdentityRQ, body.Bytes())
+}
+
+func (ui *UserIdentity) read(src io.Reader) (err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(src, header[:]); err != nil {
+		return err
+	}
+	ui.Type = UserIdentityType(header[0])
+	ui.PositiveResponseRequested = header[1] == 1
+
+	if ui.PrimaryField, err = readField(src); err != nil {
+		return err
+	}
+
+	ui.SecondaryField, err = readField(src)
+	return err
+}
+
+func (uir UserIdentityResult) write(dst *bytes.Buffer) error {
+	var body bytes.Buffer
+	if err := writeField(&body, uir.ServerResponse); err != nil {
+		return err
+	}
+
+	return writeItem(dst, UserIdentityAC, body.Bytes())
+}
+
+func (uir *UserIdentityResult) read(src io.Reader) (err error) {
+	uir.ServerResponse, err = readField(src)
+	return err
+}
+
+// trimUID removes padding from a UID, although it isn't supposed to be there
+func trimUID(uid []byte) string {
+	return string(bytes.TrimRight(uid, " \x00"))
+}
+
+// NegotiatedRole returns the role of the association requestor for a SOP
+// class, given the role selections of the request and response.  Without
+// both a proposal and an answer, the requestor is only an SCU.
+// See PS 3.7, D.3.3.4.
+func NegotiatedRole(requested, accepted []SOPClassRole, sopClass string) Role {
+	var proposed, answered *SOPClassRole
+	for i := range requested {
+		if requested[i].SOPClass == sopClass {
+			proposed = &requested[i]
+		}
+	}
+	for i := range accepted {
+		if accepted[i].SOPClass == sopClass {
+			answered = &accepted[i]
+		}
+	}
+
+	if proposed == nil || answered == nil {
+		return DefaultRole
+	}
+
+	// the acceptor may only take away roles that were proposed:
+	return NewRole(proposed.Role.IsSCU() && answered.Role.IsSCU(),
+		proposed.Role.IsSCP() && answered.Role.IsSCP())
+}
+
+// checkRoles verifies that there is at most one role selection per SOP class
+func checkRoles(roles []SOPClassRole) error {
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		if seen[role.SOPClass] {
+			return fmt.Errorf("more than one role selection for %s",
+				role.SOPClass)
+		}
+		seen[role.SOPClass] = true
+	}
+
+	return nil
+}
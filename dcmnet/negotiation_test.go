@@ -0,0 +1,248 @@
+package dcmnet
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+const ctImageStorage = "1.2.840.10008.5.1.4.1.1.2"
+
+func TestAssocRQNegotiationRoundTrip(t *testing.T) {
+	exp := AssociateRQ{
+		AssociateRQAC: AssociateRQAC{
+			ProtocolVersion:    1,
+			CalledAE:           "CALLED",
+			CallingAE:          "CALLING",
+			ApplicationContext: DICOMApplicationContext,
+			PresentationContexts: []PresentationContext{{
+				ID:             1,
+				AbstractSyntax: ctImageStorage,
+				TransferSyntaxes: []dcm.TransferSyntax{
+					dcm.ImplicitVRLittleEndian,
+				},
+			}},
+			MaxPDULength: 16384,
+			Roles: []SOPClassRole{
+				{ctImageStorage, NewRole(false, true)},
+				{verification, NewRole(true, true)},
+			},
+			ExtendedNegotiations: []ExtendedNegotiation{
+				{ctImageStorage, []byte{2, 0, 0, 0, 1, 0}},
+			},
+		},
+		CommonExtendedNegotiations: []CommonExtendedNegotiation{
+			{
+				SOPClass:     "1.2.3.4",
+				ServiceClass: "1.2.840.10008.4.2",
+				RelatedGeneralSOPClasses: []string{
+					ctImageStorage, "1.2.840.10008.5.1.4.1.1.4",
+				},
+			},
+			{
+				SOPClass:     "1.2.3.5",
+				ServiceClass: "1.2.840.10008.4.2",
+			},
+		},
+		UserIdentity: &UserIdentity{
+			Type:                      UserIdentityUsernamePasscode,
+			PositiveResponseRequested: true,
+			PrimaryField:              []byte("user"),
+			SecondaryField:            []byte("secret"),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := exp.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got AssociateRQ
+	if err := got.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("expected\n%#v\ngot\n%#v", exp, got)
+	}
+}
+
+func TestAssocACNegotiationRoundTrip(t *testing.T) {
+	exp := AssociateAC{
+		AssociateRQAC: AssociateRQAC{
+			ProtocolVersion:    1,
+			CalledAE:           "CALLED",
+			CallingAE:          "CALLING",
+			ApplicationContext: DICOMApplicationContext,
+			MaxPDULength:       16384,
+			Roles: []SOPClassRole{
+				{ctImageStorage, NewRole(false, true)},
+			},
+		},
+		UserIdentity: &UserIdentityResult{
+			ServerResponse: []byte("ticket"),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := exp.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got AssociateAC
+	if err := got.Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("expected\n%#v\ngot\n%#v", exp, got)
+	}
+}
+
+func TestWriteNegotiationItems(t *testing.T) {
+	for _, test := range []struct {
+		write func(*bytes.Buffer) error
+		exp   bytes.Buffer
+	}{
+		{
+			SOPClassRole{"1.2.3", NewRole(false, true)}.write,
+			bufitem(RoleSelection, []byte{0, 5}, "1.2.3", []byte{0, 1}),
+		},
+		{
+			ExtendedNegotiation{"1.2.3", []byte{1, 0}}.write,
+			bufitem(SOPClassExtendedNegotiation, []byte{0, 5}, "1.2.3",
+				[]byte{1, 0}),
+		},
+		{
+			CommonExtendedNegotiation{"1.2.3", "1.4", []string{"1.5"}}.write,
+			bufitem(SOPClassCommonExtendedNegotiation,
+				[]byte{0, 5}, "1.2.3", []byte{0, 3}, "1.4",
+				[]byte{0, 5}, []byte{0, 3}, "1.5"),
+		},
+		{
+			UserIdentity{UserIdentityUsername, false, []byte("user"), nil}.write,
+			bufitem(UserIdentityRQ, []byte{1, 0, 0, 4}, "user", []byte{0, 0}),
+		},
+		{
+			UserIdentityResult{}.write,
+			bufitem(UserIdentityAC, []byte{0, 0}),
+		},
+	} {
+		var got bytes.Buffer
+		if err := test.write(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(test.exp.Bytes(), got.Bytes()) {
+			t.Errorf("expected\n% x\ngot\n% x", test.exp.Bytes(), got.Bytes())
+		}
+	}
+}
+
+func TestWriteDuplicateRoles(t *testing.T) {
+	rq := AssociateRQ{AssociateRQAC: AssociateRQAC{
+		Roles: []SOPClassRole{
+			{ctImageStorage, NewRole(true, false)},
+			{ctImageStorage, NewRole(false, true)},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := rq.Write(&buf); err == nil {
+		t.Fatal("expected error for duplicate role selection")
+	}
+}
+
+func TestNegotiatedRole(t *testing.T) {
+	scp := []SOPClassRole{{ctImageStorage, NewRole(false, true)}}
+	both := []SOPClassRole{{ctImageStorage, NewRole(true, true)}}
+
+	for _, test := range []struct {
+		requested, accepted []SOPClassRole
+		exp                 Role
+	}{
+		{nil, nil, DefaultRole},
+		// not answered:
+		{scp, nil, DefaultRole},
+		{scp, scp, NewRole(false, true)},
+		{both, scp, NewRole(false, true)},
+		// the acceptor can't add roles:
+		{scp, both, NewRole(false, true)},
+	} {
+		got := NegotiatedRole(test.requested, test.accepted, ctImageStorage)
+		if got != test.exp {
+			t.Errorf("%v, %v: expected %s, got %s",
+				test.requested, test.accepted, test.exp, got)
+		}
+	}
+}
+
+func TestPolicyRoleSelection(t *testing.T) {
+	policy := Policy{
+		TransferCapabilities: []TransferCapability{
+			{
+				AbstractSyntax:   ctImageStorage,
+				Role:             NewRole(true, true),
+				TransferSyntaxes: []dcm.TransferSyntax{dcm.ImplicitVRLittleEndian},
+			},
+			{
+				AbstractSyntax:   verification,
+				TransferSyntaxes: []dcm.TransferSyntax{dcm.ImplicitVRLittleEndian},
+			},
+		},
+	}
+
+	// as for a c-get, where the requestor stores the images:
+	rq := AssociateRQ{AssociateRQAC: AssociateRQAC{
+		ProtocolVersion: 1,
+		PresentationContexts: []PresentationContext{
+			{ID: 1, AbstractSyntax: ctImageStorage, TransferSyntaxes: []dcm.TransferSyntax{
+				dcm.ImplicitVRLittleEndian}},
+			{ID: 3, AbstractSyntax: verification, TransferSyntaxes: []dcm.TransferSyntax{
+				dcm.ImplicitVRLittleEndian}},
+		},
+		Roles: []SOPClassRole{
+			{ctImageStorage, NewRole(false, true)},
+			// we only allow the default:
+			{verification, NewRole(false, true)},
+		},
+	}}
+
+	ac, err := policy.Accept(rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ac.PresentationContexts[0].Result.IsAcceptance() {
+		t.Errorf("expected acceptance, got %s", ac.PresentationContexts[0].Result)
+	}
+	if ac.PresentationContexts[1].Result != PCUserRejection {
+		t.Errorf("expected user rejection, got %s", ac.PresentationContexts[1].Result)
+	}
+
+	expRoles := []SOPClassRole{{ctImageStorage, NewRole(false, true)}}
+	if !reflect.DeepEqual(expRoles, ac.Roles) {
+		t.Fatalf("expected roles %v, got %v", expRoles, ac.Roles)
+	}
+
+	if got := NegotiatedRole(rq.Roles, ac.Roles, ctImageStorage); got != NewRole(false, true) {
+		t.Fatalf("unexpected negotiated role %s", got)
+	}
+}
+
+func TestWriteInvalidUserIdentity(t *testing.T) {
+	for _, id := range []*UserIdentity{
+		{Type: 9, PrimaryField: []byte("user")},
+		{Type: UserIdentityJWT},
+		{Type: UserIdentityUsernamePasscode, PrimaryField: []byte("user")},
+	} {
+		rq := AssociateRQ{UserIdentity: id}
+
+		var buf bytes.Buffer
+		if err := rq.Write(&buf); err == nil {
+			t.Errorf("expected error for %v", id)
+		}
+	}
+}
@@ -2,10 +2,10 @@ package dcmnet
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 
 	"github.com/jeremyhuiskamp/dcm/dcm"
 )
@@ -36,46 +36,49 @@ type PresentationContext struct {
 	TransferSyntaxes []dcm.TransferSyntax
 }
 
-func (pc PresentationContext) Write(dst io.Writer) {
-	// item type
-	// TODO wrong! differentiate between request and response
-	// TODO this should be a uint8 and a reserved 0x0
-	binary.Write(dst, binary.LittleEndian, uint16(0x20))
+// Write writes the body of a presentation context item.  The abstract syntax
+// is only written if it is set, since responses don't have one.
+// See PS 3.8, 9.3.2.2 and 9.3.3.2.
+func (pc PresentationContext) Write(dst io.Writer) error {
+	var buf bytes.Buffer
 
-	buf := new(bytes.Buffer)
-
-	// TODO: write the result!
 	buf.WriteByte(byte(pc.ID))
 	buf.WriteByte(0)
-	buf.WriteByte(0)
+	// the result is only significant in a response, and is reserved (0) in
+	// a request:
+	buf.WriteByte(byte(pc.Result))
 	buf.WriteByte(0)
 
-	// TODO use constants here and below
-	binary.Write(buf, binary.LittleEndian, uint16(0x30))
-	binary.Write(buf, binary.BigEndian, uint16(len(pc.AbstractSyntax)))
-	buf.WriteString(pc.AbstractSyntax)
+	if pc.AbstractSyntax != "" {
+		err := writeItem(&buf, AbstractSyntax, []byte(pc.AbstractSyntax))
+		if err != nil {
+			return err
+		}
+	}
 
 	for _, ts := range pc.TransferSyntaxes {
-		binary.Write(buf, binary.LittleEndian, uint16(0x40))
-		binary.Write(buf, binary.BigEndian, uint16(len(ts.UID())))
-		buf.WriteString(ts.UID())
+		if err := writeItem(&buf, TransferSyntax, []byte(ts.UID())); err != nil {
+			return err
+		}
 	}
 
-	binary.Write(dst, binary.BigEndian, uint16(buf.Len()))
-	dst.Write(buf.Bytes())
+	_, err := buf.WriteTo(dst)
+	return err
 }
 
+// readString reads the value of an item.  Padding is removed, although it
+// isn't supposed to be there.
 func readString(src io.Reader) (string, error) {
 	bytes, err := ioutil.ReadAll(src)
 	if err != nil {
 		return "", err
 	}
-	return string(bytes), nil
+	return strings.TrimRight(string(bytes), " \x00"), nil
 }
 
 func (pc *PresentationContext) Read(src io.Reader) error {
 	var buf [4]byte
-	_, err := src.Read(buf[:])
+	_, err := io.ReadFull(src, buf[:])
 	if err != nil {
 		return err
 	}
@@ -9,6 +9,7 @@ import (
 type PDataReader struct {
 	pdus PDUDecoder
 	pdu  *PDU
+	err  error
 }
 
 func NewPDataReader(pdus PDUDecoder) PDataReader {
@@ -59,14 +60,17 @@ func (pdr *PDataReader) Read(buf []byte) (int, error) {
 func (pdr *PDataReader) nextPDU() error {
 	nextpdu, err := pdr.pdus.NextPDU()
 	if err != nil {
-		// TODO: preserve error for inspection after PData?
 		// don't preserve current pdu, if any:
 		pdr.pdu = nil
+		pdr.err = err
 
 		return err
 	}
 
 	pdr.pdu = nextpdu
+	if nextpdu == nil {
+		pdr.err = io.EOF
+	}
 
 	return nil
 }
@@ -77,3 +81,10 @@ func (pdr *PDataReader) nextPDU() error {
 func (pdr PDataReader) GetFinalPDU() *PDU {
 	return pdr.pdu
 }
+
+// ended is whether Read has reached the end of the presentation data,
+// because of another PDU, the end of the stream or an error.
+func (pdr PDataReader) ended() bool {
+	return pdr.err != nil ||
+		(pdr.pdu != nil && pdr.pdu.Type != PDUPresentationData)
+}
@@ -0,0 +1,394 @@
+package dcmnet
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+// Acceptor decides whether to accept association requests, and which of
+// their presentation contexts.
+type Acceptor interface {
+	// Accept returns the response to an association request.  To reject
+	// the association, it returns an error, which should be an AssociateRJ.
+	// Other errors are reported to the peer as a permanent rejection by the
+	// service-user with no reason given.
+	Accept(rq AssociateRQ) (AssociateAC, error)
+}
+
+// AcceptorFunc allows a function to be used as an Acceptor.
+type AcceptorFunc func(rq AssociateRQ) (AssociateAC, error)
+
+func (f AcceptorFunc) Accept(rq AssociateRQ) (AssociateAC, error) {
+	return f(rq)
+}
+
+// Policy is an Acceptor that accepts associations based on AE titles and
+// the transfer capabilities that it supports.
+type Policy struct {
+	// CalledAETitles are the AE titles that we answer to.  If empty, any
+	// called AE title is accepted.
+	CalledAETitles []string
+
+	// CallingAETitles are the AE titles that may request associations.  If
+	// empty, any calling AE title is accepted.
+	CallingAETitles []string
+
+	// TransferCapabilities are the abstract syntaxes that we support, with
+	// their transfer syntaxes in order of preference.  The Role is that which
+	// the requestor may take, or DefaultRole if it is zero.  The requestor
+	// gets the roles that it proposes, out of those.
+	TransferCapabilities []TransferCapability
+
+	// MaxPDULength is the length of the largest PDU that we are willing to
+	// receive.  If zero, DefaultMaxPDULength is used.
+	MaxPDULength uint32
+
+	ImplementationClassUID string
+	ImplementationVersion  string
+
+	// Authenticate, if set, checks the identity of the user, after the AE
+	// titles have been accepted.  See Authenticator.
+	Authenticate Authenticator
+}
+
+// Authenticator checks the identity of the user that requests an
+// association.  The identity is nil if the request doesn't have one.
+//
+// If the identity asks for a positive response, the server response is sent
+// back.  It should be empty unless the identity is a Kerberos service
+// ticket or a SAML assertion that has a response.
+//
+// To reject the association, it returns an error, as for Acceptor.Accept.
+// The standard has no rejection reason for authentication failures, so
+// ErrAuthenticationFailed gives no reason.
+type Authenticator func(id *UserIdentity) (serverResponse []byte, err error)
+
+// ErrAuthenticationFailed is the rejection of a user that can't be
+// authenticated.
+var ErrAuthenticationFailed = AssociateRJ{RJPermanent, RJServiceUser,
+	RJNoReasonGiven}
+
+func containsAE(aes []string, ae string) bool {
+	if len(aes) == 0 {
+		return true
+	}
+
+	for _, candidate := range aes {
+		if candidate == ae {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p Policy) Accept(rq AssociateRQ) (AssociateAC, error) {
+	var ac AssociateAC
+
+	switch {
+	case rq.ProtocolVersion&1 == 0:
+		return ac, AssociateRJ{RJPermanent, RJServiceProviderACSE,
+			RJProtocolVersionNotSupported}
+
+	// an absent application context is tolerated:
+	case rq.ApplicationContext != "" &&
+		rq.ApplicationContext != DICOMApplicationContext:
+		return ac, AssociateRJ{RJPermanent, RJServiceUser,
+			RJApplicationContextNotSupported}
+
+	case !containsAE(p.CalledAETitles, rq.CalledAE):
+		return ac, AssociateRJ{RJPermanent, RJServiceUser,
+			RJCalledAENotRecognized}
+
+	case !containsAE(p.CallingAETitles, rq.CallingAE):
+		return ac, AssociateRJ{RJPermanent, RJServiceUser,
+			RJCallingAENotRecognized}
+	}
+
+	if p.Authenticate != nil {
+		serverResponse, err := p.Authenticate(rq.UserIdentity)
+		if err != nil {
+			return ac, err
+		}
+
+		if rq.UserIdentity != nil && rq.UserIdentity.PositiveResponseRequested {
+			ac.UserIdentity = &UserIdentityResult{serverResponse}
+		}
+	}
+
+	ac.ProtocolVersion = 1
+	ac.CalledAE = rq.CalledAE
+	ac.CallingAE = rq.CallingAE
+	ac.ApplicationContext = DICOMApplicationContext
+	ac.MaxPDULength = p.MaxPDULength
+	if ac.MaxPDULength == 0 {
+		ac.MaxPDULength = DefaultMaxPDULength
+	}
+	ac.ImplementationClassUID = p.ImplementationClassUID
+	ac.ImplementationVersion = p.ImplementationVersion
+
+	answered := make(map[string]bool)
+	for _, pc := range rq.PresentationContexts {
+		proposed, selected := proposedRole(rq.Roles, pc.AbstractSyntax)
+
+		acpc, role := p.acceptPresentationContext(pc, proposed)
+		ac.PresentationContexts = append(ac.PresentationContexts, acpc)
+
+		// role selections are answered once per SOP class:
+		if selected && acpc.Result.IsAcceptance() && !answered[pc.AbstractSyntax] {
+			ac.Roles = append(ac.Roles, SOPClassRole{pc.AbstractSyntax, role})
+			answered[pc.AbstractSyntax] = true
+		}
+	}
+
+	return ac, nil
+}
+
+// proposedRole finds the role that the requestor proposes for a SOP class,
+// and whether it was selected explicitly.
+func proposedRole(roles []SOPClassRole, sopClass string) (Role, bool) {
+	for _, role := range roles {
+		if role.SOPClass == sopClass {
+			return role.Role, true
+		}
+	}
+
+	return DefaultRole, false
+}
+
+// acceptPresentationContext chooses the first transfer syntax that we
+// prefer out of those that are proposed, or rejects the presentation context.
+// It also returns the role of the requestor, out of those that are proposed.
+func (p Policy) acceptPresentationContext(pc PresentationContext,
+	proposed Role) (PresentationContext, Role) {
+
+	var role Role
+	result := PresentationContext{
+		ID:     pc.ID,
+		Result: PCProviderRejectionAbstractSyntaxNotSupported,
+	}
+
+	// the transfer syntax of a rejection isn't significant, but some peers
+	// expect one:
+	if len(pc.TransferSyntaxes) > 0 {
+		result.TransferSyntaxes = pc.TransferSyntaxes[:1]
+	}
+
+	for _, tcap := range p.TransferCapabilities {
+		if tcap.AbstractSyntax != pc.AbstractSyntax {
+			continue
+		}
+
+		allowed := tcap.Role
+		if allowed == (Role{}) {
+			allowed = DefaultRole
+		}
+
+		role = NewRole(proposed.IsSCU() && allowed.IsSCU(),
+			proposed.IsSCP() && allowed.IsSCP())
+		if role == (Role{}) {
+			result.Result = PCUserRejection
+			continue
+		}
+
+		result.Result = PCProviderRejectionTransferSyntaxesNotSupported
+
+		for _, ts := range tcap.TransferSyntaxes {
+			if overlap([]dcm.TransferSyntax{ts}, pc.TransferSyntaxes) {
+				result.Result = PCAcceptance
+				result.TransferSyntaxes = []dcm.TransferSyntax{ts}
+				return result, role
+			}
+		}
+	}
+
+	return result, role
+}
+
+// AcceptAssociation reads an association request from a connection that has
+// just been accepted and answers it as the acceptor decides.  If the
+// association is rejected, the error is the AssociateRJ that was sent.  If
+// the association is not established, the connection is closed.
+//
+// The context limits the negotiation of the association, but not its use
+// afterwards.  It plays the part of the ARTIM timer while waiting for the
+// request.
+func AcceptAssociation(ctx context.Context, conn net.Conn, acceptor Acceptor) (*Association, error) {
+	as := &Association{
+		conn: conn,
+		sm:   newStateMachine(false),
+		pdus: NewPDUEncoder(conn),
+	}
+
+	as.sm.handle(evtTransportIndication)
+
+	stop := interruptOnDone(ctx, conn)
+	pdus, err := as.answer(acceptor)
+	stop()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			as.sm.handle(evtARTIMExpired)
+			err = ctx.Err()
+		}
+		as.Close()
+		return nil, err
+	}
+
+	as.maxPDULength = as.rq.MaxPDULength
+	as.start(pdus)
+
+	return as, nil
+}
+
+// answer reads the request and sends the response, returning the decoder
+// for the PDUs that follow.
+func (as *Association) answer(acceptor Acceptor) (pdus PDUDecoder, err error) {
+	pdus = NewPDUDecoder(as.conn)
+	pdu, err := pdus.NextPDU()
+	if err != nil {
+		return pdus, err
+	}
+
+	evt := pduEvent(pdu)
+	if evt == evtAssociateRQPDU {
+		if err = as.rq.Read(pdu.Data); err != nil {
+			evt = evtInvalidPDU
+		}
+	}
+
+	if _, rerr := as.receive(evt, pdu); rerr != nil {
+		return pdus, rerr
+	}
+
+	var ac bytes.Buffer
+	as.ac, err = acceptor.Accept(as.rq)
+	if err == nil {
+		as.contexts = PresentationContexts{
+			Requested: as.rq.PresentationContexts,
+			Accepted:  as.ac.PresentationContexts,
+		}
+		err = checkAccepted(as.contexts)
+	}
+	if err == nil {
+		err = as.ac.Write(&ac)
+	}
+
+	if err != nil {
+		rj, ok := err.(AssociateRJ)
+		if !ok {
+			rj = AssociateRJ{RJPermanent, RJServiceUser, RJNoReasonGiven}
+		}
+
+		as.sm.handle(evtAssociateReject)
+		rjerr := as.send(PDUAssociateRJ, rj)
+		as.awaitClose()
+		if rjerr != nil {
+			return pdus, rjerr
+		}
+
+		return pdus, err
+	}
+
+	as.sm.handle(evtAssociateAccept)
+	return pdus, as.pdus.NextPDU(PDU{
+		Type:   PDUAssociateAC,
+		Length: uint32(ac.Len()),
+		Data:   &ac,
+	})
+}
+
+// Handler serves associations that have been accepted.
+//
+// ServeAssociation should receive messages until the decoder has no more.
+// After it returns, the association is released if the peer asked for that,
+// or aborted if the handler stopped early, and the connection is closed.
+// The handler may also release or abort the association itself.
+type Handler interface {
+	ServeAssociation(as *Association)
+}
+
+// HandlerFunc allows a function to be used as a Handler.
+type HandlerFunc func(as *Association)
+
+func (f HandlerFunc) ServeAssociation(as *Association) {
+	f(as)
+}
+
+// Server accepts associations on a listener and serves each one in its own
+// goroutine.
+type Server struct {
+	Acceptor Acceptor
+	Handler  Handler
+
+	// RequestTimeout limits the time to wait for an association request
+	// on a new connection.  If zero, there is no limit.
+	RequestTimeout time.Duration
+
+	// ErrorLog receives errors on individual connections.  If nil, they go
+	// to the standard logger.
+	ErrorLog *log.Logger
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// ListenAndServe listens on a TCP address and serves associations until
+// there is an error.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return s.Serve(l)
+}
+
+// Serve serves associations on the listener until it fails, eg, because it
+// has been closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	ctx := context.Background()
+	if s.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.RequestTimeout)
+		defer cancel()
+	}
+
+	as, err := AcceptAssociation(ctx, conn, s.Acceptor)
+	if err != nil {
+		s.logf("dcmnet: association from %s failed: %s",
+			conn.RemoteAddr(), err)
+		return
+	}
+
+	s.Handler.ServeAssociation(as)
+
+	if err = as.finish(); err != nil {
+		s.logf("dcmnet: association from %s ended badly: %s",
+			conn.RemoteAddr(), err)
+	}
+}
@@ -0,0 +1,465 @@
+package dcmnet
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+var testPolicy = Policy{
+	CalledAETitles:  []string{"CALLED"},
+	CallingAETitles: []string{"CALLING"},
+	TransferCapabilities: []TransferCapability{
+		NewTransferCapability(verification, dcm.ImplicitVRLittleEndian),
+		NewTransferCapability("1.2.840.10008.5.1.4.1.1.2",
+			dcm.ExplicitVRBigEndian, dcm.ExplicitVRLittleEndian,
+			dcm.ImplicitVRLittleEndian),
+		{
+			AbstractSyntax:   "1.2.840.10008.5.1.4.1.1.7",
+			Role:             NewRole(false, true),
+			TransferSyntaxes: []dcm.TransferSyntax{dcm.ImplicitVRLittleEndian},
+		},
+	},
+}
+
+func TestPolicyRejectsAssociation(t *testing.T) {
+	for _, test := range []struct {
+		rq  AssociateRQAC
+		exp AssociateRJ
+	}{
+		{
+			AssociateRQAC{ProtocolVersion: 1, CalledAE: "OTHER", CallingAE: "CALLING"},
+			AssociateRJ{RJPermanent, RJServiceUser, RJCalledAENotRecognized},
+		},
+		{
+			AssociateRQAC{ProtocolVersion: 1, CalledAE: "CALLED", CallingAE: "OTHER"},
+			AssociateRJ{RJPermanent, RJServiceUser, RJCallingAENotRecognized},
+		},
+		{
+			AssociateRQAC{ProtocolVersion: 2, CalledAE: "CALLED", CallingAE: "CALLING"},
+			AssociateRJ{RJPermanent, RJServiceProviderACSE,
+				RJProtocolVersionNotSupported},
+		},
+		{
+			AssociateRQAC{ProtocolVersion: 1, CalledAE: "CALLED", CallingAE: "CALLING",
+				ApplicationContext: "1.2.3"},
+			AssociateRJ{RJPermanent, RJServiceUser,
+				RJApplicationContextNotSupported},
+		},
+	} {
+		_, err := testPolicy.Accept(AssociateRQ{AssociateRQAC: test.rq})
+		if err != test.exp {
+			t.Errorf("%+v: expected %v, got %v", test.rq, test.exp, err)
+		}
+	}
+}
+
+func TestPolicyPresentationContexts(t *testing.T) {
+	rq := AssociateRQ{AssociateRQAC: AssociateRQAC{
+		ProtocolVersion: 1,
+		CalledAE:        "CALLED",
+		CallingAE:       "CALLING",
+		PresentationContexts: []PresentationContext{
+			{ID: 1, AbstractSyntax: verification, TransferSyntaxes: []dcm.TransferSyntax{
+				dcm.ExplicitVRLittleEndian, dcm.ImplicitVRLittleEndian}},
+			// our preference is used, not the requestor's:
+			{ID: 3, AbstractSyntax: "1.2.840.10008.5.1.4.1.1.2", TransferSyntaxes: []dcm.TransferSyntax{
+				dcm.ImplicitVRLittleEndian, dcm.ExplicitVRLittleEndian}},
+			{ID: 5, AbstractSyntax: verification, TransferSyntaxes: []dcm.TransferSyntax{
+				dcm.ExplicitVRBigEndian}},
+			{ID: 7, AbstractSyntax: "1.2.3", TransferSyntaxes: []dcm.TransferSyntax{
+				dcm.ImplicitVRLittleEndian}},
+			// only allows the requestor to be an scp:
+			{ID: 9, AbstractSyntax: "1.2.840.10008.5.1.4.1.1.7", TransferSyntaxes: []dcm.TransferSyntax{
+				dcm.ImplicitVRLittleEndian}},
+		},
+	}}
+
+	ac, err := testPolicy.Accept(rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []struct {
+		result PCResult
+		ts     string
+	}{
+		{PCAcceptance, dcm.ImplicitVRLittleEndian.UID()},
+		{PCAcceptance, dcm.ExplicitVRLittleEndian.UID()},
+		{PCProviderRejectionTransferSyntaxesNotSupported, ""},
+		{PCProviderRejectionAbstractSyntaxNotSupported, ""},
+		{PCUserRejection, ""},
+	}
+
+	if len(ac.PresentationContexts) != len(exp) {
+		t.Fatalf("unexpected presentation contexts %v", ac.PresentationContexts)
+	}
+
+	for i, pc := range ac.PresentationContexts {
+		if pc.ID != rq.PresentationContexts[i].ID {
+			t.Errorf("expected id %d, got %d", rq.PresentationContexts[i].ID, pc.ID)
+		}
+
+		if pc.Result != exp[i].result {
+			t.Errorf("%d: expected %s, got %s", pc.ID, exp[i].result, pc.Result)
+		}
+
+		if exp[i].ts != "" && (len(pc.TransferSyntaxes) != 1 ||
+			pc.TransferSyntaxes[0].UID() != exp[i].ts) {
+			t.Errorf("%d: expected %s, got %v", pc.ID, exp[i].ts, pc.TransferSyntaxes)
+		}
+	}
+
+	if ac.MaxPDULength != DefaultMaxPDULength {
+		t.Errorf("unexpected max pdu length %d", ac.MaxPDULength)
+	}
+}
+
+// echoHandler answers echo requests
+var echoHandler = HandlerFunc(func(as *Association) {
+	for {
+		msg, err := as.Decoder().NextMessage()
+		if msg == nil || err != nil {
+			return
+		}
+
+		var msgID uint16
+		msg.Command.Scan(dcm.MessageID, &msgID)
+
+		rsp := dcm.NewObject()
+		rsp.Set(dcm.AffectedSOPClassUID, verification)
+		rsp.Set(dcm.CommandField, int(CEchoRsp))
+		rsp.Set(dcm.MessageIDBeingRespondedTo, int(msgID))
+		rsp.Set(dcm.CommandDataSetType, int(CommandHasNoDataSet))
+		rsp.Set(dcm.Status, 0)
+
+		as.Encoder().NextMessage(Message{Command: rsp, TCap: msg.TCap})
+	}
+})
+
+func startServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := Server{
+		Acceptor:       testPolicy,
+		Handler:        echoHandler,
+		RequestTimeout: time.Second,
+		ErrorLog:       log.New(ioutil.Discard, "", 0),
+	}
+	go server.Serve(l)
+
+	return l
+}
+
+func TestServerEcho(t *testing.T) {
+	l := startServer(t)
+	defer l.Close()
+
+	as, err := Dial(context.Background(), l.Addr().String(), testRQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcaps := as.AcceptedTCaps()
+	if len(tcaps) != 2 || tcaps[0].AbstractSyntax != verification {
+		as.Close()
+		t.Fatalf("unexpected accepted transfer capabilities %v", tcaps)
+	}
+
+	if as.MaxPDULength() != DefaultMaxPDULength {
+		t.Errorf("unexpected max pdu length %d", as.MaxPDULength())
+	}
+
+	for msgID := 1; msgID <= 2; msgID++ {
+		rq := dcm.NewObject()
+		rq.Set(dcm.AffectedSOPClassUID, verification)
+		rq.Set(dcm.CommandField, int(CEchoReq))
+		rq.Set(dcm.MessageID, msgID)
+		rq.Set(dcm.CommandDataSetType, int(CommandHasNoDataSet))
+
+		err = as.Encoder().NextMessage(Message{Command: rq, TCap: tcaps[0]})
+		if err != nil {
+			as.Close()
+			t.Fatal(err)
+		}
+
+		rsp, err := as.Decoder().NextMessage()
+		if err != nil || rsp == nil {
+			as.Close()
+			t.Fatalf("no echo response: %v", err)
+		}
+
+		var respondedTo uint16
+		rsp.Command.Scan(dcm.MessageIDBeingRespondedTo, &respondedTo)
+		if int(respondedTo) != msgID {
+			t.Errorf("expected response to %d, got %d", msgID, respondedTo)
+		}
+	}
+
+	if err = as.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerRejects(t *testing.T) {
+	l := startServer(t)
+	defer l.Close()
+
+	rq := testRQ
+	rq.CalledAE = "OTHER"
+
+	_, err := Dial(context.Background(), l.Addr().String(), rq)
+
+	exp := AssociateRJ{RJPermanent, RJServiceUser, RJCalledAENotRecognized}
+	if err != exp {
+		t.Fatalf("expected %v, got %v", exp, err)
+	}
+}
+
+// authenticate accepts user "user" with passcode "secret", and the JWT
+// "token", which gets a server response
+func authenticate(id *UserIdentity) ([]byte, error) {
+	switch {
+	case id == nil:
+		return nil, ErrAuthenticationFailed
+
+	case id.Type == UserIdentityUsernamePasscode &&
+		string(id.PrimaryField) == "user" &&
+		string(id.SecondaryField) == "secret":
+		return nil, nil
+
+	case id.Type == UserIdentityJWT && string(id.PrimaryField) == "token":
+		return []byte("response"), nil
+
+	default:
+		return nil, ErrAuthenticationFailed
+	}
+}
+
+func TestPolicyAuthenticate(t *testing.T) {
+	policy := testPolicy
+	policy.Authenticate = authenticate
+
+	positive := NewJWTIdentity("token")
+	positive.PositiveResponseRequested = true
+
+	for _, test := range []struct {
+		id       *UserIdentity
+		accepted bool
+		response *UserIdentityResult
+	}{
+		{nil, false, nil},
+		{NewUsernameIdentity("user"), false, nil},
+		{NewUsernamePasscodeIdentity("user", "wrong"), false, nil},
+		{NewUsernamePasscodeIdentity("user", "secret"), true, nil},
+		// no response unless it's requested:
+		{NewJWTIdentity("token"), true, nil},
+		{positive, true, &UserIdentityResult{[]byte("response")}},
+	} {
+		rq := testRQ
+		rq.ProtocolVersion = 1
+		rq.UserIdentity = test.id
+
+		ac, err := policy.Accept(rq)
+		if !test.accepted {
+			if err != ErrAuthenticationFailed {
+				t.Errorf("%v: expected rejection, got %v", test.id, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %s", test.id, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.response, ac.UserIdentity) {
+			t.Errorf("%v: expected response %v, got %v",
+				test.id, test.response, ac.UserIdentity)
+		}
+	}
+}
+
+func TestServerAuthentication(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	policy := testPolicy
+	policy.Authenticate = authenticate
+
+	identities := make(chan *UserIdentity, 1)
+	server := Server{
+		Acceptor: policy,
+		Handler: HandlerFunc(func(as *Association) {
+			identities <- as.Request().UserIdentity
+			echoHandler(as)
+		}),
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+	go server.Serve(l)
+
+	rq := testRQ
+	rq.UserIdentity = NewUsernamePasscodeIdentity("user", "wrong")
+	if _, err = Dial(context.Background(), l.Addr().String(), rq); err != ErrAuthenticationFailed {
+		t.Fatalf("expected rejection, got %v", err)
+	}
+
+	rq.UserIdentity = NewJWTIdentity("token")
+	rq.UserIdentity.PositiveResponseRequested = true
+	as, err := Dial(context.Background(), l.Addr().String(), rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := <-identities; id == nil || string(id.PrimaryField) != "token" {
+		t.Errorf("unexpected identity %v", id)
+	}
+
+	if rsp := as.Response().UserIdentity; rsp == nil ||
+		string(rsp.ServerResponse) != "response" {
+		t.Errorf("unexpected server response %v", rsp)
+	}
+
+	if err = as.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserIdentityString(t *testing.T) {
+	id := NewUsernamePasscodeIdentity("user", "secret")
+	if got := id.String(); got != `username and passcode "user"` {
+		t.Fatalf("unexpected description %s", got)
+	}
+
+	if got := NewJWTIdentity("abc").String(); got != "jwt (3 bytes)" {
+		t.Fatalf("unexpected description %s", got)
+	}
+}
+
+// serve accepts one association and hands it to the handler, returning the
+// address to dial and the result of finishing the association.
+func serve(t *testing.T, handler func(as *Association) error) (string, chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		as, err := AcceptAssociation(context.Background(), conn, testPolicy)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		errs <- handler(as)
+	}()
+
+	return l.Addr().String(), errs
+}
+
+func TestReleaseCollision(t *testing.T) {
+	// both peers ask for a release before reading anything:
+	addr, errs := serve(t, func(as *Association) error {
+		if err := as.Release(); err != nil {
+			return err
+		}
+		if as.State() != Sta1 {
+			return fmt.Errorf("acceptor ended in %s", as.State())
+		}
+		return nil
+	})
+
+	as, err := Dial(context.Background(), addr, testRQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = as.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if as.State() != Sta1 {
+		t.Fatalf("requestor ended in %s", as.State())
+	}
+
+	if err = <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseByAcceptor(t *testing.T) {
+	addr, errs := serve(t, func(as *Association) error {
+		return as.Release()
+	})
+
+	as, err := Dial(context.Background(), addr, testRQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := as.Decoder().NextMessage()
+	if msg != nil || err != nil {
+		t.Fatalf("expected no message, got %v, %v", msg, err)
+	}
+
+	// agrees to the acceptor's release:
+	if err = as.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerAbortsOnEarlyReturn(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	server := Server{
+		Acceptor: testPolicy,
+		// gives up without reading anything:
+		Handler:  HandlerFunc(func(as *Association) {}),
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+	go server.Serve(l)
+
+	as, err := Dial(context.Background(), l.Addr().String(), testRQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := as.Decoder().NextMessage()
+	if msg != nil || err != nil {
+		t.Fatalf("expected no message, got %v, %v", msg, err)
+	}
+
+	exp := Abort{Source: AbortServiceUser}
+	if err = as.Release(); err != exp {
+		t.Fatalf("expected %v, got %v", exp, err)
+	}
+}
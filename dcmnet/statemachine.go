@@ -0,0 +1,301 @@
+package dcmnet
+
+import (
+	"fmt"
+	"sync"
+)
+
+// State is a state of the DICOM upper layer state machine, which governs
+// how an association is established, released and aborted.
+// See PS 3.8, 9.2.
+type State uint8
+
+//go:generate stringer -type State
+const (
+	// Sta1 is idle: there is no association or connection.
+	Sta1 State = iota + 1
+	// Sta2 is an acceptor with an open connection, awaiting a request.
+	Sta2
+	// Sta3 is an acceptor deciding how to answer a request.
+	Sta3
+	// Sta4 is a requestor awaiting its connection to open.
+	Sta4
+	// Sta5 is a requestor awaiting the answer to its request.
+	Sta5
+	// Sta6 is an established association, ready for data.
+	Sta6
+	// Sta7 is awaiting the response to a release request.
+	Sta7
+	// Sta8 is deciding how to answer a release request.
+	Sta8
+	// Sta9 is a requestor answering a release request in a collision.
+	Sta9
+	// Sta10 is an acceptor awaiting a release response in a collision.
+	Sta10
+	// Sta11 is a requestor awaiting a release response in a collision.
+	Sta11
+	// Sta12 is an acceptor answering a release request in a collision.
+	Sta12
+	// Sta13 is awaiting the peer to close the connection.
+	Sta13
+)
+
+// event is something that moves the state machine.  See PS 3.8, table 9-6.
+type event uint8
+
+const (
+	evtAssociateRQLocal    event = iota + 1 // A-ASSOCIATE request (local user)
+	evtTransportConfirm                     // transport connection confirmed
+	evtAssociateACPDU                       // A-ASSOCIATE-AC PDU received
+	evtAssociateRJPDU                       // A-ASSOCIATE-RJ PDU received
+	evtTransportIndication                  // transport connection accepted
+	evtAssociateRQPDU                       // A-ASSOCIATE-RQ PDU received
+	evtAssociateAccept                      // A-ASSOCIATE response (accept)
+	evtAssociateReject                      // A-ASSOCIATE response (reject)
+	evtPDataLocal                           // P-DATA request (local user)
+	evtPDataPDU                             // P-DATA-TF PDU received
+	evtReleaseRQLocal                       // A-RELEASE request (local user)
+	evtReleaseRQPDU                         // A-RELEASE-RQ PDU received
+	evtReleaseRPPDU                         // A-RELEASE-RP PDU received
+	evtReleaseRPLocal                       // A-RELEASE response (local user)
+	evtAbortLocal                           // A-ABORT request (local user)
+	evtAbortPDU                             // A-ABORT PDU received
+	evtTransportClosed                      // transport connection closed
+	evtARTIMExpired                         // ARTIM timer expired
+	evtInvalidPDU                           // unrecognized or invalid PDU
+)
+
+var eventNames = [...]string{
+	evtAssociateRQLocal:    "A-ASSOCIATE request",
+	evtTransportConfirm:    "transport connection confirmation",
+	evtAssociateACPDU:      "A-ASSOCIATE-AC PDU",
+	evtAssociateRJPDU:      "A-ASSOCIATE-RJ PDU",
+	evtTransportIndication: "transport connection indication",
+	evtAssociateRQPDU:      "A-ASSOCIATE-RQ PDU",
+	evtAssociateAccept:     "A-ASSOCIATE response (accept)",
+	evtAssociateReject:     "A-ASSOCIATE response (reject)",
+	evtPDataLocal:          "P-DATA request",
+	evtPDataPDU:            "P-DATA-TF PDU",
+	evtReleaseRQLocal:      "A-RELEASE request",
+	evtReleaseRQPDU:        "A-RELEASE-RQ PDU",
+	evtReleaseRPPDU:        "A-RELEASE-RP PDU",
+	evtReleaseRPLocal:      "A-RELEASE response",
+	evtAbortLocal:          "A-ABORT request",
+	evtAbortPDU:            "A-ABORT PDU",
+	evtTransportClosed:     "transport connection closed",
+	evtARTIMExpired:        "ARTIM timer expiry",
+	evtInvalidPDU:          "unrecognized or invalid PDU",
+}
+
+func (e event) String() string {
+	if int(e) < len(eventNames) && eventNames[e] != "" {
+		return eventNames[e]
+	}
+	return fmt.Sprintf("event(%d)", uint8(e))
+}
+
+// pduEvent is the event for receiving a PDU, or for the connection closing
+// if it is nil.
+func pduEvent(pdu *PDU) event {
+	if pdu == nil {
+		return evtTransportClosed
+	}
+
+	switch pdu.Type {
+	case PDUAssociateRQ:
+		return evtAssociateRQPDU
+	case PDUAssociateAC:
+		return evtAssociateACPDU
+	case PDUAssociateRJ:
+		return evtAssociateRJPDU
+	case PDUPresentationData:
+		return evtPDataPDU
+	case PDUReleaseRQ:
+		return evtReleaseRQPDU
+	case PDUReleaseRP:
+		return evtReleaseRPPDU
+	case PDUAbort:
+		return evtAbortPDU
+	default:
+		return evtInvalidPDU
+	}
+}
+
+// action is what to do in response to an event.  See PS 3.8, table 9-7
+// to 9-9.
+type action uint8
+
+const (
+	ae1  action = iota + 1 // issue transport connect
+	ae2                    // send A-ASSOCIATE-RQ
+	ae3                    // issue A-ASSOCIATE confirmation (accept)
+	ae4                    // issue A-ASSOCIATE confirmation (reject), close
+	ae5                    // accept transport connection, start ARTIM
+	ae6                    // stop ARTIM, issue A-ASSOCIATE indication
+	ae7                    // send A-ASSOCIATE-AC
+	ae8                    // send A-ASSOCIATE-RJ, start ARTIM
+	dt1                    // send P-DATA-TF
+	dt2                    // issue P-DATA indication
+	ar1                    // send A-RELEASE-RQ
+	ar2                    // issue A-RELEASE indication
+	ar3                    // issue A-RELEASE confirmation, close
+	ar4                    // send A-RELEASE-RP, start ARTIM
+	ar5                    // stop ARTIM
+	ar6                    // issue P-DATA indication while releasing
+	ar7                    // send P-DATA-TF while releasing
+	ar8                    // issue A-RELEASE indication (collision)
+	ar9                    // send A-RELEASE-RP (collision, requestor)
+	ar10                   // issue A-RELEASE confirmation (collision, acceptor)
+	aa1                    // send A-ABORT (service-user), start ARTIM
+	aa2                    // stop ARTIM, close
+	aa3                    // issue A-ABORT or A-P-ABORT indication, close
+	aa4                    // issue A-P-ABORT indication
+	aa5                    // stop ARTIM
+	aa6                    // ignore PDU
+	aa7                    // send A-ABORT
+	aa8                    // send A-ABORT (service-provider), start ARTIM
+)
+
+var actionNames = [...]string{
+	ae1: "AE-1", ae2: "AE-2", ae3: "AE-3", ae4: "AE-4",
+	ae5: "AE-5", ae6: "AE-6", ae7: "AE-7", ae8: "AE-8",
+	dt1: "DT-1", dt2: "DT-2",
+	ar1: "AR-1", ar2: "AR-2", ar3: "AR-3", ar4: "AR-4", ar5: "AR-5",
+	ar6: "AR-6", ar7: "AR-7", ar8: "AR-8", ar9: "AR-9", ar10: "AR-10",
+	aa1: "AA-1", aa2: "AA-2", aa3: "AA-3", aa4: "AA-4",
+	aa5: "AA-5", aa6: "AA-6", aa7: "AA-7", aa8: "AA-8",
+}
+
+func (a action) String() string {
+	if int(a) < len(actionNames) && actionNames[a] != "" {
+		return actionNames[a]
+	}
+	return fmt.Sprintf("action(%d)", uint8(a))
+}
+
+// next is the state after an action.  The state after AR-8 depends on
+// whether we requested the association.  AE-6 always leads to Sta3, where
+// an unacceptable request is rejected.
+func (a action) next(requestor bool) State {
+	switch a {
+	case ae1:
+		return Sta4
+	case ae2:
+		return Sta5
+	case ae3, ae7, dt1, dt2:
+		return Sta6
+	case ae5:
+		return Sta2
+	case ae6:
+		return Sta3
+	case ar1, ar6:
+		return Sta7
+	case ar2, ar7:
+		return Sta8
+	case ar8:
+		if requestor {
+			return Sta9
+		}
+		return Sta10
+	case ar9:
+		return Sta11
+	case ar10:
+		return Sta12
+	case ae8, ar4, aa1, aa6, aa7, aa8:
+		return Sta13
+	default: // ae4, ar3, ar5, aa2, aa3, aa4, aa5
+		return Sta1
+	}
+}
+
+// transitions is the state transition table, by event and then by state.
+// Events that are missing for a state are not valid in that state.
+// See PS 3.8, table 9-10.
+var transitions = map[event]map[State]action{
+	evtAssociateRQLocal: {Sta1: ae1},
+	evtTransportConfirm: {Sta4: ae2},
+	evtAssociateACPDU: {
+		Sta2: aa1, Sta3: aa8, Sta5: ae3, Sta6: aa8, Sta7: aa8, Sta8: aa8,
+		Sta9: aa8, Sta10: aa8, Sta11: aa8, Sta12: aa8, Sta13: aa6,
+	},
+	evtAssociateRJPDU: {
+		Sta2: aa1, Sta3: aa8, Sta5: ae4, Sta6: aa8, Sta7: aa8, Sta8: aa8,
+		Sta9: aa8, Sta10: aa8, Sta11: aa8, Sta12: aa8, Sta13: aa6,
+	},
+	evtTransportIndication: {Sta1: ae5},
+	evtAssociateRQPDU: {
+		Sta2: ae6, Sta3: aa8, Sta5: aa8, Sta6: aa8, Sta7: aa8, Sta8: aa8,
+		Sta9: aa8, Sta10: aa8, Sta11: aa8, Sta12: aa8, Sta13: aa7,
+	},
+	evtAssociateAccept: {Sta3: ae7},
+	evtAssociateReject: {Sta3: ae8},
+	evtPDataLocal:      {Sta6: dt1, Sta8: ar7},
+	evtPDataPDU: {
+		Sta2: aa1, Sta3: aa8, Sta5: aa8, Sta6: dt2, Sta7: ar6, Sta8: aa8,
+		Sta9: aa8, Sta10: aa8, Sta11: aa8, Sta12: aa8, Sta13: aa6,
+	},
+	evtReleaseRQLocal: {Sta6: ar1},
+	evtReleaseRQPDU: {
+		Sta2: aa1, Sta3: aa8, Sta5: aa8, Sta6: ar2, Sta7: ar8, Sta8: aa8,
+		Sta9: aa8, Sta10: aa8, Sta11: aa8, Sta12: aa8, Sta13: aa6,
+	},
+	evtReleaseRPPDU: {
+		Sta2: aa1, Sta3: aa8, Sta5: aa8, Sta6: aa8, Sta7: ar3, Sta8: aa8,
+		Sta9: aa8, Sta10: ar10, Sta11: ar3, Sta12: aa8, Sta13: aa6,
+	},
+	evtReleaseRPLocal: {Sta8: ar4, Sta9: ar9, Sta12: ar4},
+	evtAbortLocal: {
+		Sta3: aa1, Sta4: aa2, Sta5: aa1, Sta6: aa1, Sta7: aa1, Sta8: aa1,
+		Sta9: aa1, Sta10: aa1, Sta11: aa1, Sta12: aa1,
+	},
+	evtAbortPDU: {
+		Sta2: aa2, Sta3: aa3, Sta5: aa3, Sta6: aa3, Sta7: aa3, Sta8: aa3,
+		Sta9: aa3, Sta10: aa3, Sta11: aa3, Sta12: aa3, Sta13: aa2,
+	},
+	evtTransportClosed: {
+		Sta2: aa5, Sta3: aa4, Sta5: aa4, Sta6: aa4, Sta7: aa4,
+		Sta8: aa4, Sta9: aa4, Sta10: aa4, Sta11: aa4, Sta12: aa4, Sta13: ar5,
+	},
+	evtARTIMExpired: {Sta2: aa2, Sta13: aa2},
+	evtInvalidPDU: {
+		Sta2: aa1, Sta3: aa8, Sta5: aa8, Sta6: aa8, Sta7: aa8, Sta8: aa8,
+		Sta9: aa8, Sta10: aa8, Sta11: aa8, Sta12: aa8, Sta13: aa7,
+	},
+}
+
+// stateMachine tracks the state of one association.  It only decides what
+// to do; the Association does it.  It is safe for concurrent use, so that
+// messages can be sent and received in different goroutines.
+type stateMachine struct {
+	mu        sync.Mutex
+	state     State
+	requestor bool
+}
+
+func newStateMachine(requestor bool) stateMachine {
+	return stateMachine{state: Sta1, requestor: requestor}
+}
+
+// handle moves to the next state for an event, returning the action to
+// take.  Events that are not valid in the current state are errors, and
+// leave the state as it was.
+func (m *stateMachine) handle(e event) (action, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	act, ok := transitions[e][m.state]
+	if !ok {
+		return 0, fmt.Errorf("%s is not valid in state %s", e, m.state)
+	}
+
+	m.state = act.next(m.requestor)
+
+	return act, nil
+}
+
+func (m *stateMachine) current() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
@@ -0,0 +1,95 @@
+package dcmnet
+
+import "testing"
+
+func TestStateMachine(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		requestor bool
+		events    []event
+		exp       []State
+	}{
+		{
+			"requestor releases",
+			true,
+			[]event{evtAssociateRQLocal, evtTransportConfirm,
+				evtAssociateACPDU, evtPDataLocal, evtPDataPDU,
+				evtReleaseRQLocal, evtPDataPDU, evtReleaseRPPDU},
+			[]State{Sta4, Sta5, Sta6, Sta6, Sta6, Sta7, Sta7, Sta1},
+		},
+		{
+			"acceptor is released",
+			false,
+			[]event{evtTransportIndication, evtAssociateRQPDU,
+				evtAssociateAccept, evtReleaseRQPDU, evtPDataLocal,
+				evtReleaseRPLocal, evtTransportClosed},
+			[]State{Sta2, Sta3, Sta6, Sta8, Sta8, Sta13, Sta1},
+		},
+		{
+			"acceptor rejects",
+			false,
+			[]event{evtTransportIndication, evtAssociateRQPDU,
+				evtAssociateReject, evtARTIMExpired},
+			[]State{Sta2, Sta3, Sta13, Sta1},
+		},
+		{
+			"requestor in release collision",
+			true,
+			[]event{evtAssociateRQLocal, evtTransportConfirm,
+				evtAssociateACPDU, evtReleaseRQLocal, evtReleaseRQPDU,
+				evtReleaseRPLocal, evtReleaseRPPDU},
+			[]State{Sta4, Sta5, Sta6, Sta7, Sta9, Sta11, Sta1},
+		},
+		{
+			"acceptor in release collision",
+			false,
+			[]event{evtTransportIndication, evtAssociateRQPDU,
+				evtAssociateAccept, evtReleaseRQLocal, evtReleaseRQPDU,
+				evtReleaseRPPDU, evtReleaseRPLocal, evtTransportClosed},
+			[]State{Sta2, Sta3, Sta6, Sta7, Sta10, Sta12, Sta13, Sta1},
+		},
+		{
+			"unexpected pdu",
+			true,
+			[]event{evtAssociateRQLocal, evtTransportConfirm,
+				evtAssociateACPDU, evtAssociateRQPDU, evtAssociateACPDU,
+				evtTransportClosed},
+			[]State{Sta4, Sta5, Sta6, Sta13, Sta13, Sta1},
+		},
+		{
+			"aborted",
+			true,
+			[]event{evtAssociateRQLocal, evtTransportConfirm,
+				evtAbortPDU},
+			[]State{Sta4, Sta5, Sta1},
+		},
+	} {
+		m := newStateMachine(test.requestor)
+		for i, evt := range test.events {
+			if _, err := m.handle(evt); err != nil {
+				t.Errorf("%s: %s", test.name, err)
+				break
+			}
+			if m.current() != test.exp[i] {
+				t.Errorf("%s: after %s, expected %s, got %s",
+					test.name, evt, test.exp[i], m.current())
+				break
+			}
+		}
+	}
+}
+
+func TestStateMachineInvalidEvent(t *testing.T) {
+	m := newStateMachine(true)
+
+	for _, evt := range []event{evtPDataLocal, evtReleaseRQLocal,
+		evtAbortLocal, evtAssociateACPDU} {
+		if _, err := m.handle(evt); err == nil {
+			t.Errorf("expected %s to be invalid in %s", evt, Sta1)
+		}
+	}
+
+	if m.current() != Sta1 {
+		t.Fatalf("expected %s, got %s", Sta1, m.current())
+	}
+}
@@ -53,6 +53,18 @@ func getpdu(in *bytes.Buffer) (typ PDUType, data bytes.Buffer, err error) {
 	return
 }
 
+// bufitem creates an item in a buffer
+func bufitem(typ ItemType, contents ...interface{}) (buf bytes.Buffer) {
+	payload := bufcat(contents...)
+	header := make([]byte, 4)
+	header[0] = byte(typ)
+	binary.BigEndian.PutUint16(header[2:4], uint16(payload.Len()))
+	buf.Write(header)
+	buf.ReadFrom(&payload)
+
+	return buf
+}
+
 // bufpdv creates a pdv in a buffer
 func bufpdv(context PCID, tipe PDVType, last bool, data interface{}) (buf bytes.Buffer) {
 	dataBuf := toBuffer(data)
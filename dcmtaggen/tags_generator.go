@@ -5,6 +5,7 @@ package main
 import (
 	"encoding/xml"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"regexp"
@@ -15,7 +16,7 @@ import (
 
 var (
 	tagRegex          = regexp.MustCompile(`^\(([[:alnum:]]{4}),([[:alnum:]]{4})\)$`)
-	multiplicityRegex = regexp.MustCompile(`([0-9])(-([0-9n]))?`)
+	multiplicityRegex = regexp.MustCompile(`^([0-9]+)(-([0-9]*n|[0-9]+))?$`)
 )
 
 // XML structs:
@@ -46,7 +47,14 @@ func (r Row) GetValues() []string {
 }
 
 type Table struct {
-	Rows []Row `xml:"tbody>tr"`
+	Caption string `xml:"caption"`
+	Rows    []Row  `xml:"tbody>tr"`
+}
+
+// Whether the whole table lists retired elements, as for the retired
+// command fields in part 7, which have no retired column.
+func (t Table) IsRetired() bool {
+	return strings.HasPrefix(strings.TrimSpace(t.Caption), "Retired")
 }
 
 type Section struct {
@@ -129,14 +137,26 @@ func (e Element) GetKeyword() string {
 	return regexp.MustCompile(`[^[:alnum:]]`).ReplaceAllString(e.Keyword, "")
 }
 
-// Unimplemented, not sure if useful
-func (e Element) GetLowMultiplicity() *uint32 {
-	return nil
-}
+// Get the range of the value multiplicity, eg 1-3.  The high value is -1
+// if it is unbounded, as in 1-n or 2-2n.  Both are 0 if the multiplicity
+// can't be parsed.
+func (e Element) GetMultiplicity() (low, high int) {
+	matches := multiplicityRegex.FindStringSubmatch(strings.TrimSpace(e.VM))
+	if matches == nil {
+		return 0, 0
+	}
 
-// Unimplemented, not sure if useful
-func (e Element) GetHighMultiplicity() *uint32 {
-	return nil
+	low, _ = strconv.Atoi(matches[1])
+
+	switch {
+	case matches[3] == "":
+		return low, low
+	case strings.HasSuffix(matches[3], "n"):
+		return low, -1
+	default:
+		high, _ = strconv.Atoi(matches[3])
+		return low, high
+	}
 }
 
 func (e Element) GetVR() string {
@@ -169,12 +189,16 @@ func (e Element) String() string {
 }
 
 func NewElement(row Row) Element {
+	// the column after the VM notes whether the element is retired
+	retired := len(row.Cells) > 5 &&
+		strings.HasPrefix(strings.TrimSpace(row.Cells[5].GetValue()), "RET")
+
 	return Element{
 		Tag:     row.Cells[0].GetValue(),
 		Keyword: row.Cells[2].GetValue(),
 		VR:      row.Cells[3].GetValue(),
 		VM:      row.Cells[4].GetValue(),
-		Retired: row.Cells[4].GetValue() == "RET",
+		Retired: retired,
 		Desc:    row.Cells[1].GetValue(),
 	}
 }
@@ -199,12 +223,18 @@ func parseStandard(filename string, elements elements, chapters ...string) {
 	}
 	defer stream.Close()
 
+	if err = parseBook(stream, elements, chapters...); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseBook reads the elements from the tables in the given chapters of a
+// part of the standard.
+func parseBook(stream io.Reader, elements elements, chapters ...string) error {
 	decoder := xml.NewDecoder(stream)
 	var book Book
-	err = decoder.Decode(&book)
-
-	if err != nil {
-		log.Fatal(err)
+	if err := decoder.Decode(&book); err != nil {
+		return err
 	}
 
 	tagChapters := NewSet(chapters...)
@@ -217,6 +247,7 @@ func parseStandard(filename string, elements elements, chapters ...string) {
 		for _, table := range chapter.GetTables() {
 			for _, row := range table.Rows {
 				element := NewElement(row)
+				element.Retired = element.Retired || table.IsRetired()
 
 				if element.GetTagLowValue() != nil {
 					elements[*element.GetTagLowValue()] = element
@@ -226,6 +257,8 @@ func parseStandard(filename string, elements elements, chapters ...string) {
 			}
 		}
 	}
+
+	return nil
 }
 
 func forEach(elements elements, f func(element Element)) {
@@ -286,11 +319,14 @@ func writeDictionary(elements elements) {
 		// TODO: support for multi-tag elements
 		// - either define a single value and map it multiple times
 		// - or enhance datadict.go to search for these somehow...
+		minVM, maxVM := element.GetMultiplicity()
 		fmt.Fprintf(out, "\t\t"+elementSpecPattern+"\n",
 			*element.GetTagLowValue(),
 			*element.GetTagLowValue(),
 			*element.GetTagHighValue(),
 			element.GetVR(),
+			minVM,
+			maxVM,
 			element.Retired,
 			element.Desc,
 			element.GetKeyword(),
@@ -309,7 +345,8 @@ var stddict = NewDataDictionary("",
 `
 
 const elementSpecPattern = `Tag(0x%08X): {tag: Tag(0x%08X), ` +
-	`maxValue: Tag(0x%08X), vr: %s, retired: %t, desc: "%s", keyword: "%s"},`
+	`maxValue: Tag(0x%08X), vr: %s, minVM: %d, maxVM: %d, retired: %t, ` +
+	`desc: "%s", keyword: "%s"},`
 
 // dumb util for set membership check
 type Set map[string]*struct{}
@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestParseTag(t *testing.T) {
 	for input, exp := range map[string]struct {
@@ -73,3 +76,79 @@ func TestParseVR(t *testing.T) {
 		}
 	}
 }
+
+func TestParseMultiplicity(t *testing.T) {
+	for input, exp := range map[string]struct {
+		low, high int
+	}{
+		"1":        {1, 1},
+		"1-3":      {1, 3},
+		"1-32":     {1, 32},
+		"1-n":      {1, -1},
+		"2-2n":     {2, -1},
+		" 3 ":      {3, 3},
+		"":         {0, 0},
+		"See Note": {0, 0},
+	} {
+		el := Element{VM: input}
+		if low, high := el.GetMultiplicity(); low != exp.low || high != exp.high {
+			t.Errorf("unexpected multiplicity %d-%d for %q", low, high, input)
+		}
+	}
+}
+
+// a cut down version of part 6 of the standard
+const part06 = `<book>
+<chapter label="6">
+<table>
+<caption>Registry of DICOM Data Elements</caption>
+<tbody>
+<tr>
+<td><para>(0010,0010)</para></td>
+<td><para>Patient's Name</para></td>
+<td><para>PatientName</para></td>
+<td><para>PN</para></td>
+<td><para>1</para></td>
+<td><para/></td>
+</tr>
+<tr>
+<td><para>(0010,1000)</para></td>
+<td><para><emphasis>Other Patient IDs</emphasis></para></td>
+<td><para><emphasis>OtherPatientIDs</emphasis></para></td>
+<td><para><emphasis>LO</emphasis></para></td>
+<td><para><emphasis>1-n</emphasis></para></td>
+<td><para><emphasis>RET</emphasis></para></td>
+</tr>
+</tbody>
+</table>
+</chapter>
+</book>`
+
+func TestParseBook(t *testing.T) {
+	elements := make(elements)
+	if err := parseBook(strings.NewReader(part06), elements, "6"); err != nil {
+		t.Fatal(err)
+	}
+
+	for tag, exp := range map[uint32]struct {
+		keyword   string
+		low, high int
+		retired   bool
+	}{
+		0x00100010: {"PatientName", 1, 1, false},
+		0x00101000: {"OtherPatientIDs", 1, -1, true},
+	} {
+		el, ok := elements[tag]
+		if !ok {
+			t.Errorf("missing %08X", tag)
+			continue
+		}
+
+		low, high := el.GetMultiplicity()
+		if el.GetKeyword() != exp.keyword || low != exp.low ||
+			high != exp.high || el.Retired != exp.retired {
+			t.Errorf("unexpected element %s: VM %d-%d, retired %t",
+				el, low, high, el.Retired)
+		}
+	}
+}
@@ -0,0 +1,504 @@
+// Package dcmxml converts dicom objects to and from the Native DICOM Model
+// XML, as used by hosted applications.  See PS 3.19, Section A.1.
+package dcmxml
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+type nativeDicomModel struct {
+	XMLName    xml.Name    `xml:"NativeDicomModel"`
+	Space      string      `xml:"xml:space,attr,omitempty"`
+	Attributes []attribute `xml:"DicomAttribute"`
+}
+
+// attribute is a DicomAttribute.  Only one of Values, PersonNames, Items,
+// InlineBinary and BulkData is present, or none if the value is empty.
+type attribute struct {
+	Tag            string       `xml:"tag,attr"`
+	VR             string       `xml:"vr,attr"`
+	Keyword        string       `xml:"keyword,attr,omitempty"`
+	PrivateCreator string       `xml:"privateCreator,attr,omitempty"`
+	Values         []value      `xml:"Value"`
+	PersonNames    []personName `xml:"PersonName"`
+	Items          []item       `xml:"Item"`
+	InlineBinary   string       `xml:"InlineBinary,omitempty"`
+	BulkData       *bulkData    `xml:"BulkData"`
+}
+
+// value is one of the values of an attribute, numbered from 1.  Empty
+// values may be left out.
+type value struct {
+	Number int    `xml:"number,attr"`
+	Text   string `xml:",chardata"`
+}
+
+type personName struct {
+	Number      int        `xml:"number,attr"`
+	Alphabetic  *nameGroup `xml:"Alphabetic"`
+	Ideographic *nameGroup `xml:"Ideographic"`
+	Phonetic    *nameGroup `xml:"Phonetic"`
+}
+
+type nameGroup struct {
+	FamilyName string `xml:"FamilyName,omitempty"`
+	GivenName  string `xml:"GivenName,omitempty"`
+	MiddleName string `xml:"MiddleName,omitempty"`
+	NamePrefix string `xml:"NamePrefix,omitempty"`
+	NameSuffix string `xml:"NameSuffix,omitempty"`
+}
+
+type item struct {
+	Number     int         `xml:"number,attr"`
+	Attributes []attribute `xml:"DicomAttribute"`
+}
+
+type bulkData struct {
+	URI string `xml:"uri,attr"`
+}
+
+// Encoder converts objects to XML.
+type Encoder struct {
+	// BulkDataURI, if set, is called for the binary elements (OB, OW,
+	// UN, etc, including encapsulated data).  If it returns a URI, that is
+	// written as BulkData instead of the value.  Otherwise, the value is
+	// written as InlineBinary.  Encapsulated data is written as it is
+	// encoded in a file (see dcm.EncapsulatedElement.Bytes).
+	BulkDataURI func(el dcm.Element) string
+}
+
+// Decoder converts XML to objects.
+type Decoder struct {
+	// BulkData, if set, retrieves the values of elements that are given
+	// by BulkData.  Otherwise, they are an error.
+	BulkData func(uri string) ([]byte, error)
+}
+
+// Marshal converts an object to XML, with binary values written inline.
+func Marshal(obj dcm.Object) ([]byte, error) {
+	return Encoder{}.Marshal(obj)
+}
+
+// Unmarshal converts XML to an object, which must not have BulkData.
+func Unmarshal(data []byte) (dcm.Object, error) {
+	return Decoder{}.Unmarshal(data)
+}
+
+// Marshal converts an object to an indented XML document.  Text values are
+// written as they are held in memory (UTF-8), and group length elements
+// are left out.
+func (e Encoder) Marshal(obj dcm.Object) ([]byte, error) {
+	attrs, err := e.object(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := xml.MarshalIndent(nativeDicomModel{
+		Space:      "preserve",
+		Attributes: attrs,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// tagString formats a tag as in the tag attribute, eg 0020000D
+func tagString(tag dcm.Tag) string {
+	return fmt.Sprintf("%08X", uint32(tag))
+}
+
+func (e Encoder) object(obj dcm.Object) (attrs []attribute, err error) {
+	obj.ForEach(func(tag dcm.Tag, el dcm.Element) bool {
+		if tag.IsGroupLength() {
+			return true
+		}
+
+		attr := attribute{Tag: tagString(tag)}
+
+		creator := ""
+		if creatorTag, ok := tag.PrivateCreator(); ok {
+			creator = obj.GetString(creatorTag)
+			attr.PrivateCreator = strings.TrimSpace(creator)
+		}
+
+		if spec := dcm.SpecForTag(attr.PrivateCreator, tag); spec != nil {
+			attr.Keyword = spec.GetKeyword()
+		}
+
+		if err = e.element(obj, el, &attr); err != nil {
+			err = fmt.Errorf("unable to convert %s to xml: %s", el, err)
+			return false
+		}
+
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	return attrs, err
+}
+
+func (e Encoder) element(obj dcm.Object, el dcm.Element, attr *attribute) error {
+	switch el := el.(type) {
+	case dcm.SequenceElement:
+		attr.VR = dcm.SQ.Name
+		for i, obj := range el.Objects {
+			itemAttrs, err := e.object(obj)
+			if err != nil {
+				return err
+			}
+
+			attr.Items = append(attr.Items, item{i + 1, itemAttrs})
+		}
+		return nil
+
+	case dcm.EncapsulatedElement:
+		attr.VR = el.VR.Name
+		e.binary(el, el.Bytes(), attr)
+		return nil
+
+	case dcm.SimpleElement:
+		attr.VR = el.VR.Name
+		if isBinary(el.VR) {
+			if len(el.Data) > 0 {
+				e.binary(el, el.WithByteOrder(binary.LittleEndian).Data, attr)
+			}
+			return nil
+		}
+
+		if dcm.VREq(&el.VR, &dcm.PN) {
+			names, err := obj.PersonNames(el.Tag)
+			for i, name := range names {
+				if name == (dcm.Name{}) {
+					continue
+				}
+
+				attr.PersonNames = append(attr.PersonNames, personName{
+					Number:      i + 1,
+					Alphabetic:  newNameGroup(name.Alphabetic),
+					Ideographic: newNameGroup(name.Ideographic),
+					Phonetic:    newNameGroup(name.Phonetic),
+				})
+			}
+			return err
+		}
+
+		values, err := simpleValues(obj, el)
+		for i, text := range values {
+			if text != "" {
+				attr.Values = append(attr.Values, value{i + 1, text})
+			}
+		}
+		return err
+
+	default:
+		return fmt.Errorf("unsupported element type %T", el)
+	}
+}
+
+func (e Encoder) binary(el dcm.Element, data []byte, attr *attribute) {
+	if e.BulkDataURI != nil {
+		if uri := e.BulkDataURI(el); uri != "" {
+			attr.BulkData = &bulkData{uri}
+			return
+		}
+	}
+
+	attr.InlineBinary = base64.StdEncoding.EncodeToString(data)
+}
+
+// isBinary returns whether values of the VR are written as InlineBinary
+// or BulkData instead of Value
+func isBinary(vr dcm.VR) bool {
+	switch vr.Name {
+	case dcm.OB.Name, dcm.OD.Name, dcm.OF.Name, dcm.OW.Name, dcm.UN.Name:
+		return true
+	default:
+		return false
+	}
+}
+
+func newNameGroup(ng dcm.NameGroup) *nameGroup {
+	if ng == (dcm.NameGroup{}) {
+		return nil
+	}
+
+	return &nameGroup{ng.Family, ng.Given, ng.Middle, ng.Prefix, ng.Suffix}
+}
+
+// simpleValues gives the text of the values of an element that isn't
+// binary or PN.
+func simpleValues(obj dcm.Object, el dcm.SimpleElement) ([]string, error) {
+	var values []string
+
+	switch el.VR.Name {
+	case dcm.US.Name, dcm.SS.Name, dcm.UL.Name, dcm.SL.Name:
+		ints, err := obj.Ints(el.Tag)
+		for _, value := range ints {
+			values = append(values, strconv.FormatInt(value, 10))
+		}
+		return values, err
+
+	case dcm.FL.Name, dcm.FD.Name:
+		bits := 64
+		if el.VR.Name == dcm.FL.Name {
+			bits = 32
+		}
+
+		floats, err := obj.Floats(el.Tag)
+		for _, value := range floats {
+			values = append(values, strconv.FormatFloat(value, 'G', -1, bits))
+		}
+		return values, err
+
+	case dcm.AT.Name:
+		tags, err := obj.Tags(el.Tag)
+		for _, value := range tags {
+			values = append(values, tagString(value))
+		}
+		return values, err
+
+	default:
+		// including IS and DS, which are kept as written
+		return obj.Strings(el.Tag)
+	}
+}
+
+// Unmarshal converts XML to an object.  Text values are kept in UTF-8, so
+// SpecificCharacterSet should allow for them if the object is to be written
+// out again.
+func (d Decoder) Unmarshal(data []byte) (dcm.Object, error) {
+	var model nativeDicomModel
+	if err := xml.Unmarshal(data, &model); err != nil {
+		return dcm.Object{}, err
+	}
+
+	return d.object(model.Attributes)
+}
+
+func (d Decoder) object(attrs []attribute) (dcm.Object, error) {
+	obj := dcm.NewObject()
+
+	for _, attr := range attrs {
+		el, err := d.element(attr)
+		if err != nil {
+			return obj, fmt.Errorf("invalid attribute %s: %s", attr.Tag, err)
+		}
+
+		obj.Put(el)
+	}
+
+	return obj, nil
+}
+
+func parseTag(str string) (dcm.Tag, error) {
+	if len(str) != 8 {
+		return 0, fmt.Errorf("invalid tag %q", str)
+	}
+
+	value, err := strconv.ParseUint(str, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tag %q", str)
+	}
+
+	return dcm.Tag(value), nil
+}
+
+func (d Decoder) element(attr attribute) (dcm.Element, error) {
+	tag, err := parseTag(attr.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	vr := dcm.GetVRByName(attr.VR)
+	if vr.Name != attr.VR {
+		return nil, fmt.Errorf("unknown vr %q", attr.VR)
+	}
+
+	switch {
+	case dcm.VREq(vr, &dcm.SQ):
+		sq := dcm.SequenceElement{Tag: tag}
+		for _, item := range attr.Items {
+			obj, err := d.object(item.Attributes)
+			if err != nil {
+				return nil, err
+			}
+
+			sq.Objects = append(sq.Objects, obj)
+		}
+		return sq, nil
+
+	case attr.BulkData != nil:
+		if d.BulkData == nil {
+			return nil, fmt.Errorf("unable to retrieve bulk data %s",
+				attr.BulkData.URI)
+		}
+
+		data, err := d.BulkData(attr.BulkData.URI)
+		if err != nil {
+			return nil, err
+		}
+		return binaryElement(tag, vr, data)
+
+	case attr.InlineBinary != "":
+		data, err := base64.StdEncoding.DecodeString(
+			strings.TrimSpace(attr.InlineBinary))
+		if err != nil {
+			return nil, err
+		}
+		return binaryElement(tag, vr, data)
+
+	case len(attr.PersonNames) > 0:
+		numbers := make([]int, len(attr.PersonNames))
+		for i, pn := range attr.PersonNames {
+			numbers[i] = pn.Number
+		}
+		count, err := valueCount(numbers)
+		if err != nil {
+			return nil, err
+		}
+
+		names := make([]dcm.Name, count)
+		for _, pn := range attr.PersonNames {
+			names[pn.Number-1] = dcm.Name{
+				Alphabetic:  pn.Alphabetic.nameGroup(),
+				Ideographic: pn.Ideographic.nameGroup(),
+				Phonetic:    pn.Phonetic.nameGroup(),
+			}
+		}
+		return dcm.NewElement(tag, vr, names)
+
+	case len(attr.Values) > 0:
+		numbers := make([]int, len(attr.Values))
+		for i, v := range attr.Values {
+			numbers[i] = v.Number
+		}
+		count, err := valueCount(numbers)
+		if err != nil {
+			return nil, err
+		}
+
+		texts := make([]string, count)
+		for _, v := range attr.Values {
+			texts[v.Number-1] = v.Text
+		}
+
+		value, err := parseValues(*vr, texts)
+		if err != nil {
+			return nil, err
+		}
+		return dcm.NewElement(tag, vr, value)
+
+	default:
+		return dcm.SimpleElement{Tag: tag, VR: *vr}, nil
+	}
+}
+
+// maxValueNumber limits the value numbers we accept.  Empty values are
+// left out, so there may be gaps, but a document shouldn't be able to make
+// us allocate arbitrarily many values.
+const maxValueNumber = 1 << 16
+
+// valueCount checks the value numbers and gives the number of values,
+// which is the highest value number
+func valueCount(numbers []int) (int, error) {
+	count := 0
+	for _, number := range numbers {
+		if number < 1 || number > maxValueNumber {
+			return 0, fmt.Errorf("invalid value number %d", number)
+		}
+
+		if number > count {
+			count = number
+		}
+	}
+	return count, nil
+}
+
+// binaryElement makes an element from InlineBinary or BulkData, which may
+// hold encapsulated data.
+func binaryElement(tag dcm.Tag, vr *dcm.VR, data []byte) (dcm.Element, error) {
+	if tag == dcm.PixelData && dcm.IsEncapsulated(data) {
+		return dcm.ParseEncapsulated(tag, *vr, data)
+	}
+
+	if !isBinary(*vr) {
+		return nil, errors.New("binary data for a vr with values")
+	}
+
+	return dcm.NewElement(tag, vr, data)
+}
+
+func (ng *nameGroup) nameGroup() dcm.NameGroup {
+	if ng == nil {
+		return dcm.NameGroup{}
+	}
+
+	return dcm.NameGroup{
+		Family: ng.FamilyName,
+		Given:  ng.GivenName,
+		Middle: ng.MiddleName,
+		Prefix: ng.NamePrefix,
+		Suffix: ng.NameSuffix,
+	}
+}
+
+// parseValues converts the text of Value elements to a value for
+// dcm.NewElement
+func parseValues(vr dcm.VR, texts []string) (interface{}, error) {
+	switch vr.Name {
+	case dcm.US.Name, dcm.SS.Name, dcm.UL.Name, dcm.SL.Name,
+		dcm.FL.Name, dcm.FD.Name, dcm.AT.Name:
+		// binary values can't be empty, so there can't be gaps either
+		for i, text := range texts {
+			if strings.TrimSpace(text) == "" {
+				return nil, fmt.Errorf("missing %s value number %d",
+					vr.Name, i+1)
+			}
+		}
+	}
+
+	switch vr.Name {
+	case dcm.US.Name, dcm.SS.Name, dcm.UL.Name, dcm.SL.Name:
+		ints := make([]int64, len(texts))
+		for i, text := range texts {
+			var err error
+			if ints[i], err = strconv.ParseInt(strings.TrimSpace(text), 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		return ints, nil
+
+	case dcm.FL.Name, dcm.FD.Name:
+		floats := make([]float64, len(texts))
+		for i, text := range texts {
+			var err error
+			if floats[i], err = strconv.ParseFloat(strings.TrimSpace(text), 64); err != nil {
+				return nil, err
+			}
+		}
+		return floats, nil
+
+	case dcm.AT.Name:
+		tags := make([]dcm.Tag, len(texts))
+		for i, text := range texts {
+			var err error
+			if tags[i], err = parseTag(strings.TrimSpace(text)); err != nil {
+				return nil, err
+			}
+		}
+		return tags, nil
+
+	default:
+		return texts, nil
+	}
+}
@@ -0,0 +1,183 @@
+package dcmxml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jeremyhuiskamp/dcm/dcm"
+)
+
+func newElement(t *testing.T, tag dcm.Tag, value interface{}) dcm.Element {
+	el, err := dcm.NewElement(tag, nil, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return el
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	item := dcm.NewObject()
+	item.Put(newElement(t, dcm.SeriesInstanceUID, "1.2.3"))
+
+	obj := dcm.NewObject()
+	obj.Put(newElement(t, dcm.ImageType, []string{"ORIGINAL", "", "AXIAL"}))
+	obj.Put(newElement(t, dcm.PatientName, dcm.Name{
+		Alphabetic:  dcm.NameGroup{Family: "Yamada", Given: "Tarou"},
+		Ideographic: dcm.NameGroup{Family: "山田", Given: "太郎"},
+	}))
+	obj.Put(dcm.SimpleElement{Tag: dcm.PatientBirthDate, VR: dcm.DA})
+	obj.Put(newElement(t, dcm.Rows, 512))
+	obj.Put(newElement(t, dcm.PixelSpacing, []string{"0.50", "0.25"}))
+	obj.Put(newElement(t, dcm.FrameIncrementPointer, dcm.FrameTime))
+	obj.Put(newElement(t, dcm.RecommendedDisplayFrameRateInFloat, 0.1))
+	obj.Put(dcm.SequenceElement{
+		Tag:     dcm.ReferencedSeriesSequence,
+		Objects: []dcm.Object{item},
+	})
+	obj.Put(dcm.SimpleElement{Tag: dcm.Tag(0x00290010), VR: dcm.LO, Data: []byte("ACME")})
+	obj.Put(dcm.SimpleElement{Tag: dcm.Tag(0x00291001), VR: dcm.OB, Data: []byte{1, 2, 3, 4}})
+	obj.Put(dcm.EncapsulatedElement{
+		Tag:  dcm.PixelData,
+		VR:   dcm.OB,
+		Data: [][]byte{{}, {5, 6}},
+	})
+
+	data, err := Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `<?xml version="1.0" encoding="UTF-8"?>
+<NativeDicomModel xml:space="preserve">
+  <DicomAttribute tag="00080008" vr="CS" keyword="ImageType">
+    <Value number="1">ORIGINAL</Value>
+    <Value number="3">AXIAL</Value>
+  </DicomAttribute>
+  <DicomAttribute tag="00081115" vr="SQ" keyword="ReferencedSeriesSequence">
+    <Item number="1">
+      <DicomAttribute tag="0020000E" vr="UI" keyword="SeriesInstanceUID">
+        <Value number="1">1.2.3</Value>
+      </DicomAttribute>
+    </Item>
+  </DicomAttribute>
+  <DicomAttribute tag="00089459" vr="FL" keyword="RecommendedDisplayFrameRateInFloat">
+    <Value number="1">0.1</Value>
+  </DicomAttribute>
+  <DicomAttribute tag="00100010" vr="PN" keyword="PatientName">
+    <PersonName number="1">
+      <Alphabetic>
+        <FamilyName>Yamada</FamilyName>
+        <GivenName>Tarou</GivenName>
+      </Alphabetic>
+      <Ideographic>
+        <FamilyName>山田</FamilyName>
+        <GivenName>太郎</GivenName>
+      </Ideographic>
+    </PersonName>
+  </DicomAttribute>
+  <DicomAttribute tag="00100030" vr="DA" keyword="PatientBirthDate"></DicomAttribute>
+  <DicomAttribute tag="00280009" vr="AT" keyword="FrameIncrementPointer">
+    <Value number="1">00181063</Value>
+  </DicomAttribute>
+  <DicomAttribute tag="00280010" vr="US" keyword="Rows">
+    <Value number="1">512</Value>
+  </DicomAttribute>
+  <DicomAttribute tag="00280030" vr="DS" keyword="PixelSpacing">
+    <Value number="1">0.50</Value>
+    <Value number="2">0.25</Value>
+  </DicomAttribute>
+  <DicomAttribute tag="00290010" vr="LO" keyword="PrivateCreator">
+    <Value number="1">ACME</Value>
+  </DicomAttribute>
+  <DicomAttribute tag="00291001" vr="OB" privateCreator="ACME">
+    <InlineBinary>AQIDBA==</InlineBinary>
+  </DicomAttribute>
+  <DicomAttribute tag="7FE00010" vr="OB" keyword="PixelData">
+    <InlineBinary>/v8A4AAAAAD+/wDgAgAAAAUG/v/d4AAAAAA=</InlineBinary>
+  </DicomAttribute>
+</NativeDicomModel>
+`
+	if string(data) != exp {
+		t.Fatalf("unexpected xml:\n%s\nexpected:\n%s", data, exp)
+	}
+
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(obj, got) {
+		t.Fatalf("expected\n%s\ngot\n%s", obj, got)
+	}
+}
+
+func TestBulkData(t *testing.T) {
+	obj := dcm.NewObject()
+	obj.Put(dcm.SimpleElement{Tag: dcm.PixelData, VR: dcm.OW, Data: []byte{1, 2}})
+
+	encoder := Encoder{BulkDataURI: func(el dcm.Element) string {
+		return "file:pixels.raw"
+	}}
+
+	data, err := encoder.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), `<BulkData uri="file:pixels.raw"></BulkData>`) {
+		t.Fatalf("unexpected xml:\n%s", data)
+	}
+
+	if _, err = Unmarshal(data); err == nil {
+		t.Fatal("expected error reading bulk data")
+	}
+
+	decoder := Decoder{BulkData: func(uri string) ([]byte, error) {
+		return []byte{1, 2}, nil
+	}}
+
+	got, err := decoder.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(obj, got) {
+		t.Fatalf("expected\n%s\ngot\n%s", obj, got)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, data := range []string{
+		`<NativeDicomModel><DicomAttribute tag="0010" vr="LO"/></NativeDicomModel>`,
+		`<NativeDicomModel><DicomAttribute tag="00100020" vr="XX"/></NativeDicomModel>`,
+		`<NativeDicomModel><DicomAttribute tag="00280010" vr="US">
+			<Value number="1">x</Value></DicomAttribute></NativeDicomModel>`,
+		`<NativeDicomModel><DicomAttribute tag="00100020" vr="LO">
+			<Value number="0">x</Value></DicomAttribute></NativeDicomModel>`,
+		// numbers beyond the values, and gaps:
+		`<NativeDicomModel><DicomAttribute tag="00280010" vr="US">
+			<Value number="2">1</Value></DicomAttribute></NativeDicomModel>`,
+		`<NativeDicomModel><DicomAttribute tag="00100020" vr="LO">
+			<Value number="1000000000">x</Value></DicomAttribute></NativeDicomModel>`,
+		`<NativeDicomModel><DicomAttribute tag="00100010" vr="PN">
+			<PersonName number="1000000000"><Alphabetic><FamilyName>x</FamilyName>
+			</Alphabetic></PersonName></DicomAttribute></NativeDicomModel>`,
+		`<NativeDicomModel><DicomAttribute tag="00100020" vr="LO">
+			<InlineBinary>AQI=</InlineBinary></DicomAttribute></NativeDicomModel>`,
+		`<NativeDicomModel><DicomAttribute tag="00100020" vr="LO">`,
+	} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestUnmarshalMissingValue(t *testing.T) {
+	_, err := Unmarshal([]byte(`<NativeDicomModel>
+		<DicomAttribute tag="00280010" vr="US"><Value number="2">1</Value>
+		</DicomAttribute></NativeDicomModel>`))
+	if err == nil || !strings.Contains(err.Error(), "missing US value number 1") {
+		t.Fatalf("expected missing value error, got %v", err)
+	}
+}